@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"log"
+	"strings"
+	_ "time/tzdata" // series timezones don't depend on the host having zoneinfo
 
 	"github.com/gin-gonic/gin"
 	"github.com/wmfadel/wander-base/db"
 	"github.com/wmfadel/wander-base/internal/di"
 	"github.com/wmfadel/wander-base/internal/routes"
+	"github.com/wmfadel/wander-base/internal/service"
 	"github.com/wmfadel/wander-base/pkg/utils"
 )
 
@@ -22,10 +27,27 @@ func main() {
 	dbConnection := db.InitDB(*migrate, *seed)
 
 	server := gin.Default()
-	server.Static("/user_photos", "./public/user_photos")
-	server.Static("/event_photos", "./public/event_photos")
-
+	// Logins are throttled per client address, so X-Forwarded-For is only believed from known proxies
+	var proxies []string
+	if value := utils.GetFromEnv("TRUSTED_PROXIES"); value != "" {
+		for _, proxy := range strings.Split(value, ",") {
+			proxies = append(proxies, strings.TrimSpace(proxy))
+		}
+	}
+	if err := server.SetTrustedProxies(proxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 	container := di.NewDependencies(dbConnection)
+	container.ModerationQueue.Start(context.Background(), service.ModerationWorkers)
+	container.EventService.StartMaterializer(context.Background())
+
+	// Files on the local disk are served by the app itself, other drivers serve their own URLs
+	if local, ok := container.Storage.(*utils.LocalStorage); ok {
+		server.Static(local.RoutePath(), local.Dir)
+		for path, dir := range local.LegacyRoutes() {
+			server.Static(path, dir)
+		}
+	}
 	routes.RegisterRoutes(server, *container)
 
 	server.Run(":8080")
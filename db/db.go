@@ -11,6 +11,7 @@ import (
 	"github.com/wmfadel/wander-base/pkg/utils"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -61,23 +62,103 @@ func InitDB(migrate, seed bool) *gorm.DB {
 			log.Fatalf("Failed to create registration_status ENUM: %v", err)
 		}
 
+		err = db.Exec(`CREATE TYPE event_status AS ENUM (
+			'draft',
+			'published',
+			'cancelled',
+			'completed'
+		)`).Error
+		if err != nil && !isAlreadyExistsError(err) {
+			log.Fatalf("Failed to create event_status ENUM: %v", err)
+		}
+
+		err = db.Exec(`CREATE TYPE photo_status AS ENUM (
+			'pending',
+			'approved',
+			'rejected',
+			'hidden'
+		)`).Error
+		if err != nil && !isAlreadyExistsError(err) {
+			log.Fatalf("Failed to create photo_status ENUM: %v", err)
+		}
+
+		err = db.Exec(`CREATE TYPE comment_status AS ENUM (
+			'pending',
+			'visible',
+			'hidden'
+		)`).Error
+		if err != nil && !isAlreadyExistsError(err) {
+			log.Fatalf("Failed to create comment_status ENUM: %v", err)
+		}
+
+		err = db.Exec(`CREATE TYPE report_status AS ENUM (
+			'open',
+			'dismissed',
+			'actioned'
+		)`).Error
+		if err != nil && !isAlreadyExistsError(err) {
+			log.Fatalf("Failed to create report_status ENUM: %v", err)
+		}
+
+		// Values added to the ENUM after it was first created
+		for _, value := range []string{"rejected", "waitlisted"} {
+			err = db.Exec(fmt.Sprintf("ALTER TYPE registration_status ADD VALUE IF NOT EXISTS '%s'", value)).Error
+			if err != nil {
+				log.Fatalf("Failed to add %s to registration_status ENUM: %v", value, err)
+			}
+		}
+
+		// Users who signed up before phones were verified keep their access, they count as verified
+		backfillPhoneVerified := !db.Migrator().HasColumn(&models.User{}, "PhoneVerifiedAt")
+
 		// Auto-migrate tables
 		err = db.AutoMigrate(
 			&models.User{},
 			&models.Role{},
 			&models.UserRole{},
+			&models.Permission{},
+			&models.RolePermission{},
 			&models.Event{},
+			&models.EventCoHost{},
 			&models.Destination{},
 			&models.EventDestination{},
 			&models.Activity{},
 			&models.EventActivities{},
+			&models.ItineraryStop{},
+			&models.PhotoAlbum{},
 			&models.EventPhoto{},
 			&models.Registration{},
 			&models.Comment{},
+			&models.CommentEdit{},
+			&models.CommentReaction{},
+			&models.Report{},
+			&models.ModerationAction{},
+			&models.PhoneVerification{},
+			&models.LoginThrottle{},
+			&models.SecurityEvent{},
+			&models.TwoFactor{},
+			&models.RecoveryCode{},
+			&models.LoginChallenge{},
+			&models.Session{},
+			&models.RefreshToken{},
+			&models.RevokedAccessToken{},
+			&models.CalendarToken{},
 		)
 		if err != nil {
 			log.Fatalf("Failed to auto-migrate: %v", err)
 		}
+
+		if err := createEventSearchIndexes(db); err != nil {
+			log.Fatalf("Failed to create event search indexes: %v", err)
+		}
+		if backfillPhoneVerified {
+			if err := db.Exec("UPDATE users SET phone_verified_at = now() WHERE phone_verified_at IS NULL").Error; err != nil {
+				log.Fatalf("Failed to mark existing phones verified: %v", err)
+			}
+		}
+		if err := migrateLegacyFileURLs(db); err != nil {
+			log.Fatalf("Failed to migrate file URLs: %v", err)
+		}
 		log.Println("Database migrated")
 	}
 
@@ -87,6 +168,12 @@ func InitDB(migrate, seed bool) *gorm.DB {
 			log.Fatalf("Failed to seed roles: %v", err)
 		}
 		log.Println("Roles seeded successfully")
+
+		// Seed permissions and grant the defaults to the seeded roles
+		if err := seedPermissions(db); err != nil {
+			log.Fatalf("Failed to seed permissions: %v", err)
+		}
+		log.Println("Permissions seeded successfully")
 	}
 
 	log.Println("Database Connected...")
@@ -101,6 +188,74 @@ func isAlreadyExistsError(err error) bool {
 	return false
 }
 
+// createEventSearchIndexes adds the full text search column over name, description and location,
+// Postgres keeps it up to date as a generated column. GORM doesn't know about it, so it's created here.
+func createEventSearchIndexes(db *gorm.DB) error {
+	statements := []string{
+		`ALTER TABLE events ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (to_tsvector('simple',
+				coalesce(name, '') || ' ' || coalesce(description, '') || ' ' || coalesce(location, ''))) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_events_search_vector ON events USING GIN (search_vector)`,
+		`CREATE INDEX IF NOT EXISTS idx_events_date_time_id ON events (date_time, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_events_name_id ON events (name, id)`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateLegacyFileURLs points the URLs of files saved before they moved under STORAGE_PUBLIC_URL at it,
+// the files themselves stay where they are. Photos from back then had a single size, it stands in for
+// every variant so the photo's files are found when it's deleted.
+func migrateLegacyFileURLs(db *gorm.DB) error {
+	storage, err := utils.NewStorageFromEnv()
+	if err != nil {
+		return err
+	}
+	local, ok := storage.(*utils.LocalStorage)
+	if !ok {
+		return nil
+	}
+
+	columns := []struct{ table, url, variants string }{
+		{"event_photos", "url", "variant_"},
+		{"users", "photo", "photo_"},
+	}
+	for _, column := range columns {
+		var rows []struct {
+			ID  int64
+			URL string
+		}
+		err := db.Table(column.table).
+			Select("id, "+column.url+" AS url").
+			Where(column.url+" <> '' AND "+column.url+" NOT LIKE ?", local.BaseURL+"/%").
+			Scan(&rows).Error
+		if err != nil {
+			return fmt.Errorf("failed to get %s: %w", column.table, err)
+		}
+
+		for _, row := range rows {
+			key, err := local.KeyFromURL(row.URL)
+			if err != nil {
+				continue // not a file of the local storage
+			}
+			url := local.URL(key)
+			updates := map[string]interface{}{column.url: url}
+			for _, size := range []string{"thumbnail", "medium", "original"} {
+				variant := column.variants + size + "_url"
+				updates[variant] = gorm.Expr("CASE WHEN coalesce("+variant+", '') IN ('', ?) THEN ? ELSE "+variant+" END", row.URL, url)
+			}
+			if err := db.Table(column.table).Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to migrate file URL of %s %d: %w", column.table, row.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
 // seedRoles seeds the roles table with predefined roles
 func seedRoles(db *gorm.DB) error {
 	roles := []models.Role{
@@ -128,3 +283,48 @@ func seedRoles(db *gorm.DB) error {
 
 	return nil
 }
+
+// seedPermissions seeds the permissions table and the default role permissions.
+// Existing grants are kept so changes made by admins survive a re-seed.
+func seedPermissions(db *gorm.DB) error {
+	permissions := []models.Permission{
+		{Name: models.PermissionEventsCreate, Description: "Create events"},
+		{Name: models.PermissionEventsEditOwn, Description: "Edit and delete own or co-hosted events"},
+		{Name: models.PermissionEventsEditAny, Description: "Edit and delete any event"},
+		{Name: models.PermissionPhotosUpload, Description: "Upload event photos"},
+		{Name: models.PermissionCommentsModerate, Description: "Moderate comments"},
+		{Name: models.PermissionReportsManage, Description: "Triage reported comments, photos and users"},
+		{Name: models.PermissionCatalogManage, Description: "Manage activities and destinations"},
+		{Name: models.PermissionRolesManage, Description: "Manage roles and permissions"},
+		{Name: models.PermissionUsersManage, Description: "Manage and block users"},
+	}
+
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&permissions).Error
+	if err != nil {
+		return fmt.Errorf("failed to create permissions: %w", err)
+	}
+
+	allPermissions := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		allPermissions = append(allPermissions, permission.Name)
+	}
+	defaults := map[string][]string{
+		models.RoleAdmin:        allPermissions,
+		models.RoleOrganizer:    {models.PermissionEventsCreate, models.PermissionEventsEditOwn, models.PermissionPhotosUpload},
+		models.RolePhotographer: {models.PermissionPhotosUpload},
+	}
+
+	for roleName, permissionNames := range defaults {
+		err := db.Exec(`
+			INSERT INTO role_permissions (role_id, permission_id)
+			SELECT r.id, p.id FROM roles r, permissions p
+			WHERE r.name = ? AND p.name IN ?
+			ON CONFLICT DO NOTHING`,
+			roleName, permissionNames).Error
+		if err != nil {
+			return fmt.Errorf("failed to grant permissions to role %s: %w", roleName, err)
+		}
+	}
+
+	return nil
+}
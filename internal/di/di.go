@@ -16,7 +16,7 @@ import (
 type DIContainer struct {
 	// DB
 	DB      *gorm.DB
-	Storage *utils.Storage
+	Storage utils.Storage
 	// Services
 	EventService        *service.EventService
 	UserService         *service.UserService
@@ -26,6 +26,15 @@ type DIContainer struct {
 	ActivityService     *service.ActivityService
 	DestinationService  *service.DestinationService
 	CommentService      *service.CommentService
+	TokenService        *service.TokenService
+	ItineraryService    *service.ItineraryService
+	CalendarService     *service.CalendarService
+	ModerationQueue     *service.ModerationQueue
+	ReportService       *service.ReportService
+	VerificationService *service.VerificationService
+	PasswordService     *service.PasswordService
+	LoginGuardService   *service.LoginGuardService
+	TwoFactorService    *service.TwoFactorService
 
 	// Handlers
 	AuthHandler         *handlers.AuthHandler
@@ -36,6 +45,14 @@ type DIContainer struct {
 	ActivityHandler     *handlers.ActivityHandler
 	DestinationHandler  *handlers.DestinationHandler
 	CommentHandler      *handlers.CommentHandler
+	ItineraryHandler    *handlers.ItineraryHandler
+	CalendarHandler     *handlers.CalendarHandler
+	PhotoAlbumHandler   *handlers.PhotoAlbumHandler
+	ReportHandler       *handlers.ReportHandler
+	VerificationHandler *handlers.VerificationHandler
+	SecurityHandler     *handlers.SecurityHandler
+	TwoFactorHandler    *handlers.TwoFactorHandler
+	SessionHandler      *handlers.SessionHandler
 
 	// Middlewares
 	AuthMiddleware *middleware.AuthMiddleware
@@ -46,7 +63,14 @@ func NewDependencies(db *gorm.DB) *DIContainer {
 	key := utils.GetFromEnv("OPENAI_API_KEY")
 	log.Print("key: ", key)
 	openaiClient := openai.NewClient(key)
-	storage := utils.NewStorage("http://localhost:8080")
+	storage, err := utils.NewStorageFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up storage: %v", err)
+	}
+	smsSender, err := utils.NewSMSSenderFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up SMS: %v", err)
+	}
 	// Repositories initialization
 	eventPhotosRepository := repository.NewEventPhotoRepository(db, storage)
 	eventRepo := repository.NewEventRepository(db, eventPhotosRepository)
@@ -56,28 +80,65 @@ func NewDependencies(db *gorm.DB) *DIContainer {
 	activityRepo := repository.NewActivityRepository(db)
 	destinationRepo := repository.NewDestinationRepository(db)
 	commentRepository := repository.NewCommentRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	itineraryRepo := repository.NewItineraryRepository(db)
+	photoAlbumRepo := repository.NewPhotoAlbumRepository(db)
+	reportRepo := repository.NewReportRepository(db)
+	verificationRepo := repository.NewVerificationRepository(db)
+	loginThrottleRepo := repository.NewLoginThrottleRepository(db)
+	securityEventRepo := repository.NewSecurityEventRepository(db)
+	twoFactorRepo := repository.NewTwoFactorRepository(db)
 	// Services initialization
-	eventPhotosService := service.NewEventPhotoService(eventPhotosRepository)
+	eventPhotosService := service.NewEventPhotoService(eventPhotosRepository, photoAlbumRepo, registrationRepo)
 	eventService := service.NewEventService(eventRepo, eventPhotosService)
 	userService := service.NewUserService(userRepo, rolesRepo)
 	rolesService := service.NewRoleService(rolesRepo)
 	registrationService := service.NewRegistrationService(registrationRepo)
 	activityService := service.NewActivityService(activityRepo)
 	destinationService := service.NewDestinationService(destinationRepo)
-	auditService := service.NewAuditService(openaiClient, 0.7)
-	commentService := service.NewCommentService(commentRepository, auditService)
+	threshold, err := service.ModerationThresholdFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up moderation: %v", err)
+	}
+	moderator, err := service.NewModeratorFromEnv(openaiClient)
+	if err != nil {
+		log.Fatalf("Failed to set up moderation: %v", err)
+	}
+	moderationService := service.NewModerationService(moderator, threshold)
+	moderationQueue := service.NewModerationQueue(commentRepository, moderationService)
+	commentService := service.NewCommentService(commentRepository, moderationQueue)
+	tokenService := service.NewTokenService(tokenRepo, userRepo, securityEventRepo)
+	itineraryService := service.NewItineraryService(itineraryRepo)
+	calendarService := service.NewCalendarService(tokenRepo, registrationRepo)
+	reportThreshold, err := service.ReportThresholdFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to set up reports: %v", err)
+	}
+	reportService := service.NewReportService(reportRepo, commentService, eventPhotosService, eventService, userService, rolesService, reportThreshold)
+	verificationService := service.NewVerificationService(verificationRepo, userRepo, smsSender)
+	passwordService := service.NewPasswordService(userRepo, tokenService, verificationService)
+	twoFactorService := service.NewTwoFactorService(twoFactorRepo, securityEventRepo, userRepo, verificationService, service.TwoFactorRolesFromEnv())
+	loginGuardService := service.NewLoginGuardService(loginThrottleRepo, securityEventRepo, userRepo, twoFactorService)
 	// Handlers initialization
 
-	authHandler := handlers.NewAuthHandler(userService)
-	adminHandler := handlers.NewAdmingHandler(rolesService, userService)
+	authHandler := handlers.NewAuthHandler(userService, tokenService, verificationService, passwordService, loginGuardService)
+	adminHandler := handlers.NewAdmingHandler(rolesService, userService, tokenService)
 	profileHandler := handlers.NewProfileHandler(userService)
 	eventHandler := handlers.NewEventHandler(eventService, eventPhotosService)
 	registrationHandler := handlers.NewRegistrationHandler(registrationService)
 	activityHandler := handlers.NewActivityHandler(activityService)
 	destinationHandler := handlers.NewDestinationHandler(destinationService)
 	commentHandler := handlers.NewCommentHandler(commentService)
+	itineraryHandler := handlers.NewItineraryHandler(itineraryService, eventService)
+	calendarHandler := handlers.NewCalendarHandler(calendarService, eventService)
+	photoAlbumHandler := handlers.NewPhotoAlbumHandler(eventPhotosService, eventService)
+	reportHandler := handlers.NewReportHandler(reportService)
+	verificationHandler := handlers.NewVerificationHandler(verificationService)
+	securityHandler := handlers.NewSecurityHandler(loginGuardService, twoFactorService)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService, tokenService, loginGuardService, verificationService)
+	sessionHandler := handlers.NewSessionHandler(tokenService)
 	// Middlewares initialization
-	authMiddleware := middleware.NewAuthMiddleware(userService, eventService)
+	authMiddleware := middleware.NewAuthMiddleware(userService, eventService, tokenService, twoFactorService)
 
 	return &DIContainer{
 		// DB
@@ -92,6 +153,15 @@ func NewDependencies(db *gorm.DB) *DIContainer {
 		ActivityService:     activityService,
 		DestinationService:  destinationService,
 		CommentService:      commentService,
+		ModerationQueue:     moderationQueue,
+		TokenService:        tokenService,
+		ItineraryService:    itineraryService,
+		CalendarService:     calendarService,
+		ReportService:       reportService,
+		VerificationService: verificationService,
+		PasswordService:     passwordService,
+		LoginGuardService:   loginGuardService,
+		TwoFactorService:    twoFactorService,
 		// Handlers
 		AuthHandler:         authHandler,
 		AdminHandler:        adminHandler,
@@ -101,6 +171,14 @@ func NewDependencies(db *gorm.DB) *DIContainer {
 		ActivityHandler:     activityHandler,
 		DestinationHandler:  destinationHandler,
 		CommentHandler:      commentHandler,
+		ItineraryHandler:    itineraryHandler,
+		CalendarHandler:     calendarHandler,
+		PhotoAlbumHandler:   photoAlbumHandler,
+		ReportHandler:       reportHandler,
+		VerificationHandler: verificationHandler,
+		SecurityHandler:     securityHandler,
+		TwoFactorHandler:    twoFactorHandler,
+		SessionHandler:      sessionHandler,
 		// Middlewares
 		AuthMiddleware: authMiddleware,
 	}
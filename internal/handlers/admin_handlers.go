@@ -9,15 +9,17 @@ import (
 	"github.com/wmfadel/wander-base/internal/models/core"
 	"github.com/wmfadel/wander-base/internal/models/requests"
 	"github.com/wmfadel/wander-base/internal/service"
+	"github.com/wmfadel/wander-base/pkg/utils"
 )
 
 type AdmingHandler struct {
 	RolesService *service.RoleService
 	UserService  *service.UserService
+	TokenService *service.TokenService
 }
 
-func NewAdmingHandler(rolesService *service.RoleService, userService *service.UserService) *AdmingHandler {
-	return &AdmingHandler{RolesService: rolesService, UserService: userService}
+func NewAdmingHandler(rolesService *service.RoleService, userService *service.UserService, tokenService *service.TokenService) *AdmingHandler {
+	return &AdmingHandler{RolesService: rolesService, UserService: userService, TokenService: tokenService}
 }
 
 func (h *AdmingHandler) GetAllRoles(c *gin.Context) {
@@ -139,7 +141,7 @@ func (h *AdmingHandler) GetUsersByRoleId(c *gin.Context) {
 
 func (h *AdmingHandler) GetAllAdmins(c *gin.Context) {
 
-	users, err := h.RolesService.GetUsersByRoleId(1)
+	users, err := h.RolesService.GetUsersByRoleName(models.RoleAdmin)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get roles", err))
 		return
@@ -149,7 +151,7 @@ func (h *AdmingHandler) GetAllAdmins(c *gin.Context) {
 }
 
 func (h *AdmingHandler) GetAllOrganizers(c *gin.Context) {
-	users, err := h.RolesService.GetUsersByRoleId(2)
+	users, err := h.RolesService.GetUsersByRoleName(models.RoleOrganizer)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get roles", err))
 		return
@@ -224,3 +226,125 @@ func (h *AdmingHandler) BlockUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "user blocked"})
 
 }
+
+func (h *AdmingHandler) GetUserSessions(c *gin.Context) {
+	userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse user ID", err))
+		return
+	}
+
+	sessions, err := h.TokenService.GetSessions(userId, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get sessions", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func (h *AdmingHandler) RevokeUserSession(c *gin.Context) {
+	userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse user ID", err))
+		return
+	}
+	admin, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	err = h.TokenService.RevokeUserSession(userId, c.Param("session_id"), admin)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to revoke session", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeUserSessions logs the user out everywhere.
+func (h *AdmingHandler) RevokeUserSessions(c *gin.Context) {
+	userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse user ID", err))
+		return
+	}
+	admin, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	err = h.TokenService.RevokeUserSessions(userId, admin)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to revoke sessions", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked"})
+}
+
+func (h *AdmingHandler) GetAllPermissions(c *gin.Context) {
+	permissions, err := h.RolesService.GetAllPermissions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get permissions", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"permissions": permissions})
+}
+
+func (h *AdmingHandler) GetRolePermissions(c *gin.Context) {
+	roleId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse role ID", err))
+		return
+	}
+
+	permissions, err := h.RolesService.GetRolePermissions(roleId)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to get role permissions", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"permissions": permissions})
+}
+
+func (h *AdmingHandler) GrantPermissions(c *gin.Context) {
+	roleId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse role ID", err))
+		return
+	}
+
+	var permissionsRequest requests.RolePermissionsRequest
+	if err := c.ShouldBindJSON(&permissionsRequest); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse permissions", err))
+		return
+	}
+
+	err = h.RolesService.GrantPermissions(roleId, permissionsRequest.Permissions)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to grant permissions", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Permissions granted"})
+}
+
+func (h *AdmingHandler) RevokePermissions(c *gin.Context) {
+	roleId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse role ID", err))
+		return
+	}
+
+	var permissionsRequest requests.RolePermissionsRequest
+	if err := c.ShouldBindJSON(&permissionsRequest); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse permissions", err))
+		return
+	}
+
+	err = h.RolesService.RevokePermissions(roleId, permissionsRequest.Permissions)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to revoke permissions", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Permissions revoked"})
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wmfadel/wander-base/internal/models"
@@ -12,11 +16,15 @@ import (
 )
 
 type AuthHandler struct {
-	service *service.UserService
+	service             *service.UserService
+	tokenService        *service.TokenService
+	verificationService *service.VerificationService
+	passwordService     *service.PasswordService
+	loginGuard          *service.LoginGuardService
 }
 
-func NewAuthHandler(service *service.UserService) *AuthHandler {
-	return &AuthHandler{service: service}
+func NewAuthHandler(service *service.UserService, tokenService *service.TokenService, verificationService *service.VerificationService, passwordService *service.PasswordService, loginGuard *service.LoginGuardService) *AuthHandler {
+	return &AuthHandler{service: service, tokenService: tokenService, verificationService: verificationService, passwordService: passwordService, loginGuard: loginGuard}
 }
 
 func (h *AuthHandler) SignupHandler(context *gin.Context) {
@@ -33,8 +41,17 @@ func (h *AuthHandler) SignupHandler(context *gin.Context) {
 		return
 	}
 
+	// The user exists either way, a failed send can be retried after logging in
+	if err := h.verificationService.SendSignupCode(&user); err != nil {
+		log.Printf("Warning: failed to send verification code to user %d: %v", user.ID, err)
+		context.JSON(http.StatusCreated, gin.H{
+			"message": "User created, log in to request a verification code",
+		})
+		return
+	}
+
 	context.JSON(http.StatusCreated, gin.H{
-		"message": "User created",
+		"message": "User created, a verification code was sent to your phone",
 	})
 }
 
@@ -46,24 +63,178 @@ func (h *AuthHandler) LoginHandler(context *gin.Context) {
 		return
 	}
 
-	err = h.service.ValidateCredintials(&loginRequest)
+	challenge, err := h.loginGuard.Login(&loginRequest, context.ClientIP())
+	if loginFailed(context, err) {
+		return
+	}
+	if challenge != nil {
+		context.JSON(http.StatusOK, gin.H{
+			"message":             "Two-factor code required",
+			"two_factor_required": true,
+			"challenge_token":     challenge.ChallengeToken,
+			"expires_at":          challenge.ExpiresAt,
+		})
+		return
+	}
+
+	tokens, err := h.tokenService.IssueTokens(loginRequest.Phone, loginRequest.ID, false, utils.GetSessionDevice(context, loginRequest.Device))
 	if err != nil {
-		context.JSON(http.StatusUnauthorized, core.NewESError("Cannot verify identity", err))
+		context.JSON(http.StatusInternalServerError, core.NewESError("Cannot create token", err))
 		return
 	}
 
-	token, err := utils.GernerateToken(loginRequest.Phone, loginRequest.ID)
+	context.JSON(http.StatusOK, gin.H{
+		"message":       "User Validated",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
+	})
+}
+
+// LoginTwoFactorHandler completes a login that returned a challenge with a TOTP or recovery code.
+func (h *AuthHandler) LoginTwoFactorHandler(context *gin.Context) {
+	var request requests.TwoFactorLoginRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		context.JSON(http.StatusBadRequest, core.NewESError("Could not parse two-factor login", err))
+		return
+	}
+
+	userID, phone, err := h.loginGuard.LoginTwoFactor(request.ChallengeToken, request.Code, context.ClientIP())
+	if loginFailed(context, err) {
+		return
+	}
+
+	tokens, err := h.tokenService.IssueTokens(phone, userID, true, utils.GetSessionDevice(context, request.Device))
 	if err != nil {
 		context.JSON(http.StatusInternalServerError, core.NewESError("Cannot create token", err))
 		return
 	}
 
 	context.JSON(http.StatusOK, gin.H{
-		"message": "User Validated",
-		"token":   token,
+		"message":       "User Validated",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
+	})
+}
+
+// loginFailed answers failed logins the same way whatever was wrong, telling throttled clients when to retry.
+func loginFailed(context *gin.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	var throttled *service.ThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		context.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		context.JSON(http.StatusTooManyRequests, core.NewESError("Too many failed logins", err))
+	case errors.Is(err, core.ErrInvalidCredentials), errors.Is(err, service.ErrInvalidTwoFactorCode), errors.Is(err, service.ErrInvalidLoginChallenge):
+		context.JSON(http.StatusUnauthorized, core.NewESError("Cannot verify identity", err))
+	default:
+		context.JSON(http.StatusInternalServerError, core.NewESError("Cannot verify identity", err))
+	}
+	return true
+}
+
+func (h *AuthHandler) RefreshHandler(context *gin.Context) {
+	var refreshRequest requests.RefreshTokenRequest
+	err := context.ShouldBindJSON(&refreshRequest)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, core.NewESError("Could not parse refresh token", err))
+		return
+	}
+
+	tokens, err := h.tokenService.Refresh(refreshRequest.RefreshToken, utils.GetSessionDevice(context, ""))
+	if err != nil {
+		context.JSON(http.StatusUnauthorized, core.NewESError("Cannot refresh token", err))
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message":       "Token refreshed",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_at":    tokens.ExpiresAt,
 	})
 }
 
 func (h *AuthHandler) LogoutHandler(context *gin.Context) {
-	context.JSON(http.StatusNotExtended, core.NewESError("Not implemented", nil))
+	claims, err := utils.GetClaimsFromContext(context)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, core.NewESError("Failed to get token from context", err))
+		return
+	}
+
+	err = h.tokenService.Logout(claims)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, core.NewESError("Cannot logout", err))
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message": "Logged out",
+	})
+}
+
+func (h *AuthHandler) ChangePasswordHandler(context *gin.Context) {
+	var request requests.ChangePasswordRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		context.JSON(http.StatusBadRequest, core.NewESError("Could not parse password", err))
+		return
+	}
+	user, err := utils.GetUserFromContext(context)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+	claims, err := utils.GetClaimsFromContext(context)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, core.NewESError("Failed to get token from context", err))
+		return
+	}
+
+	err = h.passwordService.Change(user, request.CurrentPassword, request.NewPassword, claims.SessionID)
+	if err != nil {
+		context.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Cannot change password", err))
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message": "Password changed, other sessions were logged out",
+	})
+}
+
+// ForgotPasswordHandler answers the same whether the phone is signed up or not, failures are only logged.
+func (h *AuthHandler) ForgotPasswordHandler(context *gin.Context) {
+	var request requests.ForgotPasswordRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		context.JSON(http.StatusBadRequest, core.NewESError("Could not parse phone", err))
+		return
+	}
+
+	if err := h.passwordService.RequestReset(request.Phone); err != nil {
+		log.Printf("Warning: failed to send password reset code to %s: %v", request.Phone, err)
+	}
+
+	context.JSON(http.StatusAccepted, gin.H{
+		"message": "If the phone is signed up, a reset code was sent to it",
+	})
+}
+
+func (h *AuthHandler) ResetPasswordHandler(context *gin.Context) {
+	var request requests.ResetPasswordRequest
+	if err := context.ShouldBindJSON(&request); err != nil {
+		context.JSON(http.StatusBadRequest, core.NewESError("Could not parse password reset", err))
+		return
+	}
+
+	err := h.passwordService.Reset(request.Phone, request.Code, request.Password)
+	if err != nil {
+		context.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Cannot reset password", err))
+		return
+	}
+
+	context.JSON(http.StatusOK, gin.H{
+		"message": "Password reset, log in again on every device",
+	})
 }
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/service"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+const calendarContentType = "text/calendar; charset=utf-8"
+
+type CalendarHandler struct {
+	service      *service.CalendarService
+	eventService *service.EventService
+}
+
+func NewCalendarHandler(service *service.CalendarService, eventService *service.EventService) *CalendarHandler {
+	return &CalendarHandler{service: service, eventService: eventService}
+}
+
+// GetEventCalendar serves /events/:id.ics, the :id param still carries the .ics suffix.
+func (h *CalendarHandler) GetEventCalendar(c *gin.Context) {
+	eventID, err := strconv.ParseInt(strings.TrimSuffix(c.Param("id"), ".ics"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse event ID", err))
+		return
+	}
+
+	event, err := h.eventService.GetVisibleEvent(eventID, utils.GetViewerFromContext(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event data", err))
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, core.NewESError("Event not found", nil))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="event-%d.ics"`, event.ID))
+	c.Data(http.StatusOK, calendarContentType, h.service.EventCalendar(event))
+}
+
+// RotateFeedToken issues the calendar feed URL for the current user, replacing any previous one.
+func (h *CalendarHandler) RotateFeedToken(c *gin.Context) {
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	token, err := h.service.RotateFeedToken(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to create calendar token", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": token, "url": "/users/me/calendar.ics?token=" + token})
+}
+
+func (h *CalendarHandler) GetUserFeed(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, core.NewESError("Calendar token is required", nil))
+		return
+	}
+
+	feed, err := h.service.UserFeed(token)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to get calendar feed", err))
+		return
+	}
+	c.Data(http.StatusOK, calendarContentType, feed)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/wmfadel/wander-base/internal/models"
 	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
 	"github.com/wmfadel/wander-base/internal/service"
 	"github.com/wmfadel/wander-base/pkg/utils"
 )
@@ -39,19 +40,19 @@ func (h *CommentHandler) Create(c *gin.Context) {
 	}
 
 	comment := models.Comment{
-		EventID: eventId,
-		UserID:  user.ID,
-		Content: commentRequest.Content,
-		Score:   0,
-		Visible: true,
+		EventID:  eventId,
+		UserID:   user.ID,
+		ParentID: commentRequest.ParentID,
+		Content:  commentRequest.Content,
 	}
-	err = h.CommentService.Create(&comment)
+	err = h.CommentService.Create(&comment, user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to create comment", err))
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to create comment", err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Comment created"})
+	// The comment shows up for others once moderation lets it through
+	c.JSON(http.StatusAccepted, gin.H{"message": "Comment created", "comment": comment})
 }
 
 func (h *CommentHandler) GetEventComments(c *gin.Context) {
@@ -60,10 +61,180 @@ func (h *CommentHandler) GetEventComments(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse event ID", err))
 		return
 	}
-	comments, err := h.CommentService.GetEventComments(eventId)
+	var query requests.CommentQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Invalid comments query", err))
+		return
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+	page, err := h.CommentService.GetEventComments(eventId, user, query)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to get comments", err))
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// GetReplies pages through the replies of a thread, following the replies_cursor it was listed with.
+func (h *CommentHandler) GetReplies(c *gin.Context) {
+	commentId, user, ok := h.commentAndUser(c)
+	if !ok {
+		return
+	}
+	var query requests.CommentQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Invalid replies query", err))
+		return
+	}
+	page, err := h.CommentService.GetThreadReplies(commentId, user, query)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to get replies", err))
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *CommentHandler) GetComment(c *gin.Context) {
+	commentId, user, ok := h.commentAndUser(c)
+	if !ok {
+		return
+	}
+	comment, err := h.CommentService.GetCommentById(commentId, user)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to get comment", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"comment": comment})
+}
+
+func (h *CommentHandler) EditComment(c *gin.Context) {
+	commentId, user, ok := h.commentAndUser(c)
+	if !ok {
+		return
+	}
+	var request models.EditCommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse comment", err))
+		return
+	}
+
+	comment, err := h.CommentService.Edit(commentId, request.Content, user)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to edit comment", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Comment edited", "comment": comment})
+}
+
+func (h *CommentHandler) GetCommentHistory(c *gin.Context) {
+	commentId, user, ok := h.commentAndUser(c)
+	if !ok {
+		return
+	}
+	edits, err := h.CommentService.GetEdits(commentId, user)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to get comment history", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"edits": edits})
+}
+
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	commentId, user, ok := h.commentAndUser(c)
+	if !ok {
+		return
+	}
+	if err := h.CommentService.Delete(commentId, user); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to delete comment", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted"})
+}
+
+func (h *CommentHandler) React(c *gin.Context) {
+	h.reaction(c, h.CommentService.React, "Reaction added")
+}
+
+func (h *CommentHandler) Unreact(c *gin.Context) {
+	h.reaction(c, h.CommentService.Unreact, "Reaction removed")
+}
+
+func (h *CommentHandler) reaction(c *gin.Context, apply func(commentId int64, emoji string, user *models.User) error, message string) {
+	commentId, user, ok := h.commentAndUser(c)
+	if !ok {
+		return
+	}
+	var request models.ReactionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse reaction", err))
+		return
+	}
+
+	if err := apply(commentId, request.Emoji, user); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to update reactions", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// commentAndUser reads the comment in the :id param and the authenticated user, responding on failure.
+func (h *CommentHandler) commentAndUser(c *gin.Context) (int64, *models.User, bool) {
+	commentId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse comment ID", err))
+		return 0, nil, false
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return 0, nil, false
+	}
+	return commentId, user, true
+}
+
+// GetModerationQueue lists comments by status for the admins, pending ones by default.
+func (h *CommentHandler) GetModerationQueue(c *gin.Context) {
+	comments, err := h.CommentService.GetModerationQueue(models.CommentStatus(c.Query("status")))
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to get comments", err))
+		return
+	}
+	moderated := make([]models.ModeratedComment, 0, len(comments))
+	for _, comment := range comments {
+		moderated = append(moderated, models.NewModeratedComment(comment))
+	}
+	c.JSON(http.StatusOK, gin.H{"comments": moderated})
+}
+
+func (h *CommentHandler) ApproveComment(c *gin.Context) {
+	h.override(c, h.CommentService.Approve, "Comment approved")
+}
+
+func (h *CommentHandler) HideComment(c *gin.Context) {
+	h.override(c, h.CommentService.Hide, "Comment hidden")
+}
+
+// override applies an admin's decision to the comment in the :id param, replacing the moderator's.
+func (h *CommentHandler) override(c *gin.Context, apply func(commentId int64, reviewerId int64) (*models.Comment, error), message string) {
+	commentId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse comment ID", err))
+		return
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	comment, err := apply(commentId, user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get comments", err))
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to update comment", err))
 		return
 	}
-	c.JSON(http.StatusOK, comments)
+	c.JSON(http.StatusOK, gin.H{"message": message, "comment": models.NewModeratedComment(*comment)})
 }
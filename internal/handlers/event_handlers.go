@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/wmfadel/wander-base/internal/models/core"
 	"github.com/wmfadel/wander-base/internal/models/requests"
 	"github.com/wmfadel/wander-base/internal/service"
+	"github.com/wmfadel/wander-base/pkg/utils"
 )
 
 type EventHandler struct {
@@ -38,7 +41,7 @@ func (h *EventHandler) CreateEvent(c *gin.Context) {
 
 	// Create event with photos
 	if err := h.service.CreateEvent(&event); err != nil {
-		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to create event", err))
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to create event", err))
 		return
 	}
 
@@ -46,21 +49,25 @@ func (h *EventHandler) CreateEvent(c *gin.Context) {
 }
 
 func (h *EventHandler) SetEventDestinations(c *gin.Context) {
-	eventIDStr := c.Param("event_id")
-	eventID, err := strconv.ParseInt(eventIDStr, 10, 64)
+	event, err := utils.GetEventFromContext(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
 		return
 	}
 
 	var destinations []models.EventDestinationRequest
 	if err := c.ShouldBindJSON(&destinations); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.JSON(http.StatusBadRequest, core.NewESError("Invalid request body", err))
 		return
 	}
 
-	if err := h.service.SetDestinations(destinations, eventID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if len(destinations) == 0 {
+		c.JSON(http.StatusBadRequest, core.NewESError("destinations cannot be empty", nil))
+		return
+	}
+
+	if err := h.service.SetDestinations(destinations, event.ID); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to set event destinations", err))
 		return
 	}
 
@@ -68,29 +75,27 @@ func (h *EventHandler) SetEventDestinations(c *gin.Context) {
 }
 
 func (h *EventHandler) RemoveDestinations(c *gin.Context) {
-	// Extract event_id from URL
-	eventIDStr := c.Param("event_id")
-	eventID, err := strconv.ParseInt(eventIDStr, 10, 64)
+	event, err := utils.GetEventFromContext(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
 		return
 	}
 
 	// Parse request body
 	var req models.RemoveDestinationsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.JSON(http.StatusBadRequest, core.NewESError("Invalid request body", err))
 		return
 	}
 
 	if len(req.DestinationIDs) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "destination_ids cannot be empty"})
+		c.JSON(http.StatusBadRequest, core.NewESError("destination_ids cannot be empty", nil))
 		return
 	}
 
 	// Call service to remove destinations
-	if err := h.service.RemoveDestinations(req.DestinationIDs, eventID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.RemoveDestinations(req.DestinationIDs, event.ID); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to remove event destinations", err))
 		return
 	}
 
@@ -98,29 +103,27 @@ func (h *EventHandler) RemoveDestinations(c *gin.Context) {
 }
 
 func (h *EventHandler) AddActivities(c *gin.Context) {
-	// Extract event_id from URL
-	eventIDStr := c.Param("event_id")
-	eventID, err := strconv.ParseInt(eventIDStr, 10, 64)
+	event, err := utils.GetEventFromContext(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
 		return
 	}
 
-	// Parse request body (just a list of activity IDs)
-	var activityIDs []int64
-	if err := c.ShouldBindJSON(&activityIDs); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+	// Parse request body
+	var req models.AddActivitiesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Invalid request body", err))
 		return
 	}
 
-	if len(activityIDs) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "activity_ids cannot be empty"})
+	if len(req.ActivityIDs) == 0 {
+		c.JSON(http.StatusBadRequest, core.NewESError("activity_ids cannot be empty", nil))
 		return
 	}
 
 	// Call service to add activities
-	if err := h.service.AddActivities(activityIDs, eventID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.AddActivities(req.ActivityIDs, event.ID); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to add event activities", err))
 		return
 	}
 
@@ -128,29 +131,27 @@ func (h *EventHandler) AddActivities(c *gin.Context) {
 }
 
 func (h *EventHandler) RemoveActivities(c *gin.Context) {
-	// Extract event_id from URL
-	eventIDStr := c.Param("event_id")
-	eventID, err := strconv.ParseInt(eventIDStr, 10, 64)
+	event, err := utils.GetEventFromContext(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
 		return
 	}
 
 	// Parse request body
 	var req models.RemoveActivitiesRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		c.JSON(http.StatusBadRequest, core.NewESError("Invalid request body", err))
 		return
 	}
 
 	if len(req.ActivityIDs) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "activity_ids cannot be empty"})
+		c.JSON(http.StatusBadRequest, core.NewESError("activity_ids cannot be empty", nil))
 		return
 	}
 
 	// Call service to remove activities
-	if err := h.service.RemoveActivities(req.ActivityIDs, eventID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.RemoveActivities(req.ActivityIDs, event.ID); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to remove event activities", err))
 		return
 	}
 
@@ -163,27 +164,37 @@ func (h *EventHandler) GetEvent(context *gin.Context) {
 		context.JSON(http.StatusBadRequest, core.NewESError("Failed to parse event ID", err))
 		return
 	}
-	event, err := h.service.GetEventById(eventID)
+	event, err := h.service.GetVisibleEvent(eventID, utils.GetViewerFromContext(context))
 	if err != nil {
 		context.JSON(http.StatusNotFound, core.NewESError("Failed to get event data", err))
 		return
 	}
+	if event == nil {
+		context.JSON(http.StatusNotFound, core.NewESError("Event not found", nil))
+		return
+	}
 	context.JSON(http.StatusOK, event)
 }
 
 func (h *EventHandler) GetEvents(context *gin.Context) {
-	events, err := h.service.GetAllEvents()
+	var query requests.EventQuery
+	if err := context.ShouldBindQuery(&query); err != nil {
+		context.JSON(http.StatusBadRequest, core.NewESError("Invalid events query", err))
+		return
+	}
+
+	page, err := h.service.GetAllEvents(query, utils.GetViewerFromContext(context))
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, core.NewESError("Failed to get events data", err))
+		context.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to get events data", err))
 		return
 	}
-	context.JSON(http.StatusOK, events)
+	context.JSON(http.StatusOK, page)
 }
 
 func (h *EventHandler) UpdateEvent(context *gin.Context) {
-	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	event, err := utils.GetEventFromContext(context)
 	if err != nil {
-		context.JSON(http.StatusBadRequest, core.NewESError("Failed to parse event ID", err))
+		context.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
 		return
 	}
 
@@ -195,9 +206,10 @@ func (h *EventHandler) UpdateEvent(context *gin.Context) {
 		return
 	}
 
-	err = h.service.UpdatePartially(eventId, patchEvent)
+	// scope only matters for occurrences of recurring events: "this" (default) or "following"
+	err = h.service.UpdatePartially(event, patchEvent, context.Query("scope"))
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, core.NewESError("Failed to update event", err))
+		context.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to update event", err))
 		return
 	}
 
@@ -205,11 +217,39 @@ func (h *EventHandler) UpdateEvent(context *gin.Context) {
 }
 
 func (h *EventHandler) AddPhotos(c *gin.Context) {
+	event, err := utils.GetEventFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+	h.savePhotos(c, event)
+}
+
+// UploadPhotos lets photographers upload photos to events they attend, their photos wait for the organizers' review.
+func (h *EventHandler) UploadPhotos(c *gin.Context) {
 	eventID, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse event ID", err))
 		return
 	}
+	event, err := h.service.GetVisibleEvent(eventID, utils.GetViewerFromContext(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event data", err))
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, core.NewESError("Event not found", nil))
+		return
+	}
+	h.savePhotos(c, event)
+}
+
+func (h *EventHandler) savePhotos(c *gin.Context, event *models.Event) {
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
 
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -218,17 +258,113 @@ func (h *EventHandler) AddPhotos(c *gin.Context) {
 	}
 	photos := form.File["photos"]
 
-	if err := h.photoService.AddPhotos(eventID, photos); err != nil {
-		c.JSON(http.StatusBadRequest, core.NewESError("Failed to save event photos", err))
+	status, err := h.photoService.AddPhotos(event, user, photos)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusBadRequest), core.NewESError("Failed to save event photos", err))
+		return
+	}
+	if status == models.PhotoPending {
+		c.JSON(http.StatusAccepted, gin.H{"message": "Photos submitted for review"})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Photos added"})
 }
 
+// GetPhotos lists the event's photos for its managers, pending ones included.
+func (h *EventHandler) GetPhotos(c *gin.Context) {
+	event, err := utils.GetEventFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+
+	status := models.PhotoStatus(c.Query("status"))
+	photos, err := h.photoService.GetPhotos(event.ID, status)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to get photos", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"photos": photos})
+}
+
+// DownloadPhotoArchive streams a ZIP of the event's approved photos to its attendees and managers.
+func (h *EventHandler) DownloadPhotoArchive(c *gin.Context) {
+	eventID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse event ID", err))
+		return
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	// Attendees keep their photos once the event completed, so access is up to CanDownloadArchive alone
+	event, err := h.service.GetEventById(eventID)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to get event data", err))
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, core.NewESError("Event not found", nil))
+		return
+	}
+	if err := h.photoService.CanDownloadArchive(event, user); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to download photos", err))
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="event-%d-photos.zip"`, event.ID))
+	c.Status(http.StatusOK)
+	if err := h.photoService.WriteArchive(c.Writer, event.ID); err != nil {
+		// The response has started, the client is left with a truncated archive
+		log.Printf("Failed to stream the photo archive of event %d: %v", event.ID, err)
+	}
+}
+
+func (h *EventHandler) ApprovePhotos(c *gin.Context) {
+	h.reviewPhotos(c, h.photoService.ApprovePhotos, "Photos approved")
+}
+
+func (h *EventHandler) RejectPhotos(c *gin.Context) {
+	h.reviewPhotos(c, h.photoService.RejectPhotos, "Photos rejected")
+}
+
+type photoReviewFunc func(eventId int64, photoIds []int64, review models.PhotoReview) error
+
+// reviewPhotos applies an organizer review to all pending photos listed in the request body.
+func (h *EventHandler) reviewPhotos(c *gin.Context, apply photoReviewFunc, message string) {
+	event, err := utils.GetEventFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	var reviewRequest requests.PhotoReviewRequest
+	if err := c.ShouldBindJSON(&reviewRequest); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse review", err))
+		return
+	}
+
+	review := models.PhotoReview{ReviewedByID: user.ID, Reason: reviewRequest.Reason}
+	if err := apply(event.ID, reviewRequest.PhotoIDs, review); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to apply review", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
 func (h *EventHandler) DeletePhotos(c *gin.Context) {
-	eventId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	event, err := utils.GetEventFromContext(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse photo ID", err))
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
 		return
 	}
 	type TempPhotos struct {
@@ -243,35 +379,248 @@ func (h *EventHandler) DeletePhotos(c *gin.Context) {
 	}
 	var photos []string
 	photos = append(photos, p.Photos...)
-	err = h.photoService.DeletEventPhotos(eventId, photos)
+	err = h.photoService.DeletePhotosByURL(event.ID, photos)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to delete photo", err))
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to delete photo", err))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Photo deleted"})
 
 }
 
-func (h *EventHandler) DeleteEvent(context *gin.Context) {
+// DeletePhoto deletes the photo in the :photo_id param, or all photos listed in the request body when there is no param.
+func (h *EventHandler) DeletePhoto(c *gin.Context) {
+	event, err := utils.GetEventFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+
+	var photoIds []int64
+	if param := c.Param("photo_id"); param != "" {
+		photoId, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse photo ID", err))
+			return
+		}
+		photoIds = []int64{photoId}
+	} else {
+		var request requests.PhotoIDsRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, core.NewESError("Missing photo details", err))
+			return
+		}
+		photoIds = request.PhotoIDs
+	}
+
+	if err := h.photoService.DeletePhotos(event.ID, photoIds); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to delete photos", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Photos deleted"})
+}
+
+func (h *EventHandler) UpdatePhoto(c *gin.Context) {
+	event, err := utils.GetEventFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+	photoId, err := strconv.ParseInt(c.Param("photo_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse photo ID", err))
+		return
+	}
+
+	var patch requests.PatchPhotoRequest
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Could not parse photo", err))
+		return
+	}
+
+	photo, err := h.photoService.UpdatePhoto(event.ID, photoId, patch)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to update photo", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"photo": photo})
+}
+
+func (h *EventHandler) ReorderPhotos(c *gin.Context) {
+	event, err := utils.GetEventFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+
+	var request requests.PhotoIDsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Could not parse photo order", err))
+		return
+	}
+
+	if err := h.photoService.ReorderPhotos(event.ID, request.PhotoIDs); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to reorder photos", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Photos reordered"})
+}
+
+func (h *EventHandler) SetCoverPhoto(c *gin.Context) {
+	event, err := utils.GetEventFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+	photoId, err := strconv.ParseInt(c.Param("photo_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse photo ID", err))
+		return
+	}
 
-	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err := h.photoService.SetCover(event.ID, photoId); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to set cover photo", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Cover photo set"})
+}
 
+func (h *EventHandler) ClearCoverPhoto(c *gin.Context) {
+	event, err := utils.GetEventFromContext(c)
 	if err != nil {
-		context.AbortWithStatusJSON(http.StatusBadRequest, core.NewESError("Failed to parse event ID", err))
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+
+	if err := h.photoService.ClearCover(event.ID); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to clear cover photo", err))
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "Cover photo cleared"})
+}
 
-	event, err := h.service.GetEventById(eventId)
+func (h *EventHandler) DeleteEvent(context *gin.Context) {
+	event, err := utils.GetEventFromContext(context)
 	if err != nil {
-		context.AbortWithStatusJSON(http.StatusBadRequest, core.NewESError("Could not find event", err))
+		context.AbortWithStatusJSON(http.StatusInternalServerError, core.NewESError("Could not find event", err))
 		return
 	}
 
-	err = h.service.Delete(event.ID, event.PhotosUrls())
+	err = h.service.Delete(event, context.Query("scope"))
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, core.NewESError("Failed to delete event", err))
+		context.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to delete event", err))
 		return
 	}
 
 	context.JSON(http.StatusOK, gin.H{"message": "Event deleted"})
 }
+
+func (h *EventHandler) AddCoHost(c *gin.Context) {
+	event, user, ok := h.eventOwner(c)
+	if !ok {
+		return
+	}
+
+	var coHostRequest requests.CoHostRequest
+	if err := c.ShouldBindJSON(&coHostRequest); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse co-host", err))
+		return
+	}
+	if coHostRequest.UserID == user.ID {
+		c.JSON(http.StatusBadRequest, core.NewESError("Event creator can't be a co-host", nil))
+		return
+	}
+
+	err := h.service.AddCoHost(event.ID, coHostRequest.UserID)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to add co-host", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Co-host added"})
+}
+
+func (h *EventHandler) RemoveCoHost(c *gin.Context) {
+	event, _, ok := h.eventOwner(c)
+	if !ok {
+		return
+	}
+
+	userId, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse user ID", err))
+		return
+	}
+
+	err = h.service.RemoveCoHost(event.ID, userId)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to remove co-host", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Co-host removed"})
+}
+
+func (h *EventHandler) PublishEvent(c *gin.Context) {
+	h.transition(c, h.service.Publish, "Event published")
+}
+
+func (h *EventHandler) UnpublishEvent(c *gin.Context) {
+	h.transition(c, h.service.Unpublish, "Event unpublished")
+}
+
+func (h *EventHandler) CompleteEvent(c *gin.Context) {
+	h.transition(c, h.service.Complete, "Event completed")
+}
+
+func (h *EventHandler) CancelEvent(c *gin.Context) {
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	var cancelRequest requests.CancelEventRequest
+	if err := c.ShouldBindJSON(&cancelRequest); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("A cancellation reason is required", err))
+		return
+	}
+
+	h.transition(c, func(eventId int64) (*models.Event, error) {
+		return h.service.Cancel(eventId, models.RegistrationDecision{DecidedByID: user.ID, Reason: cancelRequest.Reason})
+	}, "Event cancelled")
+}
+
+// transition applies a lifecycle change to the event in context and responds with its new status.
+func (h *EventHandler) transition(c *gin.Context, apply func(eventId int64) (*models.Event, error), message string) {
+	event, err := utils.GetEventFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+
+	event, err = apply(event.ID)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to change event status", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "status": event.Status})
+}
+
+// eventOwner returns the event loaded by the event manager middleware, but only for its creator
+// or admins; co-hosts can't change who else manages the event.
+func (h *EventHandler) eventOwner(c *gin.Context) (*models.Event, *models.User, bool) {
+	event, err := utils.GetEventFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return nil, nil, false
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return nil, nil, false
+	}
+	if event.UserID != user.ID && !user.HasPermission(models.PermissionEventsEditAny) {
+		c.JSON(http.StatusForbidden, core.NewESError("Only the event creator can manage co-hosts", nil))
+		return nil, nil, false
+	}
+	return event, user, true
+}
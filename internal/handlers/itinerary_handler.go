@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
+	"github.com/wmfadel/wander-base/internal/service"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+type ItineraryHandler struct {
+	service      *service.ItineraryService
+	eventService *service.EventService
+}
+
+func NewItineraryHandler(service *service.ItineraryService, eventService *service.EventService) *ItineraryHandler {
+	return &ItineraryHandler{service: service, eventService: eventService}
+}
+
+func (h *ItineraryHandler) GetItinerary(c *gin.Context) {
+	eventID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse event ID", err))
+		return
+	}
+
+	event, err := h.eventService.GetVisibleEvent(eventID, utils.GetViewerFromContext(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event data", err))
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, core.NewESError("Event not found", nil))
+		return
+	}
+
+	itinerary, err := h.service.GetItinerary(event)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get itinerary", err))
+		return
+	}
+	c.JSON(http.StatusOK, itinerary)
+}
+
+func (h *ItineraryHandler) SetItinerary(c *gin.Context) {
+	event, err := utils.GetEventFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+
+	var itineraryRequest requests.ItineraryRequest
+	if err := c.ShouldBindJSON(&itineraryRequest); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Invalid itinerary", err))
+		return
+	}
+
+	itinerary, err := h.service.SetItinerary(event, itineraryRequest)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to set itinerary", err))
+		return
+	}
+	c.JSON(http.StatusOK, itinerary)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
+	"github.com/wmfadel/wander-base/internal/service"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+type PhotoAlbumHandler struct {
+	photoService *service.EventPhotoService
+	eventService *service.EventService
+}
+
+func NewPhotoAlbumHandler(photoService *service.EventPhotoService, eventService *service.EventService) *PhotoAlbumHandler {
+	return &PhotoAlbumHandler{photoService: photoService, eventService: eventService}
+}
+
+func (h *PhotoAlbumHandler) GetAlbums(c *gin.Context) {
+	eventID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse event ID", err))
+		return
+	}
+
+	event, err := h.eventService.GetVisibleEvent(eventID, utils.GetViewerFromContext(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event data", err))
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, core.NewESError("Event not found", nil))
+		return
+	}
+
+	albums, err := h.photoService.GetAlbums(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get albums", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"albums": albums})
+}
+
+func (h *PhotoAlbumHandler) CreateAlbum(c *gin.Context) {
+	event, err := utils.GetEventFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+
+	var request requests.AlbumRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Could not parse album", err))
+		return
+	}
+
+	album, err := h.photoService.CreateAlbum(event.ID, request)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to create album", err))
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"album": album})
+}
+
+func (h *PhotoAlbumHandler) UpdateAlbum(c *gin.Context) {
+	event, err := utils.GetEventFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+	albumID, err := strconv.ParseInt(c.Param("album_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse album ID", err))
+		return
+	}
+
+	var request requests.AlbumRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Could not parse album", err))
+		return
+	}
+
+	album, err := h.photoService.UpdateAlbum(event.ID, albumID, request)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to update album", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"album": album})
+}
+
+func (h *PhotoAlbumHandler) DeleteAlbum(c *gin.Context) {
+	event, err := utils.GetEventFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+	albumID, err := strconv.ParseInt(c.Param("album_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse album ID", err))
+		return
+	}
+
+	if err := h.photoService.DeleteAlbum(event.ID, albumID); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to delete album", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Album deleted"})
+}
@@ -58,10 +58,10 @@ func (h *ProfileHandler) UpdatePhoto(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, core.NewESError("photo required", err))
 		return
 	}
-	url, err := h.UserService.UpdatePhoto(userId, photo)
+	variants, err := h.UserService.UpdatePhoto(userId, photo)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to update photo", err))
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to update photo", err))
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "Photo updated", "url": url})
+	c.JSON(http.StatusOK, gin.H{"message": "Photo updated", "url": variants.Original.URL, "variants": variants})
 }
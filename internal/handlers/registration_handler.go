@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wmfadel/wander-base/internal/models"
 	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
 	"github.com/wmfadel/wander-base/internal/service"
+	"github.com/wmfadel/wander-base/pkg/utils"
 )
 
 type RegistrationHandler struct {
@@ -26,14 +30,19 @@ func (h *RegistrationHandler) RegisterForEvent(context *gin.Context) {
 		return
 	}
 
-	err = h.service.Register(userId, eventId)
+	status, err := h.service.Register(userId, eventId)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, core.NewESError("Cannot register for event", err))
+		context.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Cannot register for event", err))
 		return
 	}
 
+	message := "Requested to register for event, please wait for approval"
+	if status == models.Waitlisted {
+		message = "Event is full, you were added to the waitlist"
+	}
 	context.JSON(http.StatusCreated, gin.H{
-		"message": "Requested to register for event, please wait for approval",
+		"message": message,
+		"status":  status,
 	})
 }
 
@@ -48,7 +57,7 @@ func (h *RegistrationHandler) CancelRegistrationEvent(context *gin.Context) {
 	event := models.Event{ID: eventId}
 	err = h.service.CancelRegister(userId, event.ID)
 	if err != nil {
-		context.JSON(http.StatusInternalServerError, core.NewESError("Cancelling registration failed", err))
+		context.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Cancelling registration failed", err))
 		return
 	}
 
@@ -56,3 +65,75 @@ func (h *RegistrationHandler) CancelRegistrationEvent(context *gin.Context) {
 		"message": "Unregistration Successful",
 	})
 }
+
+func (h *RegistrationHandler) GetEventRegistrations(context *gin.Context) {
+	event, err := utils.GetEventFromContext(context)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+
+	status := models.RegistrationStatus(context.Query("status"))
+	registrations, err := h.service.GetRegistrations(event.ID, status)
+	if err != nil {
+		context.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to get registrations", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"registrations": registrations})
+}
+
+func (h *RegistrationHandler) ApproveRegistrations(context *gin.Context) {
+	h.decide(context, h.service.ApproveRegistration, "Registrations approved")
+}
+
+func (h *RegistrationHandler) RejectRegistrations(context *gin.Context) {
+	h.decide(context, h.service.RejectRegistration, "Registrations rejected")
+}
+
+func (h *RegistrationHandler) ApproveCancellations(context *gin.Context) {
+	h.decide(context, h.service.ApproveCancelRegister, "Cancellations approved")
+}
+
+func (h *RegistrationHandler) RejectCancellations(context *gin.Context) {
+	h.decide(context, h.service.RejectCancelRegister, "Cancellations rejected")
+}
+
+type decisionFunc func(eventId int64, userIds []int64, decision models.RegistrationDecision) error
+
+// decide applies an organizer decision to the user in the :user_id param,
+// or to all users listed in the request body when there is no param.
+func (h *RegistrationHandler) decide(context *gin.Context, apply decisionFunc, message string) {
+	event, err := utils.GetEventFromContext(context)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, core.NewESError("Failed to get event from context", err))
+		return
+	}
+	user, err := utils.GetUserFromContext(context)
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	var decisionRequest requests.RegistrationDecisionRequest
+	if err := context.ShouldBindJSON(&decisionRequest); err != nil && !errors.Is(err, io.EOF) {
+		context.JSON(http.StatusBadRequest, core.NewESError("Failed to parse decision", err))
+		return
+	}
+
+	userIds := decisionRequest.UserIDs
+	if param := context.Param("user_id"); param != "" {
+		userId, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, core.NewESError("Failed to parse user ID", err))
+			return
+		}
+		userIds = []int64{userId}
+	}
+
+	decision := models.RegistrationDecision{DecidedByID: user.ID, Reason: decisionRequest.Reason}
+	if err := apply(event.ID, userIds, decision); err != nil {
+		context.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to apply decision", err))
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"message": message})
+}
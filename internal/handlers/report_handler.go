@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
+	"github.com/wmfadel/wander-base/internal/service"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+type ReportHandler struct {
+	ReportService *service.ReportService
+}
+
+func NewReportHandler(reportService *service.ReportService) *ReportHandler {
+	return &ReportHandler{ReportService: reportService}
+}
+
+func (h *ReportHandler) Report(c *gin.Context) {
+	var request requests.ReportRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse report", err))
+		return
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	report, hidden, err := h.ReportService.Report(user, request)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to report", err))
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Report received", "report": report, "hidden": hidden})
+}
+
+// GetQueue lists the reported items that still have open reports, optionally of one ?target_type.
+func (h *ReportHandler) GetQueue(c *gin.Context) {
+	items, err := h.ReportService.GetQueue(models.ReportTarget(c.Query("target_type")))
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to get reports", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+func (h *ReportHandler) GetDetails(c *gin.Context) {
+	targetType, targetID, ok := reportTarget(c)
+	if !ok {
+		return
+	}
+	details, err := h.ReportService.GetDetails(targetType, targetID)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to get reports", err))
+		return
+	}
+	c.JSON(http.StatusOK, details)
+}
+
+func (h *ReportHandler) GetActions(c *gin.Context) {
+	actions, err := h.ReportService.GetActions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get moderation log", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"actions": actions})
+}
+
+func (h *ReportHandler) Dismiss(c *gin.Context) {
+	h.act(c, models.ActionDismiss, "Reports dismissed")
+}
+
+func (h *ReportHandler) Remove(c *gin.Context) {
+	h.act(c, models.ActionRemove, "Content removed")
+}
+
+func (h *ReportHandler) Block(c *gin.Context) {
+	h.act(c, models.ActionBlock, "User blocked")
+}
+
+func (h *ReportHandler) act(c *gin.Context, action models.ModerationActionType, message string) {
+	targetType, targetID, ok := reportTarget(c)
+	if !ok {
+		return
+	}
+	// The note is optional, so is the body
+	var request requests.ReportActionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse note", err))
+			return
+		}
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	logged, err := h.ReportService.Act(targetType, targetID, action, request.Note, user)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to resolve reports", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "action": logged})
+}
+
+// reportTarget reads the :target_type and :target_id params, responding on failure.
+func reportTarget(c *gin.Context) (models.ReportTarget, int64, bool) {
+	targetID, err := strconv.ParseInt(c.Param("target_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse target ID", err))
+		return "", 0, false
+	}
+	return models.ReportTarget(c.Param("target_type")), targetID, true
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/service"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+type SecurityHandler struct {
+	LoginGuard       *service.LoginGuardService
+	TwoFactorService *service.TwoFactorService
+}
+
+func NewSecurityHandler(loginGuard *service.LoginGuardService, twoFactorService *service.TwoFactorService) *SecurityHandler {
+	return &SecurityHandler{LoginGuard: loginGuard, TwoFactorService: twoFactorService}
+}
+
+func (h *SecurityHandler) GetLockouts(c *gin.Context) {
+	lockouts, err := h.LoginGuard.GetLockouts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get lockouts", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lockouts": lockouts})
+}
+
+func (h *SecurityHandler) UnlockUser(c *gin.Context) {
+	userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse user ID", err))
+		return
+	}
+	admin, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	if err := h.LoginGuard.UnlockUser(userId, admin); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to unlock user", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+// Unlock lifts the lockout of a throttle key as listed by GetLockouts, e.g. ip:203.0.113.7.
+func (h *SecurityHandler) Unlock(c *gin.Context) {
+	admin, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	if err := h.LoginGuard.Unlock(c.Param("key"), admin); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to unlock", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Unlocked"})
+}
+
+// ResetTwoFactor removes the two-factor of a user who lost their authenticator and recovery codes.
+func (h *SecurityHandler) ResetTwoFactor(c *gin.Context) {
+	userId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse user ID", err))
+		return
+	}
+	admin, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	if err := h.TwoFactorService.Reset(userId, admin); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to reset two-factor", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor reset"})
+}
+
+// GetSecurityEvents lists the latest security events, only the ones of ?user_id when given.
+func (h *SecurityHandler) GetSecurityEvents(c *gin.Context) {
+	var userId int64
+	if param := c.Query("user_id"); param != "" {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, core.NewESError("Failed to parse user ID", err))
+			return
+		}
+		userId = id
+	}
+
+	events, err := h.LoginGuard.GetSecurityEvents(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get security events", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/service"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+type SessionHandler struct {
+	TokenService *service.TokenService
+}
+
+func NewSessionHandler(tokenService *service.TokenService) *SessionHandler {
+	return &SessionHandler{TokenService: tokenService}
+}
+
+// GetSessions lists the devices the user is logged in on, marking the one asking.
+func (h *SessionHandler) GetSessions(c *gin.Context) {
+	claims, err := utils.GetClaimsFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get token from context", err))
+		return
+	}
+
+	sessions, err := h.TokenService.GetSessions(claims.UserID, claims.SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get sessions", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeSession logs one of the user's devices out, the current one included.
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	claims, err := utils.GetClaimsFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get token from context", err))
+		return
+	}
+
+	if err := h.TokenService.RevokeSession(claims.UserID, c.Param("id")); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to revoke session", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeOtherSessions logs the user out everywhere but the device asking.
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	claims, err := utils.GetClaimsFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get token from context", err))
+		return
+	}
+
+	if err := h.TokenService.RevokeOtherSessions(claims.UserID, claims.SessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to revoke sessions", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked"})
+}
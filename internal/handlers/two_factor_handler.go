@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
+	"github.com/wmfadel/wander-base/internal/service"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+type TwoFactorHandler struct {
+	TwoFactorService    *service.TwoFactorService
+	TokenService        *service.TokenService
+	LoginGuard          *service.LoginGuardService
+	VerificationService *service.VerificationService
+}
+
+func NewTwoFactorHandler(twoFactorService *service.TwoFactorService, tokenService *service.TokenService, loginGuard *service.LoginGuardService, verificationService *service.VerificationService) *TwoFactorHandler {
+	return &TwoFactorHandler{TwoFactorService: twoFactorService, TokenService: tokenService, LoginGuard: loginGuard, VerificationService: verificationService}
+}
+
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	status, err := h.TwoFactorService.Status(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get two-factor status", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"two_factor": status})
+}
+
+// SendEnrollmentCode texts the code users whose role requires two-factor need to enroll.
+func (h *TwoFactorHandler) SendEnrollmentCode(c *gin.Context) {
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	if err := h.VerificationService.SendTwoFactorCode(user); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to send verification code", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent", "expires_in": int(service.VerificationCodeTTL.Seconds())})
+}
+
+// Enroll returns a new authenticator secret and its otpauth:// URI, it is enabled once Confirm gets a code from it.
+func (h *TwoFactorHandler) Enroll(c *gin.Context) {
+	var request requests.EnrollTwoFactorRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Invalid request body", err))
+		return
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	secret, uri, err := h.TwoFactorService.Enroll(user, request.Password, request.Code)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to enroll two-factor", err))
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Scan the URI and confirm a code to enable two-factor", "secret": secret, "otpauth_uri": uri})
+}
+
+// Confirm enables two-factor and returns the recovery codes. The session is swapped for one that passed
+// two-factor, so the caller has to use the returned tokens from then on.
+func (h *TwoFactorHandler) Confirm(c *gin.Context) {
+	var request requests.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Invalid request body", err))
+		return
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+	claims, err := utils.GetClaimsFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get token from context", err))
+		return
+	}
+
+	codes, err := h.TwoFactorService.Confirm(user, request.Code)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to enable two-factor", err))
+		return
+	}
+
+	// The new session takes over the label of the one it replaces
+	var label string
+	if session, err := h.TokenService.GetSession(claims.SessionID); err == nil && session != nil {
+		label = session.DeviceLabel
+	}
+	tokens, err := h.TokenService.IssueTokens(user.Phone, user.ID, true, utils.GetSessionDevice(c, label))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Two-factor enabled but failed to create token, log in again", err))
+		return
+	}
+	if err := h.TokenService.Logout(claims); err != nil {
+		log.Printf("Warning: failed to end session %s of user %d: %v", claims.SessionID, user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor enabled, keep the recovery codes somewhere safe",
+		"recovery_codes": codes,
+		"token":          tokens.AccessToken,
+		"refresh_token":  tokens.RefreshToken,
+		"expires_at":     tokens.ExpiresAt,
+	})
+}
+
+// Disable turns two-factor off, wrong codes count as failed logins like they do when logging in.
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var request requests.DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Invalid request body", err))
+		return
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	err = h.LoginGuard.Guard(user.Phone, c.ClientIP(), func() error {
+		return h.TwoFactorService.Disable(user, request.Password, request.Code)
+	})
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to disable two-factor", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes, the old ones stop working.
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var request requests.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Invalid request body", err))
+		return
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	var codes []string
+	err = h.LoginGuard.Guard(user.Phone, c.ClientIP(), func() error {
+		codes, err = h.TwoFactorService.RegenerateRecoveryCodes(user, request.Code)
+		return err
+	})
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to regenerate recovery codes", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Recovery codes replaced", "recovery_codes": codes})
+}
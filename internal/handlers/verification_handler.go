@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
+	"github.com/wmfadel/wander-base/internal/service"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+type VerificationHandler struct {
+	VerificationService *service.VerificationService
+}
+
+func NewVerificationHandler(verificationService *service.VerificationService) *VerificationHandler {
+	return &VerificationHandler{VerificationService: verificationService}
+}
+
+// SendCode sends a new code to the phone the user signed up with.
+func (h *VerificationHandler) SendCode(c *gin.Context) {
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	if err := h.VerificationService.SendSignupCode(user); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to send verification code", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent", "expires_in": int(service.VerificationCodeTTL.Seconds())})
+}
+
+// ChangePhone sends a code to the new phone, the change is applied once it is confirmed with VerifyPhone.
+func (h *VerificationHandler) ChangePhone(c *gin.Context) {
+	var request requests.ChangePhoneRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Invalid request body", err))
+		return
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	if err := h.VerificationService.StartPhoneChange(user, request.Phone, request.Password); err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to change phone", err))
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification code sent to the new phone", "expires_in": int(service.VerificationCodeTTL.Seconds())})
+}
+
+func (h *VerificationHandler) VerifyPhone(c *gin.Context) {
+	var request requests.VerifyPhoneRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, core.NewESError("Invalid request body", err))
+		return
+	}
+	user, err := utils.GetUserFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, core.NewESError("Failed to get user from context", err))
+		return
+	}
+
+	phone, err := h.VerificationService.Confirm(user, request.Code)
+	if err != nil {
+		c.JSON(core.StatusFor(err, http.StatusInternalServerError), core.NewESError("Failed to verify phone", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Phone verified", "phone": phone})
+}
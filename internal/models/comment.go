@@ -2,17 +2,92 @@ package models
 
 import "time"
 
+// CommentStatus is set by moderation. New comments are pending until a moderator scores them,
+// admins can override the outcome either way.
+type CommentStatus string
+
+const (
+	CommentPending CommentStatus = "pending"
+	CommentVisible CommentStatus = "visible"
+	CommentHidden  CommentStatus = "hidden"
+)
+
+func (s CommentStatus) Valid() bool {
+	switch s {
+	case CommentPending, CommentVisible, CommentHidden:
+		return true
+	}
+	return false
+}
+
 type Comment struct {
-	ID        int64   `gorm:"primaryKey" json:"comment_id"`
-	EventID   int64   `gorm:"index" json:"event_id"`
-	UserID    int64   `gorm:"index" json:"user_id"`
-	Content   string  `gorm:"not null" json:"content"`
-	Score     float32 `gorm:"not null" json:"score"`
-	Visible   bool    `gorm:"not null" json:"visible"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID                 int64            `gorm:"primaryKey" json:"comment_id"`
+	EventID            int64            `gorm:"index" json:"event_id"`
+	UserID             int64            `gorm:"index" json:"-"`                   // shown as the author
+	ParentID           *int64           `gorm:"index" json:"parent_id,omitempty"` // the comment replied to
+	RootID             *int64           `gorm:"index" json:"-"`                   // the thread's top-level comment, to load whole threads at once
+	Content            string           `gorm:"not null" json:"content"`
+	Edited             bool             `gorm:"not null;default:false" json:"edited"`
+	EditedAt           *time.Time       `json:"edited_at,omitempty"`
+	DeletedAt          *time.Time       `gorm:"index" json:"deleted_at,omitempty"` // deleted comments stay as placeholders in their thread
+	DeletedByID        *int64           `json:"deleted_by,omitempty"`
+	Score              float32          `gorm:"not null" json:"score"`                     // the highest of the scores
+	Scores             ModerationScores `gorm:"type:jsonb;not null;default:'{}'" json:"-"` // per category, only shown to moderators
+	Visible            bool             `gorm:"not null" json:"visible"`                   // kept in sync with the status
+	Status             CommentStatus    `gorm:"type:comment_status;not null;default:visible;index" json:"status"`
+	ModerationAttempts int              `gorm:"not null;default:0" json:"-"` // failed attempts, retried with backoff
+	ModerationError    string           `json:"-"`
+	NextModerationAt   *time.Time       `gorm:"index" json:"-"`
+	ModeratedAt        *time.Time       `json:"moderated_at,omitempty"`
+	ReviewedByID       *int64           `json:"reviewed_by,omitempty"` // admin who overrode the moderator
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	Author             *UserSummary    `gorm:"foreignKey:UserID;-:migration" json:"author,omitempty"`
+	Replies            []Comment       `gorm:"-" json:"replies,omitempty"`
+	RepliesCursor      string          `gorm:"-" json:"replies_cursor,omitempty"` // set on threads with more replies than listed
+	Reactions          []ReactionCount `gorm:"-" json:"reactions,omitempty"`
+}
+
+// ModeratedComment is a comment as the admins moderating it see it, with what the moderators said.
+type ModeratedComment struct {
+	Comment
+	Scores             ModerationScores `json:"scores"`
+	ModerationAttempts int              `json:"moderation_attempts,omitempty"`
+	ModerationError    string           `json:"moderation_error,omitempty"`
+}
+
+func NewModeratedComment(comment Comment) ModeratedComment {
+	return ModeratedComment{
+		Comment:            comment,
+		Scores:             comment.Scores,
+		ModerationAttempts: comment.ModerationAttempts,
+		ModerationError:    comment.ModerationError,
+	}
+}
+
+func (c Comment) IsReply() bool {
+	return c.ParentID != nil
+}
+
+func (c Comment) IsDeleted() bool {
+	return c.DeletedAt != nil
+}
+
+// CommentEdit keeps the content a comment had before an edit.
+type CommentEdit struct {
+	ID         int64     `gorm:"primaryKey" json:"id"`
+	CommentID  int64     `gorm:"index;not null" json:"comment_id"`
+	Content    string    `gorm:"not null" json:"content"`
+	EditedByID int64     `gorm:"not null" json:"edited_by"`
+	EditedAt   time.Time `gorm:"not null" json:"edited_at"`
+	Comment    Comment   `gorm:"foreignKey:CommentID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
 }
 
 type CreateCommentRequest struct {
+	Content  string `json:"content" binding:"required"`
+	ParentID *int64 `json:"parent_id"` // set to reply to a comment of the same event
+}
+
+type EditCommentRequest struct {
 	Content string `json:"content" binding:"required"`
 }
@@ -0,0 +1,7 @@
+package models
+
+// CommentPage is one page of top-level comments with their replies, NextCursor is empty on the last page.
+type CommentPage struct {
+	Comments   []Comment `json:"comments"`
+	NextCursor string    `json:"next_cursor"`
+}
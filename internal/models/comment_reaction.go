@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CommentReaction is one user's emoji on a comment, a user can add each emoji once.
+type CommentReaction struct {
+	CommentID int64     `gorm:"primaryKey;autoIncrement:false" json:"comment_id"`
+	UserID    int64     `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Emoji     string    `gorm:"primaryKey" json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+	Comment   Comment   `gorm:"foreignKey:CommentID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	User      User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// ReactionCount sums up one emoji on a comment, Reacted tells whether the viewer added it.
+type ReactionCount struct {
+	Emoji   string `json:"emoji"`
+	Count   int64  `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+type ReactionRequest struct {
+	Emoji string `json:"emoji" binding:"required"`
+}
+
+// ValidEmoji accepts a short emoji sequence, including skin tones, flags, keycaps and ZWJ sequences.
+func ValidEmoji(emoji string) bool {
+	if emoji == "" || utf8.RuneCountInString(emoji) > 10 {
+		return false
+	}
+	pictographic := false
+	for _, r := range emoji {
+		switch {
+		case unicode.Is(unicode.So, r), r == 0x20E3: // symbols, and the keycap of "1️⃣"
+			pictographic = true
+		case unicode.Is(unicode.Sk, r), // skin tones
+			r == 0x200D,                  // zero width joiner
+			r == 0xFE0F,                  // emoji presentation
+			r >= 0xE0020 && r <= 0xE007F, // subdivision flag tags
+			r == '#', r == '*', r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return pictographic
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Sentinel errors wrapped by services, so handlers can pick a status code with errors.Is.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrInvalidInput = errors.New("invalid input")
+	ErrConflict     = errors.New("conflict")
+	ErrForbidden    = errors.New("forbidden")
+	ErrRateLimited  = errors.New("too many requests")
+	// ErrInvalidCredentials is the only error failed logins get, whether the phone or the password was wrong
+	ErrInvalidCredentials = errors.New("invalid phone or password")
+)
+
+// StatusFor maps a wrapped sentinel error to an HTTP status, falling back to the given status.
+func StatusFor(err error, fallback int) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrInvalidCredentials):
+		return http.StatusUnauthorized
+	}
+	return fallback
+}
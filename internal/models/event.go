@@ -6,23 +6,29 @@ import (
 
 // / Events are created without photos, destinations or activities, they are added later on.
 type Event struct {
-	ID           int64         `gorm:"primaryKey" json:"id"`
-	Name         string        `gorm:"not null" json:"name"`
-	Description  string        `json:"description"`
-	Location     string        `gorm:"not null" json:"location"`
-	DateTime     time.Time     `gorm:"not null" json:"date_time"`
-	UserID       int64         `gorm:"index;not null" json:"user_id"`
-	Photos       []EventPhoto  `gorm:"foreignKey:EventID" json:"photos,omitempty"`
-	Destinations []Destination `gorm:"many2many:event_destinations"`
-	Activities   []Activity    `gorm:"many2many:event_activities"`
-}
-
-func (e Event) PhotosUrls() []string {
-	photoUrls := []string{}
-	for _, photo := range e.Photos {
-		photoUrls = append(photoUrls, photo.URL)
-	}
-	return photoUrls
+	ID                 int64              `gorm:"primaryKey" json:"id"`
+	Name               string             `gorm:"not null" json:"name"`
+	Description        string             `json:"description"`
+	Location           string             `gorm:"not null" json:"location"`
+	DateTime           time.Time          `gorm:"not null" json:"date_time"`
+	EndDateTime        *time.Time         `json:"end_date_time,omitempty"`
+	UserID             int64              `gorm:"index;not null" json:"user_id"`
+	Capacity           int                `gorm:"not null;default:0" json:"capacity" binding:"min=0"` // 0 means unlimited
+	WaitlistEnabled    bool               `gorm:"not null;default:false" json:"waitlist_enabled"`
+	Status             EventStatus        `gorm:"type:event_status;not null;default:published" json:"status"`
+	CancellationReason string             `json:"cancellation_reason,omitempty"`
+	RRule              string             `gorm:"not null;default:''" json:"rrule,omitempty"`                      // RFC 5545 RRULE, only set on a series' master event
+	ExDates            string             `gorm:"not null;default:''" json:"exdates,omitempty"`                    // comma separated UTC starts excluded from the series
+	Timezone           string             `gorm:"not null;default:'UTC'" json:"timezone,omitempty"`                // IANA zone the series repeats in, e.g. Europe/Berlin
+	SeriesID           *int64             `gorm:"uniqueIndex:idx_event_occurrence" json:"series_id,omitempty"`     // master event of an occurrence
+	OccurrenceAt       *time.Time         `gorm:"uniqueIndex:idx_event_occurrence" json:"occurrence_at,omitempty"` // start the rule generated, kept when the occurrence is moved
+	Series             *Event             `gorm:"foreignKey:SeriesID;constraint:OnDelete:CASCADE" json:"-"`
+	Photos             []EventPhoto       `gorm:"foreignKey:EventID" json:"photos,omitempty"`
+	CoverPhoto         *EventPhoto        `gorm:"foreignKey:EventID;-:migration" json:"cover_photo,omitempty"` // the photo marked as cover, if any
+	Destinations       []Destination      `gorm:"many2many:event_destinations"`
+	Schedule           []EventDestination `gorm:"foreignKey:EventID" json:"schedule,omitempty"` // destinations ordered by visit time
+	Activities         []Activity         `gorm:"many2many:event_activities"`
+	CoHosts            []EventCoHost      `gorm:"foreignKey:EventID" json:"co_hosts,omitempty"`
 }
 
 func (e Event) IsEmpty() bool {
@@ -31,3 +37,80 @@ func (e Event) IsEmpty() bool {
 	}
 	return false
 }
+
+func (e Event) IsCoHost(userID int64) bool {
+	for _, coHost := range e.CoHosts {
+		if coHost.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// CanBeManagedBy reports whether the user may edit the event, its photos and registrations.
+// Creators and co-hosts need events:edit_own, anyone with events:edit_any can manage every event.
+func (e Event) CanBeManagedBy(user *User) bool {
+	if user.HasPermission(PermissionEventsEditAny) {
+		return true
+	}
+	if !user.HasPermission(PermissionEventsEditOwn) {
+		return false
+	}
+	return e.UserID == user.ID || e.IsCoHost(user.ID)
+}
+
+// VisibleTo reports whether the user, nil when anonymous, may see the event. Like in event listings,
+// only published events are visible to everyone, the others only to the event's managers.
+func (e Event) VisibleTo(user *User) bool {
+	return e.Status == EventPublished || (user != nil && e.CanBeManagedBy(user))
+}
+
+// Contains reports whether t falls within the event's start and end, events without an end are open ended.
+func (e Event) Contains(t time.Time) bool {
+	if t.Before(e.DateTime) {
+		return false
+	}
+	return e.EndDateTime == nil || !t.After(*e.EndDateTime)
+}
+
+// IsRecurring reports whether the event is a series master, masters are templates
+// and only their occurrences are listed and registrable.
+func (e Event) IsRecurring() bool {
+	return e.RRule != ""
+}
+
+func (e Event) IsOccurrence() bool {
+	return e.SeriesID != nil
+}
+
+// SeriesStart is the event's start in its timezone. Rules are expanded from it, so a series starting
+// at 18:00 keeps starting at 18:00 local time when daylight saving time begins or ends.
+func (e Event) SeriesStart() time.Time {
+	location, err := time.LoadLocation(e.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	return e.DateTime.In(location)
+}
+
+// Occurrence builds the occurrence of a series master starting at start.
+func (e Event) Occurrence(start time.Time) Event {
+	occurrence := Event{
+		Name:            e.Name,
+		Description:     e.Description,
+		Location:        e.Location,
+		DateTime:        start,
+		UserID:          e.UserID,
+		Capacity:        e.Capacity,
+		WaitlistEnabled: e.WaitlistEnabled,
+		Status:          e.Status,
+		Timezone:        e.Timezone,
+		SeriesID:        &e.ID,
+		OccurrenceAt:    &start,
+	}
+	if e.EndDateTime != nil {
+		end := start.Add(e.EndDateTime.Sub(e.DateTime))
+		occurrence.EndDateTime = &end
+	}
+	return occurrence
+}
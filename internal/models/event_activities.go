@@ -5,6 +5,10 @@ type EventActivities struct {
 	ActivityID int64 `gorm:"primaryKey;autoIncrement:false" json:"activity_id"`
 }
 
+type AddActivitiesRequest struct {
+	ActivityIDs []int64 `json:"activity_ids" binding:"required"`
+}
+
 type RemoveActivitiesRequest struct {
 	ActivityIDs []int64 `json:"activity_ids" binding:"required"`
 }
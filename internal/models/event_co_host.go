@@ -0,0 +1,9 @@
+package models
+
+// EventCoHost lets the event creator share the event management with other users.
+type EventCoHost struct {
+	EventID int64 `gorm:"primaryKey;autoIncrement:false" json:"event_id"`
+	UserID  int64 `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Event   Event `gorm:"foreignKey:EventID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	User    User  `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+}
@@ -5,9 +5,10 @@ import (
 )
 
 type EventDestination struct {
-	EventID       int64     `gorm:"primaryKey;autoIncrement:false" json:"event_id"`
-	DestinationID int64     `gorm:"primaryKey;autoIncrement:false" json:"destination_id"`
-	DateTime      time.Time `gorm:"not null" json:"datetime"`
+	EventID       int64       `gorm:"primaryKey;autoIncrement:false" json:"event_id"`
+	DestinationID int64       `gorm:"primaryKey;autoIncrement:false" json:"destination_id"`
+	DateTime      time.Time   `gorm:"not null" json:"datetime"`
+	Destination   Destination `gorm:"foreignKey:DestinationID;references:ID;constraint:OnDelete:CASCADE" json:"destination"`
 }
 
 type EventDestinationRequest struct {
@@ -0,0 +1,7 @@
+package models
+
+// EventPage is one page of events, NextCursor is empty on the last page.
+type EventPage struct {
+	Events     []Event `json:"events"`
+	NextCursor string  `json:"next_cursor"`
+}
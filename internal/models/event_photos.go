@@ -4,10 +4,48 @@ import (
 	"time"
 )
 
+// PhotoStatus tracks the review of photos uploaded by photographers, photos uploaded by the
+// event's managers are approved right away. Approved photos hidden after being reported stay
+// hidden until a moderator acts on the reports, the event's managers can't approve them again.
+type PhotoStatus string
+
+const (
+	PhotoPending  PhotoStatus = "pending"
+	PhotoApproved PhotoStatus = "approved"
+	PhotoRejected PhotoStatus = "rejected"
+	PhotoHidden   PhotoStatus = "hidden"
+)
+
+func (s PhotoStatus) Valid() bool {
+	switch s {
+	case PhotoPending, PhotoApproved, PhotoRejected, PhotoHidden:
+		return true
+	}
+	return false
+}
+
 type EventPhoto struct {
-	ID         int64     `gorm:"primaryKey" json:"_"`
-	EventID    int64     `gorm:"index" json:"__"`
-	URL        string    `gorm:"not null" json:"url"`
-	UploadedAt time.Time `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"___"`
-	Event      Event     `gorm:"foreignKey:EventID;references:ID"`
+	ID           int64         `gorm:"primaryKey" json:"id"`
+	EventID      int64         `gorm:"index;uniqueIndex:idx_event_cover,where:is_cover" json:"event_id"` // one cover per event
+	URL          string        `gorm:"not null" json:"url"`                                              // the original variant
+	Variants     ImageVariants `gorm:"embedded;embeddedPrefix:variant_" json:"variants"`
+	Caption      string        `json:"caption,omitempty"`
+	Position     int           `gorm:"not null;default:0" json:"position"` // manual order within the event
+	IsCover      bool          `gorm:"not null;default:false" json:"is_cover"`
+	AlbumID      *int64        `gorm:"index" json:"album_id,omitempty"`
+	Status       PhotoStatus   `gorm:"type:photo_status;not null;default:approved;index" json:"status"`
+	UploadedByID *int64        `gorm:"index" json:"uploaded_by,omitempty"`
+	ReviewedByID *int64        `json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time    `json:"reviewed_at,omitempty"`
+	ReviewReason string        `json:"review_reason,omitempty"`
+	UploadedAt   time.Time     `gorm:"type:timestamp;default:CURRENT_TIMESTAMP" json:"uploaded_at"`
+	Event        Event         `gorm:"foreignKey:EventID;references:ID" json:"-"`
+	Album        *PhotoAlbum   `gorm:"foreignKey:AlbumID" json:"-"`
+	Photographer *UserSummary  `gorm:"foreignKey:UploadedByID;constraint:OnDelete:SET NULL" json:"photographer,omitempty"` // credit for the photo
+}
+
+// PhotoReview records who approved or rejected pending photos.
+type PhotoReview struct {
+	ReviewedByID int64
+	Reason       string
 }
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // RegistrationStatus defines the possible status values
 type RegistrationStatus string
 
@@ -9,13 +11,37 @@ const (
 	PendingRegistration RegistrationStatus = "pending_registration"
 	Cancelled           RegistrationStatus = "cancelled"
 	PendingCancellation RegistrationStatus = "pending_cancellation"
+	Rejected            RegistrationStatus = "rejected"
+	Waitlisted          RegistrationStatus = "waitlisted"
 )
 
+// SeatHoldingStatuses are the statuses that take one of the event's seats.
+var SeatHoldingStatuses = []RegistrationStatus{Registered, PendingRegistration, PendingCancellation}
+
+func (s RegistrationStatus) Valid() bool {
+	switch s {
+	case Registered, PendingRegistration, Cancelled, PendingCancellation, Rejected, Waitlisted:
+		return true
+	}
+	return false
+}
+
 // Registration model with composite key and status
 type Registration struct {
-	EventID int64              `gorm:"primaryKey;autoIncrement:false" json:"event_id"`
-	UserID  int64              `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
-	Status  RegistrationStatus `gorm:"type:registration_status;not null;default:pending_registration" json:"status"`
-	Event   Event              `gorm:"foreignKey:EventID;references:ID" json:"event,omitempty"`
-	User    User               `gorm:"foreignKey:UserID;references:ID" json:"user,omitempty"`
+	EventID        int64              `gorm:"primaryKey;autoIncrement:false" json:"event_id"`
+	UserID         int64              `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Status         RegistrationStatus `gorm:"type:registration_status;not null;default:pending_registration" json:"status"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+	DecidedByID    *int64             `json:"decided_by,omitempty"`
+	DecidedAt      *time.Time         `json:"decided_at,omitempty"`
+	DecisionReason string             `json:"decision_reason,omitempty"`
+	Event          Event              `gorm:"foreignKey:EventID;references:ID" json:"-"`
+	User           User               `gorm:"foreignKey:UserID;references:ID" json:"user,omitempty"`
+}
+
+// RegistrationDecision records who approved or rejected a registration or cancellation request.
+type RegistrationDecision struct {
+	DecidedByID int64
+	Reason      string
 }
@@ -0,0 +1,39 @@
+package models
+
+// EventStatus is where an event is in its lifecycle
+type EventStatus string
+
+const (
+	EventDraft     EventStatus = "draft"
+	EventPublished EventStatus = "published"
+	EventCancelled EventStatus = "cancelled"
+	EventCompleted EventStatus = "completed"
+)
+
+// eventTransitions lists the statuses each status can move to, cancelled and completed are final.
+var eventTransitions = map[EventStatus][]EventStatus{
+	EventDraft:     {EventPublished, EventCancelled},
+	EventPublished: {EventDraft, EventCancelled, EventCompleted},
+}
+
+func (s EventStatus) Valid() bool {
+	switch s {
+	case EventDraft, EventPublished, EventCancelled, EventCompleted:
+		return true
+	}
+	return false
+}
+
+// Final reports whether the event is over, cancelled or completed.
+func (s EventStatus) Final() bool {
+	return s == EventCancelled || s == EventCompleted
+}
+
+func (s EventStatus) CanTransitionTo(to EventStatus) bool {
+	for _, allowed := range eventTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package models
+
+// ImageVariant is one stored size of an uploaded image.
+type ImageVariant struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// ImageVariants are the sizes every uploaded image is re-encoded into.
+// Images smaller than a size reuse the next bigger variant's file.
+type ImageVariants struct {
+	Thumbnail ImageVariant `gorm:"embedded;embeddedPrefix:thumbnail_" json:"thumbnail"`
+	Medium    ImageVariant `gorm:"embedded;embeddedPrefix:medium_" json:"medium"`
+	Original  ImageVariant `gorm:"embedded;embeddedPrefix:original_" json:"original"`
+}
+
+// URLs lists the distinct files behind the variants.
+func (v ImageVariants) URLs() []string {
+	urls := []string{}
+	for _, variant := range []ImageVariant{v.Thumbnail, v.Medium, v.Original} {
+		if variant.URL == "" {
+			continue
+		}
+		duplicate := false
+		for _, url := range urls {
+			duplicate = duplicate || url == variant.URL
+		}
+		if !duplicate {
+			urls = append(urls, variant.URL)
+		}
+	}
+	return urls
+}
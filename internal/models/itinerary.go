@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ItineraryStop is one stop of a multi-stop trip with the activities done there.
+type ItineraryStop struct {
+	ID            int64       `gorm:"primaryKey" json:"id"`
+	EventID       int64       `gorm:"index;not null" json:"event_id"`
+	DestinationID int64       `gorm:"not null" json:"destination_id"`
+	Position      int         `gorm:"not null" json:"position"`
+	ArrivalAt     time.Time   `gorm:"not null" json:"arrival_at"`
+	DepartureAt   time.Time   `gorm:"not null" json:"departure_at"`
+	Notes         string      `json:"notes,omitempty"`
+	Event         Event       `gorm:"foreignKey:EventID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	Destination   Destination `gorm:"foreignKey:DestinationID;references:ID;constraint:OnDelete:CASCADE" json:"destination"`
+	Activities    []Activity  `gorm:"many2many:itinerary_stop_activities;constraint:OnDelete:CASCADE" json:"activities"`
+}
+
+// ItineraryDay groups the stops by the (UTC) day they arrive on.
+type ItineraryDay struct {
+	Date  string          `json:"date"`
+	Stops []ItineraryStop `json:"stops"`
+}
+
+// Itinerary is the day by day timeline of an event.
+type Itinerary struct {
+	EventID  int64          `json:"event_id"`
+	StartsAt time.Time      `json:"starts_at"`
+	EndsAt   *time.Time     `json:"ends_at,omitempty"`
+	Days     []ItineraryDay `json:"days"`
+}
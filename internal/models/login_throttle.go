@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// LoginThrottle counts recent failed logins for a key, "phone:<phone>" for an account or "ip:<address>" for a client.
+// Phones are throttled whether they are signed up or not, so throttling doesn't tell which ones are.
+type LoginThrottle struct {
+	Key           string     `gorm:"primaryKey" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastFailureAt time.Time  `gorm:"not null" json:"last_failure_at"`
+	LockedUntil   *time.Time `gorm:"index" json:"locked_until,omitempty"`
+}
+
+func (t LoginThrottle) Locked(now time.Time) bool {
+	return t.LockedUntil != nil && now.Before(*t.LockedUntil)
+}
+
+func PhoneThrottleKey(phone string) string {
+	return "phone:" + phone
+}
+
+func IPThrottleKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Categories scored by the local rule-based moderator, providers such as OpenAI add their own e.g. "hate".
+const (
+	CategorySpam      = "spam"
+	CategoryProfanity = "profanity"
+)
+
+// ModerationScores holds a score between 0 and 1 per category.
+type ModerationScores map[string]float32
+
+// Max is the highest score of any category, 0 when there are none.
+func (s ModerationScores) Max() float32 {
+	highest := float32(0)
+	for _, score := range s {
+		if score > highest {
+			highest = score
+		}
+	}
+	return highest
+}
+
+// Merge keeps the higher score of every category found in either.
+func (s ModerationScores) Merge(other ModerationScores) {
+	for category, score := range other {
+		if score > s[category] {
+			s[category] = score
+		}
+	}
+}
+
+// Value stores the scores as a JSON object.
+func (s ModerationScores) Value() (driver.Value, error) {
+	if s == nil {
+		return "{}", nil
+	}
+	content, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(content), nil
+}
+
+func (s *ModerationScores) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*s = ModerationScores{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	}
+	return fmt.Errorf("unsupported moderation scores type %T", value)
+}
@@ -0,0 +1,27 @@
+package models
+
+// Permissions are granted to roles, never directly to users.
+const (
+	PermissionEventsCreate     = "events:create"
+	PermissionEventsEditOwn    = "events:edit_own"
+	PermissionEventsEditAny    = "events:edit_any"
+	PermissionPhotosUpload     = "photos:upload"
+	PermissionCommentsModerate = "comments:moderate"
+	PermissionReportsManage    = "reports:manage"
+	PermissionCatalogManage    = "catalog:manage"
+	PermissionRolesManage      = "roles:manage"
+	PermissionUsersManage      = "users:manage"
+)
+
+type Permission struct {
+	ID          int64  `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"not null;unique" json:"name"`
+	Description string `gorm:"not null" json:"description"`
+}
+
+type RolePermission struct {
+	RoleID       int64      `gorm:"primaryKey;autoIncrement:false" json:"role_id"`
+	PermissionID int64      `gorm:"primaryKey;autoIncrement:false" json:"permission_id"`
+	Role         Role       `gorm:"foreignKey:RoleID;references:ID;constraint:OnDelete:CASCADE" json:"role,omitempty"`
+	Permission   Permission `gorm:"foreignKey:PermissionID;references:ID;constraint:OnDelete:CASCADE" json:"permission,omitempty"`
+}
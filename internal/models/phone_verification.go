@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// VerificationPurpose tells what confirming the code does.
+type VerificationPurpose string
+
+const (
+	VerifySignup      VerificationPurpose = "signup"       // confirms the phone the user signed up with
+	VerifyPhoneChange VerificationPurpose = "phone_change" // moves the user to the new phone
+	VerifyPassword    VerificationPurpose = "password"     // lets the user set a new password
+	VerifyTwoFactor   VerificationPurpose = "two_factor"   // lets users whose role requires two-factor enroll an authenticator
+)
+
+// PhoneVerification is a one-time code sent by SMS. Only the code's hash is stored,
+// a newer code for the same user and purpose replaces the older ones.
+type PhoneVerification struct {
+	ID         int64               `gorm:"primaryKey" json:"id"`
+	UserID     int64               `gorm:"index;not null" json:"user_id"`
+	Phone      string              `gorm:"index;not null" json:"phone"` // where the code was sent
+	Purpose    VerificationPurpose `gorm:"type:varchar(16);not null" json:"purpose"`
+	CodeHash   string              `gorm:"not null" json:"-"`
+	Attempts   int                 `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt  time.Time           `gorm:"not null" json:"expires_at"`
+	ConsumedAt *time.Time          `json:"consumed_at,omitempty"`
+	CreatedAt  time.Time           `gorm:"index" json:"created_at"`
+	User       User                `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (v PhoneVerification) Expired() bool {
+	return time.Now().After(v.ExpiresAt)
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// PhotoAlbum groups an event's photos, e.g. one album per destination of a trip.
+// Deleting an album keeps its photos in the event.
+type PhotoAlbum struct {
+	ID            int64        `gorm:"primaryKey" json:"id"`
+	EventID       int64        `gorm:"not null;uniqueIndex:idx_album_destination" json:"event_id"`
+	DestinationID *int64       `gorm:"uniqueIndex:idx_album_destination" json:"destination_id,omitempty"` // at most one album per destination
+	Name          string       `gorm:"not null" json:"name"`
+	Position      int          `gorm:"not null;default:0" json:"position"`
+	CreatedAt     time.Time    `json:"created_at"`
+	Event         Event        `gorm:"foreignKey:EventID;references:ID;constraint:OnDelete:CASCADE" json:"-"`
+	Destination   *Destination `gorm:"foreignKey:DestinationID;references:ID;constraint:OnDelete:SET NULL" json:"destination,omitempty"`
+	Photos        []EventPhoto `gorm:"foreignKey:AlbumID;constraint:OnDelete:SET NULL" json:"photos"`
+}
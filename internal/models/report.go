@@ -0,0 +1,119 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ReportTarget is the kind of content a report is about.
+type ReportTarget string
+
+const (
+	ReportComment ReportTarget = "comment"
+	ReportPhoto   ReportTarget = "photo"
+	ReportUser    ReportTarget = "user"
+)
+
+func (t ReportTarget) Valid() bool {
+	switch t {
+	case ReportComment, ReportPhoto, ReportUser:
+		return true
+	}
+	return false
+}
+
+// ReportReason is the category the reporter picked.
+type ReportReason string
+
+const (
+	ReasonSpam          ReportReason = "spam"
+	ReasonHarassment    ReportReason = "harassment"
+	ReasonHate          ReportReason = "hate"
+	ReasonInappropriate ReportReason = "inappropriate"
+	ReasonImpersonation ReportReason = "impersonation"
+	ReasonOther         ReportReason = "other"
+)
+
+func (r ReportReason) Valid() bool {
+	switch r {
+	case ReasonSpam, ReasonHarassment, ReasonHate, ReasonInappropriate, ReasonImpersonation, ReasonOther:
+		return true
+	}
+	return false
+}
+
+// ReportStatus tracks the triage of a report, open reports are resolved all together per target.
+type ReportStatus string
+
+const (
+	ReportOpen      ReportStatus = "open"
+	ReportDismissed ReportStatus = "dismissed"
+	ReportActioned  ReportStatus = "actioned"
+)
+
+// Report flags a comment, photo or user. A reporter has at most one open report per target.
+type Report struct {
+	ID           int64        `gorm:"primaryKey" json:"id"`
+	ReporterID   int64        `gorm:"not null;uniqueIndex:idx_open_report,where:status = 'open'" json:"reporter_id"`
+	TargetType   ReportTarget `gorm:"type:varchar(16);not null;uniqueIndex:idx_open_report;index:idx_report_target" json:"target_type"`
+	TargetID     int64        `gorm:"not null;uniqueIndex:idx_open_report;index:idx_report_target" json:"target_id"`
+	Reason       ReportReason `gorm:"type:varchar(32);not null" json:"reason"`
+	Details      string       `json:"details,omitempty"`
+	Status       ReportStatus `gorm:"type:report_status;not null;default:open;index" json:"status"`
+	ResolvedByID *int64       `json:"resolved_by,omitempty"`
+	ResolvedAt   *time.Time   `json:"resolved_at,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	Reporter     User         `gorm:"foreignKey:ReporterID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// ReportedItem is a target with open reports, as listed in the admins' triage queue.
+type ReportedItem struct {
+	TargetType      ReportTarget   `json:"target_type"`
+	TargetID        int64          `json:"target_id"`
+	Reports         int            `json:"reports"`
+	Reasons         pq.StringArray `gorm:"type:text[]" json:"reasons"`
+	FirstReportedAt time.Time      `json:"first_reported_at"`
+	LastReportedAt  time.Time      `json:"last_reported_at"`
+}
+
+// ModerationActionType is what was done about reported content.
+type ModerationActionType string
+
+const (
+	ActionAutoHide ModerationActionType = "auto_hide" // enough reports came in
+	ActionHide     ModerationActionType = "hide"      // an admin hid the comment from the moderation queue
+	ActionApprove  ModerationActionType = "approve"   // an admin showed the comment from the moderation queue
+	ActionDismiss  ModerationActionType = "dismiss"
+	ActionRemove   ModerationActionType = "remove"
+	ActionBlock    ModerationActionType = "block"
+)
+
+func (a ModerationActionType) Valid() bool {
+	switch a {
+	case ActionDismiss, ActionRemove, ActionBlock:
+		return true // auto_hide, hide and approve are never requested here, only recorded
+	}
+	return false
+}
+
+// ModerationAction is the audit log of everything done about reports.
+type ModerationAction struct {
+	ID         int64                `gorm:"primaryKey" json:"id"`
+	Action     ModerationActionType `gorm:"type:varchar(16);not null" json:"action"`
+	TargetType ReportTarget         `gorm:"type:varchar(16);not null;index:idx_moderation_action_target" json:"target_type"`
+	TargetID   int64                `gorm:"not null;index:idx_moderation_action_target" json:"target_id"`
+	ActorID    *int64               `gorm:"index" json:"actor_id,omitempty"`   // nil for automatic actions
+	UserID     *int64               `json:"user_id,omitempty"`                 // the blocked user
+	Reports    int                  `gorm:"not null;default:0" json:"reports"` // open reports it resolved
+	Note       string               `json:"note,omitempty"`
+	CreatedAt  time.Time            `json:"created_at"`
+}
+
+// ReportDetails is a reported target with its open reports and what was done about it so far.
+type ReportDetails struct {
+	TargetType ReportTarget       `json:"target_type"`
+	TargetID   int64              `json:"target_id"`
+	Reports    []Report           `json:"reports"`
+	Actions    []ModerationAction `json:"actions"`
+}
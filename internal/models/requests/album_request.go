@@ -0,0 +1,8 @@
+package requests
+
+// AlbumRequest creates or updates a photo album, the destination has to be one of the event's.
+type AlbumRequest struct {
+	Name          string `json:"name" binding:"required"`
+	DestinationID *int64 `json:"destination_id"`
+	Position      int    `json:"position"`
+}
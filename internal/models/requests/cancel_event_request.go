@@ -0,0 +1,5 @@
+package requests
+
+type CancelEventRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
@@ -0,0 +1,5 @@
+package requests
+
+type CoHostRequest struct {
+	UserID int64 `json:"user_id" binding:"required"`
+}
@@ -0,0 +1,24 @@
+package requests
+
+const (
+	DefaultCommentsLimit = 20
+	MaxCommentsLimit     = 100
+)
+
+// CommentQuery pages through an event's top-level comments, newest first unless Order is "oldest".
+// Replies always come with their thread, oldest first.
+type CommentQuery struct {
+	Order  string `form:"order" binding:"omitempty,oneof=newest oldest"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor string `form:"cursor"`
+}
+
+func (q CommentQuery) PageSize() int {
+	if q.Limit <= 0 {
+		return DefaultCommentsLimit
+	}
+	if q.Limit > MaxCommentsLimit {
+		return MaxCommentsLimit
+	}
+	return q.Limit
+}
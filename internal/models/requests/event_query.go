@@ -0,0 +1,33 @@
+package requests
+
+import "time"
+
+const (
+	DefaultEventsLimit = 20
+	MaxEventsLimit     = 100
+)
+
+// EventQuery holds the search, filter, sort and pagination options of GET /events.
+// Sort is one of date, -date, name or -name, a leading "-" sorts descending.
+// Status only narrows down the events the viewer may see anyway.
+type EventQuery struct {
+	Search        string    `form:"q"`
+	From          time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To            time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Activity      string    `form:"activity"`
+	DestinationID int64     `form:"destination"`
+	Status        string    `form:"status" binding:"omitempty,oneof=draft published cancelled completed"`
+	Sort          string    `form:"sort" binding:"omitempty,oneof=date -date name -name"`
+	Limit         int       `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor        string    `form:"cursor"`
+}
+
+func (q EventQuery) PageSize() int {
+	if q.Limit <= 0 {
+		return DefaultEventsLimit
+	}
+	if q.Limit > MaxEventsLimit {
+		return MaxEventsLimit
+	}
+	return q.Limit
+}
@@ -0,0 +1,16 @@
+package requests
+
+import "time"
+
+type ItineraryStopRequest struct {
+	DestinationID int64     `json:"destination_id" binding:"required"`
+	ArrivalAt     time.Time `json:"arrival_at" binding:"required"`
+	DepartureAt   time.Time `json:"departure_at" binding:"required"`
+	Notes         string    `json:"notes"`
+	ActivityIDs   []int64   `json:"activity_ids"`
+}
+
+// ItineraryRequest replaces the whole itinerary of an event, an empty list clears it.
+type ItineraryRequest struct {
+	Stops []ItineraryStopRequest `json:"stops" binding:"dive"`
+}
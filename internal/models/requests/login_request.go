@@ -4,4 +4,5 @@ type LoginRequest struct {
 	ID       int64  `json:"id"`
 	Phone    string `json:"phone" binding:"required"`
 	Password string `json:"password" binding:"required,min=5"`
+	Device   string `json:"device" binding:"max=64"` // optional label for the session, e.g. "Pixel 8"
 }
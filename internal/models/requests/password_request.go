@@ -0,0 +1,17 @@
+package requests
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=5"`
+}
+
+type ForgotPasswordRequest struct {
+	Phone string `json:"phone" binding:"required"`
+}
+
+// ResetPasswordRequest sets a new password with the code sent for a ForgotPasswordRequest.
+type ResetPasswordRequest struct {
+	Phone    string `json:"phone" binding:"required"`
+	Code     string `json:"code" binding:"required,len=6,numeric"`
+	Password string `json:"password" binding:"required,min=5"`
+}
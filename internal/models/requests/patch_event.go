@@ -3,14 +3,32 @@ package requests
 import "time"
 
 type PatchEvent struct {
-	Name        *string    `json:"name"`
-	Description *string    `json:"description"`
-	Location    *string    `json:"location"`
-	DateTime    *time.Time `json:"dateTime"`
+	Name            *string    `json:"name"`
+	Description     *string    `json:"description"`
+	Location        *string    `json:"location"`
+	DateTime        *time.Time `json:"dateTime"`
+	EndDateTime     *time.Time `json:"end_date_time"`
+	Capacity        *int       `json:"capacity" binding:"omitempty,min=0"`
+	WaitlistEnabled *bool      `json:"waitlist_enabled"`
+	RRule           *string    `json:"rrule"`
+	ExDates         *string    `json:"exdates"`
+	Timezone        *string    `json:"timezone"`
+}
+
+// Scopes of an edit or delete made through an occurrence of a recurring event
+const (
+	ScopeThis      = "this"
+	ScopeFollowing = "following"
+)
+
+// ChangesRecurrence reports whether the patch edits the series rule, its exclusions or its timezone.
+func (pe PatchEvent) ChangesRecurrence() bool {
+	return pe.RRule != nil || pe.ExDates != nil || pe.Timezone != nil
 }
 
 func (pe PatchEvent) IsEmpty() bool {
-	if pe.Name == nil && pe.Description == nil && pe.Location == nil && pe.DateTime == nil {
+	if pe.Name == nil && pe.Description == nil && pe.Location == nil && pe.DateTime == nil &&
+		pe.EndDateTime == nil && pe.Capacity == nil && pe.WaitlistEnabled == nil && pe.RRule == nil && pe.ExDates == nil && pe.Timezone == nil {
 		return true
 	}
 	return false
@@ -0,0 +1,11 @@
+package requests
+
+type VerifyPhoneRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// ChangePhoneRequest starts moving the user to a new phone, it takes effect once the code sent there is confirmed.
+type ChangePhoneRequest struct {
+	Phone    string `json:"phone" binding:"required,e164"`
+	Password string `json:"password" binding:"required"`
+}
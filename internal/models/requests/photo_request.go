@@ -0,0 +1,12 @@
+package requests
+
+// PatchPhotoRequest updates a photo's caption and album, an AlbumID of 0 takes it out of its album.
+type PatchPhotoRequest struct {
+	Caption *string `json:"caption"`
+	AlbumID *int64  `json:"album_id"`
+}
+
+// PhotoIDsRequest lists photos to reorder or delete, reordering moves them to the front in the given order.
+type PhotoIDsRequest struct {
+	PhotoIDs []int64 `json:"photo_ids" binding:"required,min=1"`
+}
@@ -0,0 +1,7 @@
+package requests
+
+// PhotoReviewRequest approves or rejects pending photos in bulk.
+type PhotoReviewRequest struct {
+	PhotoIDs []int64 `json:"photo_ids" binding:"required,min=1"`
+	Reason   string  `json:"reason"`
+}
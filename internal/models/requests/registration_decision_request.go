@@ -0,0 +1,8 @@
+package requests
+
+// RegistrationDecisionRequest approves or rejects registrations in bulk,
+// UserIDs is ignored when the user is given in the URL.
+type RegistrationDecisionRequest struct {
+	UserIDs []int64 `json:"user_ids"`
+	Reason  string  `json:"reason"`
+}
@@ -0,0 +1,16 @@
+package requests
+
+import "github.com/wmfadel/wander-base/internal/models"
+
+// ReportRequest flags a comment, photo or user for the admins.
+type ReportRequest struct {
+	TargetType models.ReportTarget `json:"target_type" binding:"required"`
+	TargetID   int64               `json:"target_id" binding:"required"`
+	Reason     models.ReportReason `json:"reason" binding:"required"`
+	Details    string              `json:"details" binding:"max=1000"`
+}
+
+// ReportActionRequest carries the admin's note on a triage action.
+type ReportActionRequest struct {
+	Note string `json:"note" binding:"max=1000"`
+}
@@ -0,0 +1,5 @@
+package requests
+
+type RolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required,min=1"`
+}
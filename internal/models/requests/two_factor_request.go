@@ -0,0 +1,25 @@
+package requests
+
+// EnrollTwoFactorRequest starts setting up an authenticator, users whose role requires two-factor
+// also send the code texted to them for it.
+type EnrollTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"omitempty,len=6,numeric"`
+}
+
+// TwoFactorCodeRequest carries a TOTP code, or a recovery code where those are accepted too.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// TwoFactorLoginRequest completes a login with the challenge token the password login returned.
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+	Device         string `json:"device" binding:"max=64"`
+}
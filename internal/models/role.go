@@ -1,8 +1,26 @@
 package models
 
+// Names of the seeded roles
+const (
+	RoleAdmin        = "admin"
+	RoleOrganizer    = "organizer"
+	RolePhotographer = "photographer"
+	RoleUser         = "user"
+)
+
 type Role struct {
-	ID          int64  `gorm:"primaryKey" json:"id"`
-	Name        string `gorm:"not null;unique" json:"name"`
-	Description string `gorm:"not null" json:"description"`
-	Default     bool   `gorm:"column:default_role;not null" json:"default"`
+	ID          int64        `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"not null;unique" json:"name"`
+	Description string       `gorm:"not null" json:"description"`
+	Default     bool         `gorm:"column:default_role;not null" json:"default"`
+	Permissions []Permission `gorm:"many2many:role_permissions" json:"permissions,omitempty"`
+}
+
+func (r Role) HasPermission(permission string) bool {
+	for _, p := range r.Permissions {
+		if p.Name == permission {
+			return true
+		}
+	}
+	return false
 }
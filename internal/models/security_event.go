@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// SecurityEventType is what happened to an account or client.
+type SecurityEventType string
+
+const (
+	EventLockout SecurityEventType = "lockout"
+	EventUnlock  SecurityEventType = "unlock"
+
+	EventTwoFactorEnabled  SecurityEventType = "two_factor_enabled"
+	EventTwoFactorDisabled SecurityEventType = "two_factor_disabled"
+	EventTwoFactorReset    SecurityEventType = "two_factor_reset" // an admin removed a user's two-factor
+
+	EventSessionRevoked SecurityEventType = "session_revoked" // an admin logged a user out
+)
+
+// SecurityEvent is the audit log of security relevant account changes.
+type SecurityEvent struct {
+	ID        int64             `gorm:"primaryKey" json:"id"`
+	Type      SecurityEventType `gorm:"type:varchar(32);not null;index" json:"type"`
+	Subject   string            `gorm:"not null;index" json:"subject"`  // e.g. a LoginThrottle key or a user's phone
+	UserID    *int64            `gorm:"index" json:"user_id,omitempty"` // the account concerned, if known
+	ActorID   *int64            `json:"actor_id,omitempty"`             // the admin, nil for automatic events
+	IP        string            `json:"ip,omitempty"`
+	Details   string            `json:"details,omitempty"`
+	CreatedAt time.Time         `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Session is a refresh token family as the user sees it, a device they are logged in on.
+// Access tokens carry the session's ID, revoking the session rejects them right away.
+type Session struct {
+	ID          string     `gorm:"primaryKey" json:"id"` // the refresh token family
+	UserID      int64      `gorm:"index;not null" json:"user_id"`
+	DeviceLabel string     `json:"device_label,omitempty"` // named by the client at login, e.g. "Pixel 8"
+	IP          string     `json:"ip"`                     // as of the last time the session was seen
+	UserAgent   string     `json:"user_agent"`
+	TwoFactor   bool       `gorm:"not null;default:false" json:"two_factor"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastSeenAt  time.Time  `gorm:"not null" json:"last_seen_at"`
+	ExpiresAt   time.Time  `gorm:"index;not null" json:"expires_at"` // when its latest refresh token expires
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	Current     bool       `gorm:"-" json:"current"` // the session the list was asked for from
+	User        User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (s Session) Revoked() bool {
+	return s.RevokedAt != nil
+}
+
+// SessionDevice is what a request tells about the device a session is used from.
+type SessionDevice struct {
+	Label     string
+	IP        string
+	UserAgent string
+}
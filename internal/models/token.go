@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// RefreshToken is a single use token, every refresh rotates it within the same family.
+// Presenting an already rotated token revokes the whole family.
+type RefreshToken struct {
+	ID        int64      `gorm:"primaryKey" json:"id"`
+	UserID    int64      `gorm:"index;not null" json:"user_id"`
+	FamilyID  string     `gorm:"index;not null" json:"family_id"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	TwoFactor bool       `gorm:"not null;default:false" json:"two_factor"` // the session's login passed two-factor
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (t RefreshToken) Revoked() bool {
+	return t.RevokedAt != nil
+}
+
+func (t RefreshToken) Expired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// RevokedAccessToken denylists an access token jti until the token expires on its own.
+type RevokedAccessToken struct {
+	JTI       string    `gorm:"primaryKey" json:"jti"`
+	UserID    int64     `gorm:"index;not null" json:"user_id"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`
+}
+
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// CalendarToken authenticates a user's calendar feed. Calendar apps can't send an
+// Authorization header, so the feed URL carries this token instead.
+type CalendarToken struct {
+	UserID    int64     `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	TokenHash string    `gorm:"uniqueIndex;not null" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// TwoFactor is a user's TOTP authenticator. It is pending until the user confirms a first code,
+// only then are logins asked for codes.
+type TwoFactor struct {
+	UserID    int64      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Secret    string     `gorm:"not null" json:"-"` // sealed with utils.SealSecret
+	EnabledAt *time.Time `json:"enabled_at,omitempty"`
+	LastStep  int64      `gorm:"not null;default:0" json:"-"` // the last TOTP step used, codes can't be replayed
+	CreatedAt time.Time  `json:"created_at"`
+	User      User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (t TwoFactor) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// RecoveryCode logs the user in once in place of a TOTP code, for when the authenticator is lost.
+type RecoveryCode struct {
+	ID        int64      `gorm:"primaryKey" json:"id"`
+	UserID    int64      `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	User      User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// LoginChallenge is handed out instead of tokens when the password was right but the user has
+// two-factor enabled, the login completes by presenting it with a code.
+type LoginChallenge struct {
+	ID         int64      `gorm:"primaryKey" json:"id"`
+	UserID     int64      `gorm:"index;not null" json:"user_id"`
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	Attempts   int        `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt  time.Time  `gorm:"index;not null" json:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	User       User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (c LoginChallenge) Expired() bool {
+	return time.Now().After(c.ExpiresAt)
+}
+
+// TwoFactorStatus is what users see of their own two-factor setup.
+type TwoFactorStatus struct {
+	Enabled           bool       `json:"enabled"`
+	Required          bool       `json:"required"` // one of the user's roles requires it
+	EnabledAt         *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesLeft int64      `json:"recovery_codes_left"`
+}
+
+// TwoFactorChallenge is returned by a password login instead of tokens when the user has two-factor enabled.
+type TwoFactorChallenge struct {
+	ChallengeToken string    `json:"challenge_token"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
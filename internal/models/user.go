@@ -1,15 +1,61 @@
 package models
 
+import "time"
+
 type User struct {
-	ID        int64  `gorm:"primaryKey" json:"id"`
-	Phone     string `gorm:"not null;unique" json:"phone" binding:"required"`
-	Password  string `gorm:"not null" json:"password" binding:"required,min=5"`
-	FirstName string `gorm:"not null" json:"first_name" binding:"required"`
-	LastName  string `gorm:"not null" json:"last_name" binding:"required"`
-	Photo     string `json:"photo,omitempty"`
-	Roles     []Role `gorm:"many2many:user_roles" json:"roles"`
+	ID              int64         `gorm:"primaryKey" json:"id"`
+	Phone           string        `gorm:"not null;unique" json:"phone" binding:"required,e164"`
+	PhoneVerifiedAt *time.Time    `json:"phone_verified_at"` // nil until the user confirms a code sent to Phone
+	Password        string        `gorm:"not null" json:"password" binding:"required,min=5"`
+	SessionsResetAt *time.Time    `json:"-"` // tokens issued before are rejected, set when the password is reset
+	FirstName       string        `gorm:"not null" json:"first_name" binding:"required"`
+	LastName        string        `gorm:"not null" json:"last_name" binding:"required"`
+	Photo           string        `json:"photo,omitempty"` // the original variant
+	PhotoVariants   ImageVariants `gorm:"embedded;embeddedPrefix:photo_" json:"photo_variants"`
+	Roles           []Role        `gorm:"many2many:user_roles" json:"roles"`
 }
 
 func (u *User) Blocked() bool {
 	return len(u.Roles) == 0
 }
+
+// PhoneVerified tells if the user confirmed owning their phone number, unverified users are limited
+// to their profile until they do.
+func (u *User) PhoneVerified() bool {
+	return u.PhoneVerifiedAt != nil
+}
+
+// IssuedBeforeReset tells if a token issued at the given time predates the user's last password reset.
+func (u *User) IssuedBeforeReset(issuedAt time.Time) bool {
+	return u.SessionsResetAt != nil && issuedAt.Before(u.SessionsResetAt.Truncate(time.Second))
+}
+
+func (u *User) HasRole(name string) bool {
+	for _, role := range u.Roles {
+		if role.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *User) HasPermission(permission string) bool {
+	for _, role := range u.Roles {
+		if role.HasPermission(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// UserSummary is the public part of a user, used wherever users are shown to other users.
+type UserSummary struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Photo     string `json:"photo,omitempty"`
+}
+
+func (UserSummary) TableName() string {
+	return "users"
+}
@@ -2,9 +2,14 @@ package repository
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
+	"github.com/wmfadel/wander-base/pkg/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CommentRepository struct {
@@ -23,17 +28,26 @@ func (repo *CommentRepository) Create(comment *models.Comment) error {
 	return nil
 }
 
-func (repo *CommentRepository) Delete(commentId int64) error {
-	result := repo.db.Delete(&models.Comment{}, commentId)
+// SoftDelete marks the comment deleted, it stays in its thread so the replies keep their place.
+func (repo *CommentRepository) SoftDelete(commentId int64, deletedById int64) error {
+	result := repo.db.Model(&models.Comment{}).
+		Where("id = ? AND deleted_at IS NULL", commentId).
+		Updates(map[string]interface{}{
+			"deleted_at":    time.Now(),
+			"deleted_by_id": deletedById,
+		})
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete comment %d: %w", commentId, result.Error)
 	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("comment %d: %w", commentId, core.ErrNotFound)
+	}
 	return nil
 }
 
 func (repo *CommentRepository) GetCommentById(commentId int64) (*models.Comment, error) {
 	comment := models.Comment{ID: commentId}
-	result := repo.db.First(&comment)
+	result := repo.db.Preload("Author").First(&comment)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -43,11 +57,353 @@ func (repo *CommentRepository) GetCommentById(commentId int64) (*models.Comment,
 	return &comment, nil
 }
 
-func (repo *CommentRepository) GetEventComments(EventID int64) ([]models.Comment, error) {
-	var comments []models.Comment
-	result := repo.db.Where("event_id = ?", EventID).Find(&comments)
+// GetThreads returns one page of the event's top-level comments, see visibleComments for which are listed.
+func (repo *CommentRepository) GetThreads(EventID int64, viewerID int64, all bool, query requests.CommentQuery) (*models.CommentPage, error) {
+	db := visibleComments(repo.db.Where("event_id = ? AND parent_id IS NULL", EventID), viewerID, all)
+	if !all {
+		// Deleted threads nobody is left talking in aren't shown, skip them here so pages stay full
+		db = db.Where(`(deleted_at IS NULL OR EXISTS (
+			SELECT 1 FROM comments replies WHERE replies.root_id = comments.id AND replies.deleted_at IS NULL
+			AND (replies.status = ? OR (replies.status = ? AND replies.user_id = ?))))`,
+			models.CommentVisible, models.CommentPending, viewerID)
+	}
+
+	direction, comparison := "DESC", "<"
+	if query.Order == "oldest" {
+		direction, comparison = "ASC", ">"
+	}
+	if query.Cursor != "" {
+		after, id, err := decodeCommentCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where(fmt.Sprintf("(created_at, id) %s (?, ?)", comparison), after, id)
+	}
+
+	limit := query.PageSize()
+	comments := []models.Comment{}
+	result := db.
+		Preload("Author").
+		Order(fmt.Sprintf("created_at %s, id %s", direction, direction)).
+		Limit(limit + 1).
+		Find(&comments)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to get comments for event %d: %w", EventID, result.Error)
 	}
+	return commentPage(comments, limit), nil
+}
+
+// GetReplies returns the first perThread+1 replies of each of the given threads, oldest first.
+// The extra reply tells the caller the thread has more.
+func (repo *CommentRepository) GetReplies(rootIDs []int64, viewerID int64, all bool, perThread int) ([]models.Comment, error) {
+	replies := []models.Comment{}
+	if len(rootIDs) == 0 {
+		return replies, nil
+	}
+	ranked := visibleComments(repo.db.Model(&models.Comment{}).Where("root_id IN ?", rootIDs), viewerID, all).
+		Select("comments.*, row_number() OVER (PARTITION BY root_id ORDER BY created_at, id) AS thread_position")
+	result := repo.db.Table("(?) AS comments", ranked).
+		Where("thread_position <= ?", perThread+1).
+		Preload("Author").
+		Order("created_at, id").
+		Find(&replies)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get replies: %w", result.Error)
+	}
+	return replies, nil
+}
+
+// GetThreadReplies returns a page of the thread's replies, oldest first. Unless all comments are wanted
+// it lists the replies the thread shows the viewer: replies below a comment they can't see are left out,
+// and deleted replies are only kept as placeholders while a reply below them is still there.
+func (repo *CommentRepository) GetThreadReplies(rootID int64, viewerID int64, all bool, query requests.CommentQuery) (*models.CommentPage, error) {
+	db := repo.db.Where("root_id = ?", rootID)
+	if !all {
+		db = db.Where("id IN (?)", repo.db.Raw(`
+			WITH RECURSIVE shown AS (
+				SELECT id, parent_id, deleted_at FROM comments
+				WHERE parent_id = ? AND (status = ? OR (status = ? AND user_id = ?))
+				UNION ALL
+				SELECT c.id, c.parent_id, c.deleted_at FROM comments c JOIN shown ON c.parent_id = shown.id
+				WHERE c.status = ? OR (c.status = ? AND c.user_id = ?)
+			), kept AS (
+				SELECT id, parent_id FROM shown WHERE deleted_at IS NULL
+				UNION
+				SELECT shown.id, shown.parent_id FROM shown JOIN kept ON kept.parent_id = shown.id
+			)
+			SELECT id FROM kept`,
+			rootID, models.CommentVisible, models.CommentPending, viewerID,
+			models.CommentVisible, models.CommentPending, viewerID))
+	}
+	if query.Cursor != "" {
+		after, id, err := decodeCommentCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Where("(created_at, id) > (?, ?)", after, id)
+	}
+
+	limit := query.PageSize()
+	replies := []models.Comment{}
+	result := db.
+		Preload("Author").
+		Order("created_at, id").
+		Limit(limit + 1).
+		Find(&replies)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get replies of comment %d: %w", rootID, result.Error)
+	}
+	return commentPage(replies, limit), nil
+}
+
+// commentPage cuts the limit+1 comments loaded down to a page, with a cursor if there are more.
+func commentPage(comments []models.Comment, limit int) *models.CommentPage {
+	page := &models.CommentPage{Comments: comments}
+	if len(comments) > limit {
+		page.Comments = comments[:limit]
+		page.NextCursor = commentCursor(page.Comments[limit-1])
+	}
+	return page
+}
+
+// commentCursor is the cursor of the comments listed after the given one.
+func commentCursor(comment models.Comment) string {
+	return utils.EncodeCursor(comment.CreatedAt.Format(time.RFC3339Nano), comment.ID)
+}
+
+func decodeCommentCursor(cursor string) (time.Time, int64, error) {
+	value, id, err := utils.DecodeCursor(cursor)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("%v: %w", err, core.ErrInvalidInput)
+	}
+	after, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("malformed cursor: %w", core.ErrInvalidInput)
+	}
+	return after, id, nil
+}
+
+// visibleComments limits the query to visible comments plus the viewer's own pending ones,
+// unless all comments are wanted.
+func visibleComments(db *gorm.DB, viewerID int64, all bool) *gorm.DB {
+	if all {
+		return db
+	}
+	return db.Where("(status = ? OR (status = ? AND user_id = ?))", models.CommentVisible, models.CommentPending, viewerID)
+}
+
+// Edit replaces the comment's content, keeping the previous content in its history.
+// The new content has to pass moderation again.
+func (repo *CommentRepository) Edit(commentId int64, content string, editorId int64) (*models.Comment, error) {
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		var comment models.Comment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&comment, commentId).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return fmt.Errorf("comment %d: %w", commentId, core.ErrNotFound)
+			}
+			return fmt.Errorf("failed to get comment %d: %w", commentId, err)
+		}
+		if comment.IsDeleted() {
+			return fmt.Errorf("comment %d is deleted: %w", commentId, core.ErrConflict)
+		}
+
+		now := time.Now()
+		edit := models.CommentEdit{CommentID: commentId, Content: comment.Content, EditedByID: editorId, EditedAt: now}
+		if err := tx.Omit(clause.Associations).Create(&edit).Error; err != nil {
+			return fmt.Errorf("failed to save history of comment %d: %w", commentId, err)
+		}
+
+		err = tx.Model(&models.Comment{}).Where("id = ?", commentId).Updates(map[string]interface{}{
+			"content":             content,
+			"edited":              true,
+			"edited_at":           now,
+			"status":              models.CommentPending,
+			"visible":             false,
+			"moderation_attempts": 0,
+			"moderation_error":    "",
+			"next_moderation_at":  nil,
+			"moderated_at":        nil,
+			"reviewed_by_id":      nil,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to edit comment %d: %w", commentId, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repo.GetCommentById(commentId)
+}
+
+// GetEdits lists the comment's previous contents, newest first.
+func (repo *CommentRepository) GetEdits(commentId int64) ([]models.CommentEdit, error) {
+	edits := []models.CommentEdit{}
+	result := repo.db.Where("comment_id = ?", commentId).Order("edited_at DESC, id DESC").Find(&edits)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get history of comment %d: %w", commentId, result.Error)
+	}
+	return edits, nil
+}
+
+// AddReaction adds the user's emoji to the comment, adding it twice changes nothing.
+func (repo *CommentRepository) AddReaction(commentId int64, userId int64, emoji string) error {
+	reaction := models.CommentReaction{CommentID: commentId, UserID: userId, Emoji: emoji}
+	err := repo.db.Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(&reaction).Error
+	if err != nil {
+		return fmt.Errorf("failed to react to comment %d: %w", commentId, err)
+	}
+	return nil
+}
+
+func (repo *CommentRepository) RemoveReaction(commentId int64, userId int64, emoji string) error {
+	result := repo.db.Where("comment_id = ? AND user_id = ? AND emoji = ?", commentId, userId, emoji).Delete(&models.CommentReaction{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove reaction from comment %d: %w", commentId, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no %s reaction on comment %d: %w", emoji, commentId, core.ErrNotFound)
+	}
+	return nil
+}
+
+// GetReactionCounts counts the reactions of every comment per emoji, most used first.
+func (repo *CommentRepository) GetReactionCounts(commentIDs []int64, viewerID int64) (map[int64][]models.ReactionCount, error) {
+	counts := map[int64][]models.ReactionCount{}
+	if len(commentIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		CommentID int64
+		models.ReactionCount
+	}
+	err := repo.db.Model(&models.CommentReaction{}).
+		Select("comment_id, emoji, COUNT(*) AS count, BOOL_OR(user_id = ?) AS reacted", viewerID).
+		Where("comment_id IN ?", commentIDs).
+		Group("comment_id, emoji").
+		Order("count DESC, MIN(created_at)").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count reactions: %w", err)
+	}
+	for _, row := range rows {
+		counts[row.CommentID] = append(counts[row.CommentID], row.ReactionCount)
+	}
+	return counts, nil
+}
+
+// GetModerationQueue lists comments in the given status, oldest first.
+func (repo *CommentRepository) GetModerationQueue(status models.CommentStatus, limit int) ([]models.Comment, error) {
+	comments := []models.Comment{}
+	result := repo.db.Preload("Author").Where("status = ?", status).Order("created_at, id").Limit(limit).Find(&comments)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get %s comments: %w", status, result.Error)
+	}
 	return comments, nil
 }
+
+// DueForModeration lists pending comments whose next moderation attempt is due.
+func (repo *CommentRepository) DueForModeration(maxAttempts, limit int) ([]int64, error) {
+	var ids []int64
+	err := repo.db.Model(&models.Comment{}).
+		Where("status = ? AND moderation_attempts < ?", models.CommentPending, maxAttempts).
+		Where("next_moderation_at IS NULL OR next_moderation_at <= ?", time.Now()).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments due for moderation: %w", err)
+	}
+	return ids, nil
+}
+
+// ClaimForModeration reserves a due pending comment for one worker until the lease ends, so workers
+// of other instances skip it. It returns nil when the comment isn't due or another worker claimed it.
+func (repo *CommentRepository) ClaimForModeration(commentId int64, maxAttempts int, lease time.Duration) (*models.Comment, error) {
+	now := time.Now()
+	result := repo.db.Model(&models.Comment{}).
+		Where("id = ? AND status = ? AND moderation_attempts < ?", commentId, models.CommentPending, maxAttempts).
+		Where("next_moderation_at IS NULL OR next_moderation_at <= ?", now).
+		Update("next_moderation_at", now.Add(lease))
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to claim comment %d: %w", commentId, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return repo.GetCommentById(commentId)
+}
+
+// SaveModeration stores the moderator's outcome, unless an admin decided on the comment in the meantime.
+func (repo *CommentRepository) SaveModeration(comment *models.Comment) error {
+	err := repo.db.Model(&models.Comment{}).
+		Where("id = ? AND status = ?", comment.ID, models.CommentPending).
+		Updates(map[string]interface{}{
+			"score":              comment.Score,
+			"scores":             comment.Scores,
+			"status":             comment.Status,
+			"visible":            comment.Status == models.CommentVisible,
+			"moderation_error":   "",
+			"next_moderation_at": nil,
+			"moderated_at":       time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to save moderation of comment %d: %w", comment.ID, err)
+	}
+	return nil
+}
+
+// RecordModerationFailure counts a failed attempt, next is when to retry or nil to give up
+// and leave the comment to the admins.
+func (repo *CommentRepository) RecordModerationFailure(commentId int64, attempts int, next *time.Time, reason string) error {
+	err := repo.db.Model(&models.Comment{}).
+		Where("id = ? AND status = ?", commentId, models.CommentPending).
+		Updates(map[string]interface{}{
+			"moderation_attempts": attempts,
+			"moderation_error":    reason,
+			"next_moderation_at":  next,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to record moderation failure of comment %d: %w", commentId, err)
+	}
+	return nil
+}
+
+// Override lets an admin show or hide a comment whatever the moderator decided. The decision goes in the
+// moderation audit log, so dismissing the comment's reports later doesn't undo it.
+func (repo *CommentRepository) Override(commentId int64, status models.CommentStatus, reviewerId int64) (*models.Comment, error) {
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Comment{}).
+			Where("id = ?", commentId).
+			Updates(map[string]interface{}{
+				"status":             status,
+				"visible":            status == models.CommentVisible,
+				"reviewed_by_id":     reviewerId,
+				"next_moderation_at": nil,
+				"moderated_at":       time.Now(),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update comment %d: %w", commentId, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("comment %d: %w", commentId, core.ErrNotFound)
+		}
+
+		action := models.ActionApprove
+		if status == models.CommentHidden {
+			action = models.ActionHide
+		}
+		return logAction(tx, &models.ModerationAction{
+			Action:     action,
+			TargetType: models.ReportComment,
+			TargetID:   commentId,
+			ActorID:    &reviewerId,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repo.GetCommentById(commentId)
+}
@@ -2,30 +2,36 @@ package repository
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"mime/multipart"
+	"time"
 
 	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
 	"github.com/wmfadel/wander-base/pkg/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type EventPhotoRepository struct {
 	db      *gorm.DB
-	storage *utils.Storage
+	storage utils.Storage
 }
 
-func NewEventPhotoRepository(db *gorm.DB, storage *utils.Storage) *EventPhotoRepository {
+func NewEventPhotoRepository(db *gorm.DB, storage utils.Storage) *EventPhotoRepository {
 	return &EventPhotoRepository{db: db, storage: storage}
 }
 
-func (repo *EventPhotoRepository) AddPhotos(eventID int64, photos []*multipart.FileHeader) error {
+// AddPhotos uploads the photos of the given uploader, they start in the given status.
+func (repo *EventPhotoRepository) AddPhotos(eventID, uploaderID int64, status models.PhotoStatus, photos []*multipart.FileHeader) error {
 	var eventPhotos []models.EventPhoto
 	var uploadErrors []error
 
 	// Process each photo, collecting successful uploads and logging failures
 	for _, photo := range photos {
-		url, err := repo.storage.UploadFile(photo, "events", eventID)
+		variants, err := utils.UploadImage(repo.storage, photo, "events", eventID)
 		if err != nil {
 			// Log the error and continue
 			log.Printf("Failed to upload photo for event %d: %v", eventID, err)
@@ -35,20 +41,52 @@ func (repo *EventPhotoRepository) AddPhotos(eventID int64, photos []*multipart.F
 
 		// Add successful upload to the list
 		eventPhotos = append(eventPhotos, models.EventPhoto{
-			EventID: eventID,
-			URL:     url,
+			EventID:      eventID,
+			URL:          variants.Original.URL,
+			Variants:     variants,
+			Status:       status,
+			UploadedByID: &uploaderID,
 		})
 	}
 
 	// If no photos were successfully uploaded, return an error with details
 	if len(eventPhotos) == 0 && len(uploadErrors) > 0 {
-		return fmt.Errorf("no photos uploaded successfully: %v errors occurred", len(uploadErrors))
+		return fmt.Errorf("no photos uploaded successfully, %v errors occurred, first: %w", len(uploadErrors), uploadErrors[0])
 	}
 
-	// Save successfully uploaded photos to the database
+	// Save successfully uploaded photos to the database, after the event's other photos.
+	// The event stays locked until they are saved, so concurrent uploads don't share positions.
 	if len(eventPhotos) > 0 {
-		if err := repo.db.Create(&eventPhotos).Error; err != nil {
-			return fmt.Errorf("failed to create event photos: %w", err)
+		err := repo.db.Transaction(func(tx *gorm.DB) error {
+			event, err := lockEvent(tx, eventID)
+			if err != nil {
+				return err
+			}
+			if event.Status.Final() {
+				return fmt.Errorf("event %d is %s: %w", eventID, event.Status, core.ErrConflict)
+			}
+			var last int
+			err = tx.Model(&models.EventPhoto{}).
+				Where("event_id = ?", eventID).
+				Select("COALESCE(MAX(position), 0)").
+				Scan(&last).Error
+			if err != nil {
+				return fmt.Errorf("failed to get the last photo position of event %d: %w", eventID, err)
+			}
+			for i := range eventPhotos {
+				eventPhotos[i].Position = last + 1 + i
+			}
+
+			if err := tx.Create(&eventPhotos).Error; err != nil {
+				return fmt.Errorf("failed to create event photos: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			for _, photo := range eventPhotos {
+				utils.DeleteImage(repo.storage, photo.Variants)
+			}
+			return err
 		}
 	}
 
@@ -62,37 +100,286 @@ func (repo *EventPhotoRepository) AddPhotos(eventID int64, photos []*multipart.F
 	return nil
 }
 
-func (repo *EventPhotoRepository) GetPhotos(eventID int64) ([]models.EventPhoto, error) {
+func (repo *EventPhotoRepository) GetPhotos(eventID int64, status models.PhotoStatus) ([]models.EventPhoto, error) {
+	photos := []models.EventPhoto{}
+
+	query := repo.db.Where("event_id = ?", eventID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	err := query.
+		Preload("Photographer").
+		Preload("Album").
+		Order("position, uploaded_at, id").
+		Find(&photos).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get photos of event %d with status %s: %w", eventID, status, err)
+	}
+
+	return photos, nil
+}
+
+// ReviewPhotos approves or rejects pending photos and records the review.
+// Nothing is changed if any of the photos is missing, belongs to another event or was already reviewed.
+// The reviewed photos are returned.
+func (repo *EventPhotoRepository) ReviewPhotos(eventID int64, photoIDs []int64, to models.PhotoStatus, review models.PhotoReview) ([]models.EventPhoto, error) {
 	var photos []models.EventPhoto
-	repo.db.Where("event_id = ?", eventID).Find(&photos)
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("event_id = ? AND id IN ?", eventID, photoIDs).
+			Find(&photos).Error
+		if err != nil {
+			return fmt.Errorf("failed to get photos of event %d: %w", eventID, err)
+		}
+
+		byID := make(map[int64]models.EventPhoto, len(photos))
+		for _, photo := range photos {
+			byID[photo.ID] = photo
+		}
+		for _, photoID := range photoIDs {
+			photo, ok := byID[photoID]
+			if !ok {
+				return fmt.Errorf("no photo %d in event %d: %w", photoID, eventID, core.ErrNotFound)
+			}
+			if photo.Status != models.PhotoPending {
+				return fmt.Errorf("photo %d is %s, expected %s: %w", photoID, photo.Status, models.PhotoPending, core.ErrConflict)
+			}
+		}
 
+		err = tx.Model(&models.EventPhoto{}).
+			Where("event_id = ? AND id IN ?", eventID, photoIDs).
+			Updates(map[string]interface{}{
+				"status":         to,
+				"reviewed_by_id": review.ReviewedByID,
+				"reviewed_at":    time.Now(),
+				"review_reason":  review.Reason,
+			}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update photos of event %d to %s: %w", eventID, to, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return photos, nil
 }
 
-func (repo *EventPhotoRepository) DeletePhotos(eventID int64, urls []string) error {
+// UpdatePhoto changes the photo's caption and album.
+func (repo *EventPhotoRepository) UpdatePhoto(eventID, photoID int64, patch requests.PatchPhotoRequest) (*models.EventPhoto, error) {
+	photo, err := repo.getPhoto(repo.db, eventID, photoID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if patch.Caption != nil {
+		updates["caption"] = *patch.Caption
+	}
+	if patch.AlbumID != nil {
+		if *patch.AlbumID == 0 {
+			updates["album_id"] = nil
+		} else {
+			var count int64
+			err := repo.db.Model(&models.PhotoAlbum{}).Where("id = ? AND event_id = ?", *patch.AlbumID, eventID).Count(&count).Error
+			if err != nil {
+				return nil, fmt.Errorf("failed to check album %d: %w", *patch.AlbumID, err)
+			}
+			if count == 0 {
+				return nil, fmt.Errorf("no album %d in event %d: %w", *patch.AlbumID, eventID, core.ErrInvalidInput)
+			}
+			updates["album_id"] = *patch.AlbumID
+		}
+	}
+	if len(updates) == 0 {
+		return photo, nil
+	}
+
+	if err := repo.db.Model(photo).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update photo %d: %w", photoID, err)
+	}
+	return repo.getPhoto(repo.db, eventID, photoID)
+}
+
+// ReorderPhotos moves the given photos to the front in the given order, the other photos keep their order after them.
+func (repo *EventPhotoRepository) ReorderPhotos(eventID int64, photoIDs []int64) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		// Locking the event keeps photos uploaded meanwhile out of the new order
+		if _, err := lockEvent(tx, eventID); err != nil {
+			return err
+		}
+		var photos []models.EventPhoto
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("event_id = ?", eventID).
+			Order("position, uploaded_at, id").
+			Find(&photos).Error
+		if err != nil {
+			return fmt.Errorf("failed to get photos of event %d: %w", eventID, err)
+		}
+
+		byID := make(map[int64]models.EventPhoto, len(photos))
+		for _, photo := range photos {
+			byID[photo.ID] = photo
+		}
+		ordered := make([]models.EventPhoto, 0, len(photos))
+		listed := make(map[int64]bool, len(photoIDs))
+		for _, photoID := range photoIDs {
+			photo, ok := byID[photoID]
+			if !ok {
+				return fmt.Errorf("no photo %d in event %d: %w", photoID, eventID, core.ErrNotFound)
+			}
+			if listed[photoID] {
+				return fmt.Errorf("photo %d is listed twice: %w", photoID, core.ErrInvalidInput)
+			}
+			listed[photoID] = true
+			ordered = append(ordered, photo)
+		}
+		for _, photo := range photos {
+			if !listed[photo.ID] {
+				ordered = append(ordered, photo)
+			}
+		}
+
+		for i, photo := range ordered {
+			if photo.Position == i+1 {
+				continue
+			}
+			if err := tx.Model(&models.EventPhoto{}).Where("id = ?", photo.ID).Update("position", i+1).Error; err != nil {
+				return fmt.Errorf("failed to move photo %d: %w", photo.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// SetCover makes the approved photo the event's cover, replacing the previous one.
+func (repo *EventPhotoRepository) SetCover(eventID, photoID int64) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		photo, err := repo.getPhoto(tx, eventID, photoID)
+		if err != nil {
+			return err
+		}
+		if photo.Status != models.PhotoApproved {
+			return fmt.Errorf("photo %d is %s, only approved photos can be the cover: %w", photoID, photo.Status, core.ErrConflict)
+		}
+
+		err = tx.Model(&models.EventPhoto{}).Where("event_id = ? AND is_cover", eventID).Update("is_cover", false).Error
+		if err != nil {
+			return fmt.Errorf("failed to clear the cover of event %d: %w", eventID, err)
+		}
+		if err := tx.Model(photo).Update("is_cover", true).Error; err != nil {
+			return fmt.Errorf("failed to set photo %d as cover: %w", photoID, err)
+		}
+		return nil
+	})
+}
+
+// ClearCover leaves the event without a cover photo.
+func (repo *EventPhotoRepository) ClearCover(eventID int64) error {
+	err := repo.db.Model(&models.EventPhoto{}).Where("event_id = ? AND is_cover", eventID).Update("is_cover", false).Error
+	if err != nil {
+		return fmt.Errorf("failed to clear the cover of event %d: %w", eventID, err)
+	}
+	return nil
+}
+
+// DeletePhotos deletes the event's photos with the given IDs along with their files.
+func (repo *EventPhotoRepository) DeletePhotos(eventID int64, photoIDs []int64) error {
+	if len(photoIDs) == 0 {
+		return nil // Nothing to delete
+	}
+
+	// Load the photos first, every variant's file has to go along with them
+	var photos []models.EventPhoto
+	if err := repo.db.Where("event_id = ? AND id IN ?", eventID, photoIDs).Find(&photos).Error; err != nil {
+		return fmt.Errorf("failed to get photos of event %d: %w", eventID, err)
+	}
+	found := make(map[int64]bool, len(photos))
+	for _, photo := range photos {
+		found[photo.ID] = true
+	}
+	for _, photoID := range photoIDs {
+		if !found[photoID] {
+			return fmt.Errorf("no photo %d in event %d: %w", photoID, eventID, core.ErrNotFound)
+		}
+	}
+
+	return repo.deletePhotos(eventID, photos)
+}
+
+// DeletePhotosByURL deletes the event's photos whose original variant has one of the given URLs.
+func (repo *EventPhotoRepository) DeletePhotosByURL(eventID int64, urls []string) error {
 	if len(urls) == 0 {
 		return nil // Nothing to delete
 	}
 
+	var photos []models.EventPhoto
+	if err := repo.db.Where("event_id = ? AND url IN ?", eventID, urls).Find(&photos).Error; err != nil {
+		return fmt.Errorf("failed to get photos of event %d: %w", eventID, err)
+	}
+	if len(photos) == 0 {
+		return fmt.Errorf("no photos found for event %d matching provided URLs: %w", eventID, core.ErrNotFound)
+	}
+
+	return repo.deletePhotos(eventID, photos)
+}
+
+func (repo *EventPhotoRepository) deletePhotos(eventID int64, photos []models.EventPhoto) error {
+	ids := make([]int64, 0, len(photos))
+	for _, photo := range photos {
+		ids = append(ids, photo.ID)
+	}
+
 	// Delete from database using GORM
-	result := repo.db.Where("event_id = ? AND photo_url IN ?", eventID, urls).Delete(&models.EventPhoto{})
+	result := repo.db.Where("event_id = ? AND id IN ?", eventID, ids).Delete(&models.EventPhoto{})
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete photos from event %d: %w", eventID, result.Error)
 	}
 
-	// Check if any rows were affected
-	rowsAffected := result.RowsAffected
-	if rowsAffected == 0 {
-		return fmt.Errorf("no photos found for event %d matching provided URLs", eventID)
+	// Delete files from storage, ignoring failures since the DB is already updated
+	repo.DeleteFiles(photos)
+
+	return nil
+}
+
+// OpenPhoto opens the original variant's file of the photo.
+func (repo *EventPhotoRepository) OpenPhoto(photo models.EventPhoto) (io.ReadCloser, error) {
+	key, err := repo.storage.KeyFromURL(photo.URL)
+	if err != nil {
+		return nil, err
+	}
+	return repo.storage.Open(key)
+}
+
+// DeleteFiles deletes every variant's file of the photos, logging failures.
+func (repo *EventPhotoRepository) DeleteFiles(photos []models.EventPhoto) {
+	for _, photo := range photos {
+		utils.DeleteImage(repo.storage, photo.Variants)
 	}
+}
 
-	// Delete files from storage, ignoring failures
-	for _, url := range urls {
-		if err := repo.storage.DeleteFile(url); err != nil {
-			log.Printf("Warning: failed to delete file %s from storage: %v", url, err)
-			// Continue despite failure—DB is already updated
+// GetPhotoById returns the photo whatever its event and status, nil if there is none.
+func (repo *EventPhotoRepository) GetPhotoById(photoID int64) (*models.EventPhoto, error) {
+	var photo models.EventPhoto
+	err := repo.db.Preload("Photographer").First(&photo, "id = ?", photoID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
 		}
+		return nil, fmt.Errorf("failed to get photo %d: %w", photoID, err)
 	}
+	return &photo, nil
+}
 
-	return nil
+func (repo *EventPhotoRepository) getPhoto(db *gorm.DB, eventID, photoID int64) (*models.EventPhoto, error) {
+	var photo models.EventPhoto
+	err := db.Preload("Photographer").Where("event_id = ? AND id = ?", eventID, photoID).First(&photo).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("no photo %d in event %d: %w", photoID, eventID, core.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get photo %d: %w", photoID, err)
+	}
+	return &photo, nil
 }
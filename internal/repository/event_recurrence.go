@@ -0,0 +1,429 @@
+package repository
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
+	"github.com/wmfadel/wander-base/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaterializeOccurrences creates the missing occurrence rows of every series within [from, to],
+// at most limit per series. Occurrences are real events so they can be registered for and edited on their own.
+func (repo *EventRepository) MaterializeOccurrences(from, to time.Time, limit int) error {
+	masters := []models.Event{}
+	if err := repo.db.Where("rrule <> '' AND date_time <= ?", to).Find(&masters).Error; err != nil {
+		return fmt.Errorf("failed to get recurring events: %w", err)
+	}
+
+	for _, master := range masters {
+		if err := repo.materializeSeries(&master, from, to, limit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MaterializeSeries creates the missing occurrence rows of one series within [from, to], at most limit.
+func (repo *EventRepository) MaterializeSeries(masterID int64, from, to time.Time, limit int) error {
+	var master models.Event
+	if err := repo.db.Where("id = ? AND rrule <> ''", masterID).First(&master).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("recurring event %d: %w", masterID, core.ErrNotFound)
+		}
+		return fmt.Errorf("failed to get event %d: %w", masterID, err)
+	}
+	return repo.materializeSeries(&master, from, to, limit)
+}
+
+func (repo *EventRepository) materializeSeries(master *models.Event, from, to time.Time, limit int) error {
+	rule, err := utils.ParseRRule(master.RRule)
+	if err != nil {
+		log.Printf("skipping event %d with invalid rrule %q: %v", master.ID, master.RRule, err)
+		return nil
+	}
+	exdates, err := utils.ParseExDates(master.ExDates)
+	if err != nil {
+		log.Printf("skipping event %d with invalid exdates %q: %v", master.ID, master.ExDates, err)
+		return nil
+	}
+
+	starts := rule.Occurrences(master.SeriesStart(), from, to, exdates, limit)
+	if len(starts) == 0 {
+		return nil
+	}
+
+	existing := []time.Time{}
+	err = repo.db.Model(&models.Event{}).
+		Where("series_id = ? AND occurrence_at IN ?", master.ID, starts).
+		Pluck("occurrence_at", &existing).Error
+	if err != nil {
+		return fmt.Errorf("failed to get occurrences of event %d: %w", master.ID, err)
+	}
+
+	for _, start := range starts {
+		if containsTime(existing, start) {
+			continue
+		}
+		if err := repo.createOccurrence(master, start); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createOccurrence inserts one occurrence along with the master's co-hosts and activities.
+// Concurrent requests may materialize the same occurrence, the loser simply does nothing.
+func (repo *EventRepository) createOccurrence(master *models.Event, start time.Time) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		occurrence := master.Occurrence(start)
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&occurrence)
+		if result.Error != nil {
+			return fmt.Errorf("failed to create occurrence of event %d at %s: %w", master.ID, start, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		return copySeriesDetails(tx, master.ID, occurrence.ID)
+	})
+}
+
+// UpdateSeries applies the patch to a series from its occurrence starting at from onwards.
+// Editing from the master's own start changes the whole series in place. Otherwise the series is split:
+// the master ends before from and a new master carries the rule on from there.
+// Materialized occurrences follow the edit and move in time along with the series, by the same
+// number of days and local clock time so the move is the same on both sides of a DST change.
+func (repo *EventRepository) UpdateSeries(masterID int64, from time.Time, patch requests.PatchEvent) (*models.Event, error) {
+	var target models.Event
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		master, err := lockEvent(tx, masterID)
+		if err != nil {
+			return err
+		}
+		if !master.IsRecurring() {
+			return fmt.Errorf("event %d is not recurring: %w", masterID, core.ErrInvalidInput)
+		}
+
+		target = *master
+		if from.After(master.DateTime) {
+			split, err := splitSeries(tx, master, from)
+			if err != nil {
+				return err
+			}
+			target = *split
+		}
+
+		delta, to := time.Duration(0), from
+		if patch.DateTime != nil {
+			delta = patch.DateTime.Sub(from)
+			to = patch.DateTime.In(target.SeriesStart().Location())
+		}
+
+		updates := eventDetailUpdates(patch)
+		if patch.DateTime != nil {
+			updates["date_time"] = *patch.DateTime
+			if target.EndDateTime != nil {
+				updates["end_date_time"] = target.EndDateTime.Add(delta)
+			}
+		}
+		if patch.EndDateTime != nil {
+			updates["end_date_time"] = *patch.EndDateTime
+		}
+		if patch.RRule != nil {
+			updates["rrule"] = *patch.RRule
+		}
+		if patch.ExDates != nil {
+			updates["ex_dates"] = *patch.ExDates
+		} else if delta != 0 {
+			exdates, err := utils.ParseExDates(target.ExDates)
+			if err != nil {
+				return fmt.Errorf("event %d has invalid exdates: %w", target.ID, err)
+			}
+			for i := range exdates {
+				exdates[i] = utils.ShiftWallClock(exdates[i], from, to)
+			}
+			updates["ex_dates"] = utils.FormatExDates(exdates)
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&models.Event{}).Where("id = ?", target.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update event %d: %w", target.ID, err)
+			}
+		}
+		if err := tx.First(&target, target.ID).Error; err != nil {
+			return fmt.Errorf("failed to reload event %d: %w", target.ID, err)
+		}
+		if err := checkItineraryWindow(tx, target.ID, patch); err != nil {
+			return err
+		}
+
+		if err := moveOccurrences(tx, master.ID, &target, from, to, patch); err != nil {
+			return err
+		}
+		if patch.ChangesRecurrence() || delta != 0 {
+			return pruneOccurrences(tx, &target)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// DeleteOccurrence deletes a single occurrence and excludes its start from the series,
+// so it isn't materialized again. The photos of the occurrence are returned so their files can be cleaned up.
+func (repo *EventRepository) DeleteOccurrence(occurrence *models.Event) ([]models.EventPhoto, error) {
+	var photos []models.EventPhoto
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		master, err := lockEvent(tx, *occurrence.SeriesID)
+		if err != nil {
+			return err
+		}
+		exdates, err := utils.ParseExDates(master.ExDates)
+		if err != nil {
+			return fmt.Errorf("event %d has invalid exdates: %w", master.ID, err)
+		}
+		exdates = append(exdates, *occurrence.OccurrenceAt)
+
+		err = tx.Model(&models.Event{}).Where("id = ?", master.ID).Update("ex_dates", utils.FormatExDates(exdates)).Error
+		if err != nil {
+			return fmt.Errorf("failed to exclude occurrence from event %d: %w", master.ID, err)
+		}
+		photos, err = deleteEventPhotos(tx, []int64{occurrence.ID})
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Event{}, occurrence.ID).Error; err != nil {
+			return fmt.Errorf("failed to delete event %d: %w", occurrence.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return photos, nil
+}
+
+// EndSeries deletes the series' occurrences starting at from or later and ends the rule before from.
+// Ending a series at its first occurrence deletes it entirely. The photos of the deleted events are
+// returned so their files can be cleaned up.
+func (repo *EventRepository) EndSeries(masterID int64, from time.Time) ([]models.EventPhoto, error) {
+	photos := []models.EventPhoto{}
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		master, err := lockEvent(tx, masterID)
+		if err != nil {
+			return err
+		}
+
+		var ids []int64
+		err = tx.Model(&models.Event{}).Where("series_id = ? AND occurrence_at >= ?", masterID, from).Pluck("id", &ids).Error
+		if err != nil {
+			return fmt.Errorf("failed to get occurrences of event %d: %w", masterID, err)
+		}
+
+		if !from.After(master.DateTime) {
+			ids = append(ids, master.ID)
+		} else if err := endRule(tx, master, from); err != nil {
+			return err
+		}
+
+		if len(ids) > 0 {
+			if photos, err = deleteEventPhotos(tx, ids); err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.Event{}, ids).Error; err != nil {
+				return fmt.Errorf("failed to delete occurrences of event %d: %w", masterID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return photos, nil
+}
+
+// splitSeries ends the master's rule before from and creates the master that continues it from there.
+func splitSeries(tx *gorm.DB, master *models.Event, from time.Time) (*models.Event, error) {
+	rule, err := utils.ParseRRule(master.RRule)
+	if err != nil {
+		return nil, fmt.Errorf("event %d has an invalid rrule: %w", master.ID, err)
+	}
+	exdates, err := utils.ParseExDates(master.ExDates)
+	if err != nil {
+		return nil, fmt.Errorf("event %d has invalid exdates: %w", master.ID, err)
+	}
+
+	if rule.Count > 0 {
+		// exdates count towards COUNT, so they are left out while counting the earlier occurrences
+		earlier := rule.Occurrences(master.SeriesStart(), master.DateTime, from.Add(-time.Nanosecond), nil, 0)
+		rule.Count -= len(earlier)
+		if rule.Count < 1 {
+			return nil, fmt.Errorf("event %d has no occurrences left at %s: %w", master.ID, from, core.ErrInvalidInput)
+		}
+	}
+
+	laterExDates := []time.Time{}
+	for _, exdate := range exdates {
+		if !exdate.Before(from) {
+			laterExDates = append(laterExDates, exdate)
+		}
+	}
+
+	next := master.Occurrence(from)
+	next.SeriesID, next.OccurrenceAt = nil, nil
+	next.RRule = rule.String()
+	next.ExDates = utils.FormatExDates(laterExDates)
+	if err := tx.Create(&next).Error; err != nil {
+		return nil, fmt.Errorf("failed to split event %d: %w", master.ID, err)
+	}
+	if err := copySeriesDetails(tx, master.ID, next.ID); err != nil {
+		return nil, err
+	}
+
+	if err := endRule(tx, master, from); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// endRule limits the master's rule to the occurrences before from and drops the exdates after it.
+func endRule(tx *gorm.DB, master *models.Event, from time.Time) error {
+	rule, err := utils.ParseRRule(master.RRule)
+	if err != nil {
+		return fmt.Errorf("event %d has an invalid rrule: %w", master.ID, err)
+	}
+	exdates, err := utils.ParseExDates(master.ExDates)
+	if err != nil {
+		return fmt.Errorf("event %d has invalid exdates: %w", master.ID, err)
+	}
+
+	until := from.Add(-time.Second)
+	rule.Count, rule.Until = 0, &until
+	earlierExDates := []time.Time{}
+	for _, exdate := range exdates {
+		if exdate.Before(from) {
+			earlierExDates = append(earlierExDates, exdate)
+		}
+	}
+
+	err = tx.Model(&models.Event{}).Where("id = ?", master.ID).Updates(map[string]interface{}{
+		"rrule":    rule.String(),
+		"ex_dates": utils.FormatExDates(earlierExDates),
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to end event %d: %w", master.ID, err)
+	}
+	return nil
+}
+
+// moveOccurrences hands the materialized occurrences starting at from over to the target series,
+// applying the patch's details and moving them the way the series moved from from to to.
+func moveOccurrences(tx *gorm.DB, masterID int64, target *models.Event, from, to time.Time, patch requests.PatchEvent) error {
+	// Rows are moved in the direction of the shift so none lands on a start that is still taken
+	order := "occurrence_at"
+	if to.After(from) {
+		order = "occurrence_at DESC"
+	}
+	occurrences := []models.Event{}
+	err := tx.Where("series_id = ? AND occurrence_at >= ?", masterID, from).Order(order).Find(&occurrences).Error
+	if err != nil {
+		return fmt.Errorf("failed to get occurrences of event %d: %w", masterID, err)
+	}
+
+	for _, occurrence := range occurrences {
+		updates := eventDetailUpdates(patch)
+		updates["series_id"] = target.ID
+		start := utils.ShiftWallClock(occurrence.DateTime, from, to)
+		if !to.Equal(from) {
+			updates["occurrence_at"] = utils.ShiftWallClock(*occurrence.OccurrenceAt, from, to)
+			updates["date_time"] = start
+		}
+		if patch.DateTime != nil || patch.EndDateTime != nil {
+			var end *time.Time
+			if target.EndDateTime != nil {
+				shifted := start.Add(target.EndDateTime.Sub(target.DateTime))
+				end = &shifted
+			}
+			updates["end_date_time"] = end
+		}
+		if err := tx.Model(&models.Event{}).Where("id = ?", occurrence.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update occurrence %d: %w", occurrence.ID, err)
+		}
+		if err := checkItineraryWindow(tx, occurrence.ID, patch); err != nil {
+			return err
+		}
+		if err := promoteAfterSeatChange(tx, occurrence.ID, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneOccurrences deletes the materialized occurrences the series' rule no longer generates.
+// Occurrences someone registered for are kept as standalone exceptions.
+func pruneOccurrences(tx *gorm.DB, master *models.Event) error {
+	rule, err := utils.ParseRRule(master.RRule)
+	if err != nil {
+		return fmt.Errorf("event %d has an invalid rrule: %w", master.ID, err)
+	}
+	exdates, err := utils.ParseExDates(master.ExDates)
+	if err != nil {
+		return fmt.Errorf("event %d has invalid exdates: %w", master.ID, err)
+	}
+
+	occurrences := []models.Event{}
+	if err := tx.Where("series_id = ?", master.ID).Order("occurrence_at").Find(&occurrences).Error; err != nil {
+		return fmt.Errorf("failed to get occurrences of event %d: %w", master.ID, err)
+	}
+	if len(occurrences) == 0 {
+		return nil
+	}
+
+	last := *occurrences[len(occurrences)-1].OccurrenceAt
+	valid := rule.Occurrences(master.SeriesStart(), master.DateTime, last, exdates, 0)
+	stale := []int64{}
+	for _, occurrence := range occurrences {
+		if !containsTime(valid, *occurrence.OccurrenceAt) {
+			stale = append(stale, occurrence.ID)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+
+	err = tx.Where("id IN ? AND NOT EXISTS (SELECT 1 FROM registrations r WHERE r.event_id = events.id)", stale).
+		Delete(&models.Event{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete stale occurrences of event %d: %w", master.ID, err)
+	}
+	return nil
+}
+
+func copySeriesDetails(tx *gorm.DB, fromEventID, toEventID int64) error {
+	err := tx.Exec(`INSERT INTO event_co_hosts (event_id, user_id)
+		SELECT ?, user_id FROM event_co_hosts WHERE event_id = ? ON CONFLICT DO NOTHING`, toEventID, fromEventID).Error
+	if err != nil {
+		return fmt.Errorf("failed to copy co-hosts of event %d: %w", fromEventID, err)
+	}
+	err = tx.Exec(`INSERT INTO event_activities (event_id, activity_id)
+		SELECT ?, activity_id FROM event_activities WHERE event_id = ? ON CONFLICT DO NOTHING`, toEventID, fromEventID).Error
+	if err != nil {
+		return fmt.Errorf("failed to copy activities of event %d: %w", fromEventID, err)
+	}
+	return nil
+}
+
+func containsTime(times []time.Time, t time.Time) bool {
+	for _, candidate := range times {
+		if candidate.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,9 +2,12 @@ package repository
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
 	"github.com/wmfadel/wander-base/internal/models/requests"
+	"github.com/wmfadel/wander-base/pkg/utils"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -38,7 +41,9 @@ func (repo *EventRepository) SetDestinations(destinations []models.EventDestinat
 	return repo.db.Transaction(func(tx *gorm.DB) error {
 		// Prepare new event_destination records
 		var eventDestinations []models.EventDestination
+		var destinationIDs []int64
 		for _, req := range destinations {
+			destinationIDs = append(destinationIDs, req.DestinationID)
 			eventDestinations = append(eventDestinations, models.EventDestination{
 				EventID:       eventID,
 				DestinationID: req.DestinationID,
@@ -46,9 +51,16 @@ func (repo *EventRepository) SetDestinations(destinations []models.EventDestinat
 			})
 		}
 
-		// Insert new destinations, ignoring duplicates
+		if err := ensureExist(tx, &models.Destination{}, destinationIDs, "destinations"); err != nil {
+			return err
+		}
+
+		// Insert new destinations, already added ones get their visit time updated
 		if len(eventDestinations) > 0 {
-			err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&eventDestinations).Error
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "event_id"}, {Name: "destination_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"date_time"}),
+			}).Omit("Destination").Create(&eventDestinations).Error
 			if err != nil {
 				return fmt.Errorf("failed to append destinations to event %d: %w", eventID, err)
 			}
@@ -67,9 +79,8 @@ func (repo *EventRepository) RemoveDestinations(destinationIDs []int64, eventID
 			return fmt.Errorf("failed to remove destinations from event %d: %w", eventID, result.Error)
 		}
 
-		// Optional: Check if any rows were affected (not strictly necessary unless you want to fail on no-op)
 		if result.RowsAffected == 0 {
-			return fmt.Errorf("no destinations removed for event %d (none matched the provided IDs)", eventID)
+			return fmt.Errorf("no destinations removed for event %d (none matched the provided IDs): %w", eventID, core.ErrNotFound)
 		}
 
 		return nil
@@ -78,6 +89,10 @@ func (repo *EventRepository) RemoveDestinations(destinationIDs []int64, eventID
 
 func (repo *EventRepository) AddActivities(activityIDs []int64, eventID int64) error {
 	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureExist(tx, &models.Activity{}, activityIDs, "activities"); err != nil {
+			return err
+		}
+
 		// Prepare new event_activities records
 		var eventActivities []models.EventActivities
 		for _, activityID := range activityIDs {
@@ -108,9 +123,8 @@ func (repo *EventRepository) RemoveActivities(activityIDs []int64, eventID int64
 			return fmt.Errorf("failed to remove activities from event %d: %w", eventID, result.Error)
 		}
 
-		// Optional: Check if any rows were affected
 		if result.RowsAffected == 0 {
-			return fmt.Errorf("no activities removed for event %d (none matched the provided IDs)", eventID)
+			return fmt.Errorf("no activities removed for event %d (none matched the provided IDs): %w", eventID, core.ErrNotFound)
 		}
 
 		return nil
@@ -126,35 +140,152 @@ func (repo *EventRepository) Update(event *models.Event) error {
 	return nil
 }
 
-func (repo *EventRepository) Delete(eventId int64) error {
-	result := repo.db.Delete(&models.Event{}, eventId)
-
-	if result.Error != nil {
-		return fmt.Errorf("failed to delete event %d: %w", eventId, result.Error)
+// Delete deletes the event along with its photos, returning the photos so their files can be cleaned up
+// once the rows are gone.
+func (repo *EventRepository) Delete(eventId int64) ([]models.EventPhoto, error) {
+	var photos []models.EventPhoto
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		photos, err = deleteEventPhotos(tx, []int64{eventId})
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Event{}, eventId).Error; err != nil {
+			return fmt.Errorf("failed to delete event %d: %w", eventId, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return photos, nil
+}
 
-	return nil
+// deleteEventPhotos deletes the photo rows of the events, which reference them, and returns them.
+func deleteEventPhotos(tx *gorm.DB, eventIDs []int64) ([]models.EventPhoto, error) {
+	photos := []models.EventPhoto{}
+	if err := tx.Where("event_id IN ?", eventIDs).Find(&photos).Error; err != nil {
+		return nil, fmt.Errorf("failed to get photos of events %v: %w", eventIDs, err)
+	}
+	if err := tx.Where("event_id IN ?", eventIDs).Delete(&models.EventPhoto{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete photos of events %v: %w", eventIDs, err)
+	}
+	return photos, nil
 }
 
-func (repo *EventRepository) GetAllEvents() ([]models.Event, error) {
-	var events = []models.Event{}
+// GetAllEvents searches, filters and sorts events and returns one page of them.
+// Pages are keyset based: the cursor holds the sort key and id of the previous page's last event.
+// Anonymous users and users who can't manage events only get published events, organizers also get their own.
+func (repo *EventRepository) GetAllEvents(query requests.EventQuery, viewer *models.User) (*models.EventPage, error) {
+	// Series masters are templates, their materialized occurrences are listed instead
+	db := repo.db.Model(&models.Event{}).Where("events.rrule = ''")
+
+	switch {
+	case viewer != nil && viewer.HasPermission(models.PermissionEventsEditAny):
+	case viewer != nil && viewer.HasPermission(models.PermissionEventsEditOwn):
+		db = db.Where(`(events.status = ? OR events.user_id = ? OR EXISTS (
+			SELECT 1 FROM event_co_hosts h WHERE h.event_id = events.id AND h.user_id = ?))`,
+			models.EventPublished, viewer.ID, viewer.ID)
+	default:
+		db = db.Where("events.status = ?", models.EventPublished)
+	}
+	if query.Status != "" {
+		db = db.Where("events.status = ?", query.Status)
+	}
+
+	if query.Search != "" {
+		db = db.Where("events.search_vector @@ websearch_to_tsquery('simple', ?)", query.Search)
+	}
+	if !query.From.IsZero() {
+		db = db.Where("events.date_time >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		db = db.Where("events.date_time <= ?", query.To)
+	}
+	if query.Activity != "" {
+		db = db.Where(`EXISTS (
+			SELECT 1 FROM event_activities ea
+			JOIN activities a ON a.id = ea.activity_id
+			WHERE ea.event_id = events.id AND a.slug = ?)`, query.Activity)
+	}
+	if query.DestinationID != 0 {
+		db = db.Where(`EXISTS (
+			SELECT 1 FROM event_destinations ed
+			WHERE ed.event_id = events.id AND ed.destination_id = ?)`, query.DestinationID)
+	}
 
-	result := repo.db.Find(&events)
+	column, descending := "date_time", false
+	switch query.Sort {
+	case "-date":
+		descending = true
+	case "name":
+		column = "name"
+	case "-name":
+		column, descending = "name", true
+	}
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if query.Cursor != "" {
+		value, id, err := utils.DecodeCursor(query.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", err, core.ErrInvalidInput)
+		}
+		var after interface{} = value
+		if column == "date_time" {
+			after, err = time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return nil, fmt.Errorf("malformed cursor: %w", core.ErrInvalidInput)
+			}
+		}
+		db = db.Where(fmt.Sprintf("(events.%s, events.id) %s (?, ?)", column, comparison), after, id)
+	}
 
+	limit := query.PageSize()
+	events := []models.Event{}
+	result := db.
+		Preload("Destinations").
+		Preload("Activities").
+		Preload("Photos", approvedPhotos).
+		Preload("Photos.Photographer").
+		Preload("CoverPhoto", "is_cover AND status = ?", models.PhotoApproved).
+		Order(fmt.Sprintf("events.%s %s, events.id %s", column, direction, direction)).
+		Limit(limit + 1).
+		Find(&events)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to get all events: %w", result.Error)
 	}
-	return events, nil
+
+	page := &models.EventPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		last := page.Events[limit-1]
+		value := last.Name
+		if column == "date_time" {
+			value = last.DateTime.Format(time.RFC3339Nano)
+		}
+		page.NextCursor = utils.EncodeCursor(value, last.ID)
+	}
+	return page, nil
 }
 
 func (repo *EventRepository) GetEventById(id int64) (*models.Event, error) {
 	var event models.Event
 
-	// Fetch event with associations
+	// Fetch event with associations, the schedule lists the destinations again ordered by visit time
 	err := repo.db.
-		Preload("Destinations").     // Load associated Destinations via event_destinations
-		Preload("Activities").       // Load associated Activities via event_activities
-		Preload("Photos").           // Load associated Photos
+		Preload("Destinations"). // Load associated Destinations via event_destinations
+		Preload("Schedule", func(db *gorm.DB) *gorm.DB {
+			return db.Order("date_time, destination_id")
+		}).
+		Preload("Schedule.Destination").
+		Preload("Activities").             // Load associated Activities via event_activities
+		Preload("Photos", approvedPhotos). // Load the approved Photos, pending ones are only shown to reviewers
+		Preload("Photos.Photographer").
+		Preload("CoverPhoto", "is_cover AND status = ?", models.PhotoApproved).
+		Preload("CoHosts").          // Load co-hosts for ownership checks
 		First(&event, "id = ?", id). // Fetch event by ID
 		Error
 
@@ -173,6 +304,152 @@ func (repo *EventRepository) UpdatePartially(eventID int64, patch requests.Patch
 		return fmt.Errorf("no fields provided for update")
 	}
 
+	updates := eventDetailUpdates(patch)
+	if patch.DateTime != nil {
+		updates["date_time"] = *patch.DateTime
+	}
+	if patch.EndDateTime != nil {
+		updates["end_date_time"] = *patch.EndDateTime
+	}
+
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		// Locked like registrations lock it, so seats freed by the edit are handed out only once
+		if _, err := lockEvent(tx, eventID); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Event{}).Where("id = ?", eventID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update event %d: %w", eventID, err)
+		}
+		if err := checkItineraryWindow(tx, eventID, patch); err != nil {
+			return err
+		}
+		return promoteAfterSeatChange(tx, eventID, patch)
+	})
+}
+
+// checkItineraryWindow refuses a patch that moves the event's start or end past its itinerary stops,
+// the stops have to be moved first.
+func checkItineraryWindow(tx *gorm.DB, eventID int64, patch requests.PatchEvent) error {
+	if patch.DateTime == nil && patch.EndDateTime == nil {
+		return nil
+	}
+	var event models.Event
+	if err := tx.First(&event, eventID).Error; err != nil {
+		return fmt.Errorf("failed to reload event %d: %w", eventID, err)
+	}
+
+	outside := tx.Where("arrival_at < ?", event.DateTime)
+	if event.EndDateTime != nil {
+		outside = outside.Or("departure_at > ?", *event.EndDateTime)
+	}
+	var count int64
+	err := tx.Model(&models.ItineraryStop{}).Where("event_id = ?", eventID).Where(outside).Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check the itinerary of event %d: %w", eventID, err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%d itinerary stops of event %d would fall outside its time window, move them first: %w", count, eventID, core.ErrConflict)
+	}
+	return nil
+}
+
+// promoteAfterSeatChange moves waitlisted users into the seats a raised capacity or a changed waitlist
+// setting freed, once the patch was saved.
+func promoteAfterSeatChange(tx *gorm.DB, eventID int64, patch requests.PatchEvent) error {
+	if patch.Capacity == nil && patch.WaitlistEnabled == nil {
+		return nil
+	}
+	event, err := lockEvent(tx, eventID)
+	if err != nil {
+		return err
+	}
+	return promoteWaitlist(tx, event)
+}
+
+// TransitionStatus moves the event to the given status, cancelling an event cancels its registrations too.
+// Transitions of a series master also apply to its occurrences that haven't started and share its status.
+func (repo *EventRepository) TransitionStatus(eventID int64, to models.EventStatus, decision models.RegistrationDecision) (*models.Event, error) {
+	var event *models.Event
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		event, err = lockEvent(tx, eventID)
+		if err != nil {
+			return err
+		}
+		if !event.Status.CanTransitionTo(to) {
+			return fmt.Errorf("event %d can't go from %s to %s: %w", eventID, event.Status, to, core.ErrConflict)
+		}
+		if to == models.EventCompleted {
+			if event.IsRecurring() {
+				return fmt.Errorf("complete the occurrences of event %d instead: %w", eventID, core.ErrInvalidInput)
+			}
+			if event.DateTime.After(time.Now()) {
+				return fmt.Errorf("event %d hasn't started yet: %w", eventID, core.ErrConflict)
+			}
+		}
+
+		ids := []int64{eventID}
+		if event.IsRecurring() {
+			occurrenceIDs := []int64{}
+			err := tx.Model(&models.Event{}).
+				Where("series_id = ? AND status = ? AND date_time >= ?", eventID, event.Status, time.Now()).
+				Pluck("id", &occurrenceIDs).Error
+			if err != nil {
+				return fmt.Errorf("failed to get occurrences of event %d: %w", eventID, err)
+			}
+			ids = append(ids, occurrenceIDs...)
+		}
+
+		if to == models.EventDraft {
+			// Attendees would lose sight of the event, it has to be cancelled instead
+			var held int64
+			err := tx.Model(&models.Registration{}).
+				Where("event_id IN ? AND status IN ?", ids, models.SeatHoldingStatuses).
+				Count(&held).Error
+			if err != nil {
+				return fmt.Errorf("failed to count registrations of event %d: %w", eventID, err)
+			}
+			if held > 0 {
+				return fmt.Errorf("event %d has registrations, cancel it instead: %w", eventID, core.ErrConflict)
+			}
+		}
+
+		updates := map[string]interface{}{"status": to}
+		if to == models.EventCancelled {
+			updates["cancellation_reason"] = decision.Reason
+		}
+		if err := tx.Model(&models.Event{}).Where("id IN ?", ids).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to move event %d to %s: %w", eventID, to, err)
+		}
+
+		if to == models.EventCancelled {
+			err := tx.Model(&models.Registration{}).
+				Where("event_id IN ? AND status NOT IN ?", ids, []models.RegistrationStatus{models.Cancelled, models.Rejected}).
+				Updates(map[string]interface{}{
+					"status":          models.Cancelled,
+					"decided_by_id":   decision.DecidedByID,
+					"decided_at":      time.Now(),
+					"decision_reason": decision.Reason,
+				}).Error
+			if err != nil {
+				return fmt.Errorf("failed to cancel registrations of event %d: %w", eventID, err)
+			}
+		}
+
+		event.Status = to
+		if to == models.EventCancelled {
+			event.CancellationReason = decision.Reason
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// eventDetailUpdates maps the patched fields that don't move the event in time to their columns.
+func eventDetailUpdates(patch requests.PatchEvent) map[string]interface{} {
 	updates := make(map[string]interface{})
 	if patch.Name != nil {
 		updates["name"] = *patch.Name
@@ -183,17 +460,74 @@ func (repo *EventRepository) UpdatePartially(eventID int64, patch requests.Patch
 	if patch.Location != nil {
 		updates["location"] = *patch.Location
 	}
-	if patch.DateTime != nil {
-		updates["date_time"] = *patch.DateTime
+	if patch.Capacity != nil {
+		updates["capacity"] = *patch.Capacity
+	}
+	if patch.WaitlistEnabled != nil {
+		updates["waitlist_enabled"] = *patch.WaitlistEnabled
 	}
+	if patch.Timezone != nil {
+		updates["timezone"] = *patch.Timezone
+	}
+	return updates
+}
 
-	result := repo.db.Model(&models.Event{}).Where("id = ?", eventID).Updates(updates)
+func (repo *EventRepository) AddCoHost(eventID, userID int64) error {
+	var userCount int64
+	if err := repo.db.Model(&models.User{}).Where("id = ?", userID).Count(&userCount).Error; err != nil {
+		return fmt.Errorf("failed to check user %d: %w", userID, err)
+	}
+	if userCount == 0 {
+		return fmt.Errorf("user %d: %w", userID, core.ErrNotFound)
+	}
+
+	coHost := models.EventCoHost{EventID: eventID, UserID: userID}
+	err := repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&coHost).Error
+	if err != nil {
+		return fmt.Errorf("failed to add co-host %d to event %d: %w", userID, eventID, err)
+	}
+	return nil
+}
+
+func (repo *EventRepository) RemoveCoHost(eventID, userID int64) error {
+	result := repo.db.Where("event_id = ? AND user_id = ?", eventID, userID).Delete(&models.EventCoHost{})
 	if result.Error != nil {
-		return fmt.Errorf("failed to update event %d: %w", eventID, result.Error)
+		return fmt.Errorf("failed to remove co-host %d from event %d: %w", userID, eventID, result.Error)
 	}
 	if result.RowsAffected == 0 {
-		return fmt.Errorf("no event found with id %d", eventID)
+		return fmt.Errorf("user %d is not a co-host of event %d: %w", userID, eventID, core.ErrNotFound)
+	}
+	return nil
+}
+
+// ensureExist fails with core.ErrNotFound listing the ids that have no row in the model's table.
+func ensureExist(tx *gorm.DB, model interface{}, ids []int64, name string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var found []int64
+	if err := tx.Model(model).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		return fmt.Errorf("failed to check %s: %w", name, err)
 	}
 
+	existing := make(map[int64]bool, len(found))
+	for _, id := range found {
+		existing[id] = true
+	}
+	var missing []int64
+	for _, id := range ids {
+		if !existing[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("unknown %s %v: %w", name, missing, core.ErrNotFound)
+	}
 	return nil
 }
+
+// approvedPhotos limits preloaded photos to the ones that passed review.
+func approvedPhotos(db *gorm.DB) *gorm.DB {
+	return db.Where("status = ?", models.PhotoApproved).Order("position, uploaded_at, id")
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"gorm.io/gorm"
+)
+
+type ItineraryRepository struct {
+	db *gorm.DB
+}
+
+func NewItineraryRepository(db *gorm.DB) *ItineraryRepository {
+	return &ItineraryRepository{db: db}
+}
+
+func (repo *ItineraryRepository) GetStops(eventID int64) ([]models.ItineraryStop, error) {
+	stops := []models.ItineraryStop{}
+	err := repo.db.
+		Where("event_id = ?", eventID).
+		Preload("Destination").
+		Preload("Activities").
+		Order("position").
+		Find(&stops).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get itinerary of event %d: %w", eventID, err)
+	}
+	return stops, nil
+}
+
+// ReplaceStops swaps the event's itinerary for the given stops in one transaction.
+func (repo *ItineraryRepository) ReplaceStops(eventID int64, stops []models.ItineraryStop) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		var destinationIDs, activityIDs []int64
+		for _, stop := range stops {
+			destinationIDs = append(destinationIDs, stop.DestinationID)
+			for _, activity := range stop.Activities {
+				activityIDs = append(activityIDs, activity.ID)
+			}
+		}
+		if err := ensureExist(tx, &models.Destination{}, destinationIDs, "destinations"); err != nil {
+			return err
+		}
+		if err := ensureExist(tx, &models.Activity{}, activityIDs, "activities"); err != nil {
+			return err
+		}
+
+		err := tx.Exec(`DELETE FROM itinerary_stop_activities
+			WHERE itinerary_stop_id IN (SELECT id FROM itinerary_stops WHERE event_id = ?)`, eventID).Error
+		if err != nil {
+			return fmt.Errorf("failed to clear itinerary activities of event %d: %w", eventID, err)
+		}
+		if err := tx.Where("event_id = ?", eventID).Delete(&models.ItineraryStop{}).Error; err != nil {
+			return fmt.Errorf("failed to clear itinerary of event %d: %w", eventID, err)
+		}
+
+		if len(stops) == 0 {
+			return nil
+		}
+		// Activities already exist, only the join rows are created
+		if err := tx.Omit("Destination", "Activities.*").Create(&stops).Error; err != nil {
+			return fmt.Errorf("failed to save itinerary of event %d: %w", eventID, err)
+		}
+		return nil
+	})
+}
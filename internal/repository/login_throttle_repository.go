@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginThrottleRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginThrottleRepository(db *gorm.DB) *LoginThrottleRepository {
+	return &LoginThrottleRepository{db: db}
+}
+
+// Reserve counts an attempt for the key as a failure before it is checked, unless allow refuses the key's
+// throttle as it was before. The key stays locked in between, so concurrent attempts are counted one after
+// the other and each sees the ones before it. Failures older than the window are forgotten.
+// It returns the key's throttle after counting, Release takes the attempt back if it didn't fail.
+func (repo *LoginThrottleRepository) Reserve(key string, window time.Duration, allow func(models.LoginThrottle) error) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`INSERT INTO login_throttles (key, failures, last_failure_at) VALUES (?, 0, ?)
+			ON CONFLICT (key) DO NOTHING`, key, time.Time{}).Error
+		if err != nil {
+			return fmt.Errorf("failed to create login throttle of %s: %w", key, err)
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&throttle, "key = ?", key).Error; err != nil {
+			return fmt.Errorf("failed to get login throttle of %s: %w", key, err)
+		}
+		if err := allow(throttle); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if throttle.LastFailureAt.Before(now.Add(-window)) {
+			throttle.Failures = 0
+		}
+		throttle.Failures++
+		throttle.LastFailureAt = now
+		err = tx.Model(&models.LoginThrottle{}).
+			Where("key = ?", key).
+			Updates(map[string]interface{}{"failures": throttle.Failures, "last_failure_at": now}).Error
+		if err != nil {
+			return fmt.Errorf("failed to record login attempt for %s: %w", key, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+// Release takes back an attempt Reserve counted that turned out not to be a failed login.
+func (repo *LoginThrottleRepository) Release(key string) error {
+	err := repo.db.Model(&models.LoginThrottle{}).
+		Where("key = ? AND failures > 0", key).
+		Update("failures", gorm.Expr("failures - 1")).Error
+	if err != nil {
+		return fmt.Errorf("failed to release login attempt for %s: %w", key, err)
+	}
+	return nil
+}
+
+// Lock locks the key until the given time if it has at least lockAfter failures, starting its count over.
+// It returns false when the key didn't qualify, e.g. a concurrent failure already locked it.
+func (repo *LoginThrottleRepository) Lock(key string, lockAfter int, until time.Time) (bool, error) {
+	result := repo.db.Model(&models.LoginThrottle{}).
+		Where("key = ? AND failures >= ?", key, lockAfter).
+		Updates(map[string]interface{}{
+			"failures":     0,
+			"locked_until": until,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to lock %s: %w", key, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Clear forgets the key's failures and lockout, returning false if it had none.
+func (repo *LoginThrottleRepository) Clear(key string) (bool, error) {
+	result := repo.db.Where("key = ?", key).Delete(&models.LoginThrottle{})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to clear login throttle of %s: %w", key, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetLocked lists the keys that are locked out right now.
+func (repo *LoginThrottleRepository) GetLocked() ([]models.LoginThrottle, error) {
+	throttles := []models.LoginThrottle{}
+	err := repo.db.Where("locked_until > ?", time.Now()).Order("locked_until DESC").Find(&throttles).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get locked out logins: %w", err)
+	}
+	return throttles, nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PhotoAlbumRepository struct {
+	db *gorm.DB
+}
+
+func NewPhotoAlbumRepository(db *gorm.DB) *PhotoAlbumRepository {
+	return &PhotoAlbumRepository{db: db}
+}
+
+// GetAlbums lists the event's albums with their approved photos.
+func (repo *PhotoAlbumRepository) GetAlbums(eventID int64) ([]models.PhotoAlbum, error) {
+	albums := []models.PhotoAlbum{}
+	err := repo.db.
+		Where("event_id = ?", eventID).
+		Preload("Destination").
+		Preload("Photos", approvedPhotos).
+		Preload("Photos.Photographer").
+		Order("position, id").
+		Find(&albums).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get albums of event %d: %w", eventID, err)
+	}
+	return albums, nil
+}
+
+func (repo *PhotoAlbumRepository) CreateAlbum(album *models.PhotoAlbum) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkAlbumDestination(tx, album); err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Create(album).Error; err != nil {
+			return fmt.Errorf("failed to create album for event %d: %w", album.EventID, err)
+		}
+		return nil
+	})
+}
+
+func (repo *PhotoAlbumRepository) UpdateAlbum(album *models.PhotoAlbum) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := tx.Model(&models.PhotoAlbum{}).Where("id = ? AND event_id = ?", album.ID, album.EventID).Count(&count).Error
+		if err != nil {
+			return fmt.Errorf("failed to check album %d: %w", album.ID, err)
+		}
+		if count == 0 {
+			return fmt.Errorf("no album %d in event %d: %w", album.ID, album.EventID, core.ErrNotFound)
+		}
+		if err := checkAlbumDestination(tx, album); err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.PhotoAlbum{}).Where("id = ?", album.ID).Updates(map[string]interface{}{
+			"name":           album.Name,
+			"destination_id": album.DestinationID,
+			"position":       album.Position,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update album %d: %w", album.ID, err)
+		}
+		return nil
+	})
+}
+
+// DeleteAlbum deletes the album, its photos stay in the event without an album.
+func (repo *PhotoAlbumRepository) DeleteAlbum(eventID, albumID int64) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.EventPhoto{}).Where("album_id = ?", albumID).Update("album_id", nil).Error
+		if err != nil {
+			return fmt.Errorf("failed to take photos out of album %d: %w", albumID, err)
+		}
+		result := tx.Where("id = ? AND event_id = ?", albumID, eventID).Delete(&models.PhotoAlbum{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete album %d: %w", albumID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("no album %d in event %d: %w", albumID, eventID, core.ErrNotFound)
+		}
+		return nil
+	})
+}
+
+// checkAlbumDestination makes sure the album's destination is one of the event's and has no other album yet.
+func checkAlbumDestination(tx *gorm.DB, album *models.PhotoAlbum) error {
+	if album.DestinationID == nil {
+		return nil
+	}
+
+	var count int64
+	err := tx.Model(&models.EventDestination{}).
+		Where("event_id = ? AND destination_id = ?", album.EventID, *album.DestinationID).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check destination %d: %w", *album.DestinationID, err)
+	}
+	if count == 0 {
+		return fmt.Errorf("destination %d is not part of event %d: %w", *album.DestinationID, album.EventID, core.ErrInvalidInput)
+	}
+
+	err = tx.Model(&models.PhotoAlbum{}).
+		Where("event_id = ? AND destination_id = ? AND id <> ?", album.EventID, *album.DestinationID, album.ID).
+		Count(&count).Error
+	if err != nil {
+		return fmt.Errorf("failed to check albums of destination %d: %w", *album.DestinationID, err)
+	}
+	if count > 0 {
+		return fmt.Errorf("destination %d already has an album: %w", *album.DestinationID, core.ErrConflict)
+	}
+	return nil
+}
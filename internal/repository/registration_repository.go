@@ -2,9 +2,12 @@ package repository
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type RegistrationRepository struct {
@@ -15,95 +18,287 @@ func NewRegistrationRepository(db *gorm.DB) *RegistrationRepository {
 	return &RegistrationRepository{db: db}
 }
 
-func (repo *RegistrationRepository) Register(userId, eventId int64) error {
-	result := repo.db.Create(&models.Registration{UserID: userId, EventID: eventId})
-	if result.Error != nil {
-		return fmt.Errorf("failed to register user %d for event %d: %w", userId, eventId, result.Error)
-	}
-	return nil
+// Register requests a seat for the user. Once the event's seats are all held the user is
+// waitlisted if the event allows it. The event row is locked so concurrent registrations can't overbook.
+func (repo *RegistrationRepository) Register(userId, eventId int64) (models.RegistrationStatus, error) {
+	var status models.RegistrationStatus
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		event, err := lockEvent(tx, eventId)
+		if err != nil {
+			return err
+		}
+		if event.IsRecurring() {
+			return fmt.Errorf("event %d is recurring, register for one of its occurrences: %w", eventId, core.ErrInvalidInput)
+		}
+		if event.Status != models.EventPublished {
+			return fmt.Errorf("event %d is %s and not open for registration: %w", eventId, event.Status, core.ErrConflict)
+		}
+
+		var existing models.Registration
+		err = tx.Where("user_id = ? AND event_id = ?", userId, eventId).First(&existing).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return fmt.Errorf("failed to find registration for user %d and event %d: %w", userId, eventId, err)
+		}
+		found := err == nil
+		if found && existing.Status != models.Cancelled && existing.Status != models.Rejected {
+			return fmt.Errorf("user %d is already %s for event %d: %w", userId, existing.Status, eventId, core.ErrConflict)
+		}
+
+		status = models.PendingRegistration
+		if event.Capacity > 0 {
+			held, err := heldSeats(tx, eventId)
+			if err != nil {
+				return err
+			}
+			if held >= int64(event.Capacity) {
+				if !event.WaitlistEnabled {
+					return fmt.Errorf("event %d is full: %w", eventId, core.ErrConflict)
+				}
+				status = models.Waitlisted
+			}
+		}
+
+		if !found {
+			err = tx.Create(&models.Registration{UserID: userId, EventID: eventId, Status: status}).Error
+		} else {
+			// Registering again after a cancellation or rejection starts over at the end of the line
+			now := time.Now()
+			err = tx.Model(&models.Registration{}).
+				Where("user_id = ? AND event_id = ?", userId, eventId).
+				Updates(map[string]interface{}{
+					"status":          status,
+					"created_at":      now,
+					"updated_at":      now,
+					"decided_by_id":   nil,
+					"decided_at":      nil,
+					"decision_reason": "",
+				}).Error
+		}
+		if err != nil {
+			return fmt.Errorf("failed to register user %d for event %d: %w", userId, eventId, err)
+		}
+		return nil
+	})
+	return status, err
 }
 
-func (repo *RegistrationRepository) ApproveRegistration(userId, eventId int64) error {
+// CancelRegister cancels a pending or waitlisted registration right away, confirmed registrations
+// need an organizer to approve the cancellation.
+func (repo *RegistrationRepository) CancelRegister(userId int64, eventId int64) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		event, err := lockEvent(tx, eventId)
+		if err != nil {
+			return err
+		}
 
-	eventRegistration := models.Registration{UserID: userId, EventID: eventId}
-	result := repo.db.Find(&eventRegistration)
+		var eventRegistration models.Registration
+		result := tx.Where("user_id = ? AND event_id = ?", userId, eventId).First(&eventRegistration)
+		if result.Error != nil {
+			if result.Error == gorm.ErrRecordNotFound {
+				return fmt.Errorf("user %d is not registered for event %d: %w", userId, eventId, core.ErrNotFound)
+			}
+			return fmt.Errorf("failed to find registration for user %d and event %d: %w", userId, eventId, result.Error)
+		}
 
-	if result.Error != nil {
-		return fmt.Errorf("failed to find registration for user %d and event %d: %w", userId, eventId, result.Error)
-	}
+		switch eventRegistration.Status {
+		case models.PendingRegistration, models.Waitlisted:
+			eventRegistration.Status = models.Cancelled
+		case models.Registered:
+			eventRegistration.Status = models.PendingCancellation
+		default:
+			return fmt.Errorf("registration for user %d and event %d is %s: %w", userId, eventId, eventRegistration.Status, core.ErrConflict)
+		}
 
-	if eventRegistration.Status != models.PendingRegistration {
-		return fmt.Errorf("registration for user %d and event %d is already approved", userId, eventId)
-	}
+		result = tx.Save(&eventRegistration)
+		if result.Error != nil {
+			return fmt.Errorf("failed to cancel registration of user %d for event %d: %w", userId, eventId, result.Error)
+		}
+		return promoteWaitlist(tx, event)
+	})
+}
 
-	eventRegistration.Status = models.Registered
+// UpdateStatuses moves the registrations of the given users from one status to another and
+// records the decision. Nothing is changed if any of the registrations is missing or not in the from status.
+func (repo *RegistrationRepository) UpdateStatuses(eventID int64, userIDs []int64, from, to models.RegistrationStatus, decision models.RegistrationDecision) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		event, err := lockEvent(tx, eventID)
+		if err != nil {
+			return err
+		}
 
-	result = repo.db.Save(&eventRegistration)
-	if result.Error != nil {
-		return fmt.Errorf("failed to register user %d for event %d: %w", userId, eventId, result.Error)
-	}
-	return nil
+		var registrations []models.Registration
+		err = tx.Where("event_id = ? AND user_id IN ?", eventID, userIDs).
+			Find(&registrations).Error
+		if err != nil {
+			return fmt.Errorf("failed to get registrations for event %d: %w", eventID, err)
+		}
+
+		if err := checkStatuses(registrations, userIDs, from); err != nil {
+			return fmt.Errorf("event %d: %w", eventID, err)
+		}
+
+		// Pending registrations already hold their seats, but the capacity may have been lowered since
+		if from == models.PendingRegistration && to == models.Registered && event.Capacity > 0 {
+			var confirmed int64
+			err := tx.Model(&models.Registration{}).
+				Where("event_id = ? AND status IN ?", eventID, []models.RegistrationStatus{models.Registered, models.PendingCancellation}).
+				Count(&confirmed).Error
+			if err != nil {
+				return fmt.Errorf("failed to count confirmed seats of event %d: %w", eventID, err)
+			}
+			if confirmed+int64(len(userIDs)) > int64(event.Capacity) {
+				return fmt.Errorf("approving %d registrations would exceed the capacity of event %d: %w", len(userIDs), eventID, core.ErrConflict)
+			}
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.Registration{}).
+			Where("event_id = ? AND user_id IN ?", eventID, userIDs).
+			Updates(map[string]interface{}{
+				"status":          to,
+				"decided_by_id":   decision.DecidedByID,
+				"decided_at":      now,
+				"decision_reason": decision.Reason,
+				"updated_at":      now,
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update registrations for event %d to %s: %w", eventID, to, result.Error)
+		}
+
+		// Cancelled and rejected registrations free their seats for the waitlist
+		return promoteWaitlist(tx, event)
+	})
 }
 
-func (repo *RegistrationRepository) CancelRegister(userId int64, eventId int64) error {
-	eventRegistration := models.Registration{UserID: userId, EventID: eventId}
-	result := repo.db.Find(&eventRegistration)
+func (repo *RegistrationRepository) GetRegistrations(eventID int64, status models.RegistrationStatus) ([]models.Registration, error) {
+	registrations := []models.Registration{}
+
+	query := repo.db.Where("event_id = ?", eventID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
 
-	if result.Error != nil {
-		return fmt.Errorf("failed to find registration for user %d and event %d: %w", userId, eventId, result.Error)
+	// Preload only the public user fields
+	err := query.
+		Preload("User", func(tx *gorm.DB) *gorm.DB {
+			return tx.Select("id", "phone", "first_name", "last_name", "photo")
+		}).
+		Order("created_at").
+		Find(&registrations).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registrations for event %d with status %s: %w", eventID, status, err)
 	}
 
-	if eventRegistration.Status == models.Cancelled {
-		return fmt.Errorf("registration for user %d and event %d is already cancelled", userId, eventId)
+	return registrations, nil
+}
+
+// GetRegistration returns the user's registration for the event, nil when there is none.
+func (repo *RegistrationRepository) GetRegistration(eventID, userID int64) (*models.Registration, error) {
+	var registration models.Registration
+	err := repo.db.Where("event_id = ? AND user_id = ?", eventID, userID).First(&registration).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get registration of user %d for event %d: %w", userID, eventID, err)
 	}
+	return &registration, nil
+}
 
-	eventRegistration.Status = models.PendingCancellation
+// checkStatuses makes sure every user has a registration in the expected status.
+func checkStatuses(registrations []models.Registration, userIDs []int64, expected models.RegistrationStatus) error {
+	byUser := make(map[int64]models.Registration, len(registrations))
+	for _, registration := range registrations {
+		byUser[registration.UserID] = registration
+	}
 
-	result = repo.db.Save(&eventRegistration)
-	if result.Error != nil {
-		return fmt.Errorf("failed to register user %d for event %d: %w", userId, eventId, result.Error)
+	for _, userID := range userIDs {
+		registration, ok := byUser[userID]
+		if !ok {
+			return fmt.Errorf("no registration for user %d: %w", userID, core.ErrNotFound)
+		}
+		if registration.Status != expected {
+			return fmt.Errorf("registration for user %d is %s, expected %s: %w", userID, registration.Status, expected, core.ErrConflict)
+		}
 	}
 	return nil
 }
 
-func (repo *RegistrationRepository) ApproveCancelRegister(userId int64, eventId int64) error {
-	eventRegistration := models.Registration{UserID: userId, EventID: eventId}
-	result := repo.db.Find(&eventRegistration)
+// lockEvent loads the event and locks its row until the transaction ends.
+// Every change to the held seats of an event goes through this lock.
+func lockEvent(tx *gorm.DB, eventID int64) (*models.Event, error) {
+	var event models.Event
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, eventID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("event %d: %w", eventID, core.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to lock event %d: %w", eventID, err)
+	}
+	return &event, nil
+}
 
-	if result.Error != nil {
-		return fmt.Errorf("failed to find registration for user %d and event %d: %w", userId, eventId, result.Error)
+func heldSeats(tx *gorm.DB, eventID int64) (int64, error) {
+	var held int64
+	err := tx.Model(&models.Registration{}).
+		Where("event_id = ? AND status IN ?", eventID, models.SeatHoldingStatuses).
+		Count(&held).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count seats of event %d: %w", eventID, err)
 	}
+	return held, nil
+}
+
+// promoteWaitlist moves waitlisted users, first come first served, into the free seats.
+// Promoted users get a pending registration so organizers still approve every attendee.
+func promoteWaitlist(tx *gorm.DB, event *models.Event) error {
+	query := tx.Model(&models.Registration{}).
+		Where("event_id = ? AND status = ?", event.ID, models.Waitlisted).
+		Order("created_at")
 
-	if eventRegistration.Status != models.PendingCancellation {
-		return fmt.Errorf("user %d didn't request cancellation for event %d", userId, eventId)
+	if event.Capacity > 0 {
+		held, err := heldSeats(tx, event.ID)
+		if err != nil {
+			return err
+		}
+		free := int64(event.Capacity) - held
+		if free <= 0 {
+			return nil
+		}
+		query = query.Limit(int(free))
 	}
 
-	eventRegistration.Status = models.Cancelled
+	var userIDs []int64
+	if err := query.Pluck("user_id", &userIDs).Error; err != nil {
+		return fmt.Errorf("failed to get waitlist of event %d: %w", event.ID, err)
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
 
-	result = repo.db.Save(&eventRegistration)
-	if result.Error != nil {
-		return fmt.Errorf("failed to register user %d for event %d: %w", userId, eventId, result.Error)
+	err := tx.Model(&models.Registration{}).
+		Where("event_id = ? AND user_id IN ?", event.ID, userIDs).
+		Updates(map[string]interface{}{
+			"status":     models.PendingRegistration,
+			"updated_at": time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to promote waitlist of event %d: %w", event.ID, err)
 	}
 	return nil
 }
 
-func (repo *RegistrationRepository) GetUsersWithStatus(eventID int64, status models.RegistrationStatus) ([]models.User, error) {
-	var registrations []models.Registration
-
-	// Query registrations with the specified eventID and status, preloading User
+// GetUserRegistrations lists the user's registrations with the given statuses along with their events,
+// ordered by event start.
+func (repo *RegistrationRepository) GetUserRegistrations(userID int64, statuses []models.RegistrationStatus) ([]models.Registration, error) {
+	registrations := []models.Registration{}
 	err := repo.db.
-		Where("event_id = ? AND status = ?", eventID, status).
-		Preload("User").
+		Joins("Event").
+		Where("registrations.user_id = ? AND registrations.status IN ?", userID, statuses).
+		Order(`"Event".date_time`).
 		Find(&registrations).Error
 	if err != nil {
-		return nil, fmt.Errorf("failed to get registrations for event %d with status %s: %w", eventID, status, err)
+		return nil, fmt.Errorf("failed to get registrations for user %d: %w", userID, err)
 	}
-
-	// Extract users from registrations
-	users := make([]models.User, 0, len(registrations))
-	for _, reg := range registrations {
-		users = append(users, reg.User)
-	}
-
-	return users, nil
+	return registrations, nil
 }
@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReportRepository struct {
+	db *gorm.DB
+}
+
+func NewReportRepository(db *gorm.DB) *ReportRepository {
+	return &ReportRepository{db: db}
+}
+
+// Create saves the report, hiding its target once it has threshold open reports.
+// It returns whether this report got the target hidden, users are never hidden automatically.
+func (repo *ReportRepository) Create(report *models.Report, threshold int) (bool, error) {
+	hidden := false
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		// The partial unique index only allows one open report per reporter and target
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(report)
+		if result.Error != nil {
+			return fmt.Errorf("failed to save report: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%s %d is already reported: %w", report.TargetType, report.TargetID, core.ErrConflict)
+		}
+		if report.TargetType == models.ReportUser {
+			return nil
+		}
+
+		open, err := countOpenReports(tx, report.TargetType, report.TargetID)
+		if err != nil {
+			return err
+		}
+		if open < int64(threshold) {
+			return nil
+		}
+
+		// Concurrent reports may all reach the threshold, only the one that changes the target logs it
+		hidden, err = setTargetHidden(tx, report.TargetType, report.TargetID, true)
+		if err != nil || !hidden {
+			return err
+		}
+		return logAction(tx, &models.ModerationAction{
+			Action:     models.ActionAutoHide,
+			TargetType: report.TargetType,
+			TargetID:   report.TargetID,
+			Reports:    int(open),
+		})
+	})
+	return hidden, err
+}
+
+func (repo *ReportRepository) CountOpenReports(targetType models.ReportTarget, targetID int64) (int64, error) {
+	return countOpenReports(repo.db, targetType, targetID)
+}
+
+// GetQueue lists the targets with open reports, most reported first.
+func (repo *ReportRepository) GetQueue(targetType models.ReportTarget, limit int) ([]models.ReportedItem, error) {
+	items := []models.ReportedItem{}
+	query := repo.db.Model(&models.Report{}).
+		Select(`target_type, target_id, count(*) AS reports, array_agg(DISTINCT reason) AS reasons,
+			min(created_at) AS first_reported_at, max(created_at) AS last_reported_at`).
+		Where("status = ?", models.ReportOpen)
+	if targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+
+	err := query.
+		Group("target_type, target_id").
+		Order("reports DESC, first_reported_at, target_id").
+		Limit(limit).
+		Scan(&items).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reported items: %w", err)
+	}
+	return items, nil
+}
+
+// GetDetails returns the target's open reports and every action taken on it.
+func (repo *ReportRepository) GetDetails(targetType models.ReportTarget, targetID int64) (*models.ReportDetails, error) {
+	details := &models.ReportDetails{TargetType: targetType, TargetID: targetID, Reports: []models.Report{}, Actions: []models.ModerationAction{}}
+	err := repo.db.
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, models.ReportOpen).
+		Order("created_at, id").
+		Find(&details.Reports).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reports of %s %d: %w", targetType, targetID, err)
+	}
+
+	err = repo.db.
+		Where("target_type = ? AND target_id = ?", targetType, targetID).
+		Order("created_at, id").
+		Find(&details.Actions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get actions on %s %d: %w", targetType, targetID, err)
+	}
+	return details, nil
+}
+
+// GetActions returns the latest entries of the audit log.
+func (repo *ReportRepository) GetActions(limit int) ([]models.ModerationAction, error) {
+	actions := []models.ModerationAction{}
+	err := repo.db.Order("created_at DESC, id DESC").Limit(limit).Find(&actions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get moderation actions: %w", err)
+	}
+	return actions, nil
+}
+
+// Dismiss closes the target's open reports without acting on them.
+// A target that was hidden automatically is shown again, unless someone acted on it since,
+// admins hiding or approving a comment from the moderation queue count as acting on it.
+func (repo *ReportRepository) Dismiss(action *models.ModerationAction) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		var last models.ModerationAction
+		err := tx.Where("target_type = ? AND target_id = ?", action.TargetType, action.TargetID).
+			Order("created_at DESC, id DESC").
+			Limit(1).
+			Find(&last).Error
+		if err != nil {
+			return fmt.Errorf("failed to get actions on %s %d: %w", action.TargetType, action.TargetID, err)
+		}
+
+		if err := resolveReports(tx, models.ReportDismissed, action); err != nil {
+			return err
+		}
+		if last.Action == models.ActionAutoHide {
+			if _, err := setTargetHidden(tx, action.TargetType, action.TargetID, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Resolve closes the target's open reports once the action was taken.
+func (repo *ReportRepository) Resolve(action *models.ModerationAction) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		return resolveReports(tx, models.ReportActioned, action)
+	})
+}
+
+func resolveReports(tx *gorm.DB, status models.ReportStatus, action *models.ModerationAction) error {
+	result := tx.Model(&models.Report{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", action.TargetType, action.TargetID, models.ReportOpen).
+		Updates(map[string]interface{}{
+			"status":         status,
+			"resolved_by_id": action.ActorID,
+			"resolved_at":    time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to resolve reports of %s %d: %w", action.TargetType, action.TargetID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no open reports of %s %d: %w", action.TargetType, action.TargetID, core.ErrNotFound)
+	}
+	action.Reports = int(result.RowsAffected)
+	return logAction(tx, action)
+}
+
+func logAction(tx *gorm.DB, action *models.ModerationAction) error {
+	if err := tx.Create(action).Error; err != nil {
+		return fmt.Errorf("failed to record %s of %s %d: %w", action.Action, action.TargetType, action.TargetID, err)
+	}
+	return nil
+}
+
+func countOpenReports(db *gorm.DB, targetType models.ReportTarget, targetID int64) (int64, error) {
+	var open int64
+	err := db.Model(&models.Report{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, models.ReportOpen).
+		Count(&open).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count reports of %s %d: %w", targetType, targetID, err)
+	}
+	return open, nil
+}
+
+// setTargetHidden hides a visible comment or an approved photo, and undoes it. It returns false when the target wasn't in the expected state.
+func setTargetHidden(tx *gorm.DB, targetType models.ReportTarget, targetID int64, hide bool) (bool, error) {
+	var result *gorm.DB
+	switch targetType {
+	case models.ReportComment:
+		from, to := models.CommentVisible, models.CommentHidden
+		if !hide {
+			from, to = to, from
+		}
+		result = tx.Model(&models.Comment{}).
+			Where("id = ? AND status = ? AND deleted_at IS NULL", targetID, from).
+			Updates(map[string]interface{}{"status": to, "visible": to == models.CommentVisible})
+	case models.ReportPhoto:
+		from, to := models.PhotoApproved, models.PhotoHidden
+		if !hide {
+			from, to = to, from
+		}
+		result = tx.Model(&models.EventPhoto{}).
+			Where("id = ? AND status = ?", targetID, from).
+			Update("status", to)
+	default:
+		return false, nil
+	}
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update %s %d: %w", targetType, targetID, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -2,8 +2,10 @@ package repository
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -104,7 +106,7 @@ func (repo *RoleRepository) GetRolesByUserId(userID int64) ([]models.Role, error
 	var user models.User
 
 	err := repo.db.
-		Preload("Roles").
+		Preload("Roles.Permissions").
 		First(&user, userID).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -116,6 +118,22 @@ func (repo *RoleRepository) GetRolesByUserId(userID int64) ([]models.Role, error
 	return user.Roles, nil
 }
 
+func (repo *RoleRepository) GetUsersByRoleName(name string) ([]models.User, error) {
+	var users []models.User
+
+	err := repo.db.
+		Joins("JOIN user_roles ur ON users.id = ur.user_id").
+		Joins("JOIN roles r ON r.id = ur.role_id").
+		Where("r.name = ?", name).
+		Preload("Roles").
+		Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users for role %s: %w", name, err)
+	}
+
+	return users, nil
+}
+
 func (repo *RoleRepository) GetUsersByRoleId(roleID int64) ([]models.User, error) {
 	var users []models.User
 
@@ -256,3 +274,99 @@ func (repo *RoleRepository) DeleteUserRoles(userID int64) error {
 	}
 	return nil
 }
+
+func (repo *RoleRepository) GetAllPermissions() ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	result := repo.db.Order("name").Find(&permissions)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get all permissions: %w", result.Error)
+	}
+	return permissions, nil
+}
+
+func (repo *RoleRepository) GetRolePermissions(roleID int64) ([]models.Permission, error) {
+	role := models.Role{ID: roleID}
+	err := repo.db.Preload("Permissions").First(&role).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("role %d: %w", roleID, core.ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get permissions for role %d: %w", roleID, err)
+	}
+	return role.Permissions, nil
+}
+
+// GrantPermissions grants the named permissions to a role, already granted ones are skipped.
+func (repo *RoleRepository) GrantPermissions(roleID int64, names []string) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		var roleCount int64
+		if err := tx.Model(&models.Role{}).Where("id = ?", roleID).Count(&roleCount).Error; err != nil {
+			return fmt.Errorf("failed to check role %d: %w", roleID, err)
+		}
+		if roleCount == 0 {
+			return fmt.Errorf("role %d: %w", roleID, core.ErrNotFound)
+		}
+
+		permissions, err := findPermissionsByName(tx, names)
+		if err != nil {
+			return err
+		}
+
+		rolePermissions := make([]models.RolePermission, 0, len(permissions))
+		for _, permission := range permissions {
+			rolePermissions = append(rolePermissions, models.RolePermission{
+				RoleID:       roleID,
+				PermissionID: permission.ID,
+			})
+		}
+
+		err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rolePermissions).Error
+		if err != nil {
+			return fmt.Errorf("failed to grant permissions to role %d: %w", roleID, err)
+		}
+		return nil
+	})
+}
+
+func (repo *RoleRepository) RevokePermissions(roleID int64, names []string) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		permissions, err := findPermissionsByName(tx, names)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(permissions))
+		for _, permission := range permissions {
+			ids = append(ids, permission.ID)
+		}
+
+		result := tx.Where("role_id = ? AND permission_id IN ?", roleID, ids).Delete(&models.RolePermission{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke permissions from role %d: %w", roleID, result.Error)
+		}
+		return nil
+	})
+}
+
+// findPermissionsByName fails if any of the names is not a known permission.
+func findPermissionsByName(tx *gorm.DB, names []string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if err := tx.Where("name IN ?", names).Find(&permissions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+
+	found := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		found[permission.Name] = true
+	}
+	var unknown []string
+	for _, name := range names {
+		if !found[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown permissions %s: %w", strings.Join(unknown, ", "), core.ErrNotFound)
+	}
+	return permissions, nil
+}
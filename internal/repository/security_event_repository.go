@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"gorm.io/gorm"
+)
+
+type SecurityEventRepository struct {
+	db *gorm.DB
+}
+
+func NewSecurityEventRepository(db *gorm.DB) *SecurityEventRepository {
+	return &SecurityEventRepository{db: db}
+}
+
+func (repo *SecurityEventRepository) Create(event *models.SecurityEvent) error {
+	if err := repo.db.Create(event).Error; err != nil {
+		return fmt.Errorf("failed to record %s of %s: %w", event.Type, event.Subject, err)
+	}
+	return nil
+}
+
+// GetEvents returns the latest events, only the user's when userID isn't 0.
+func (repo *SecurityEventRepository) GetEvents(userID int64, limit int) ([]models.SecurityEvent, error) {
+	events := []models.SecurityEvent{}
+	query := repo.db.Order("created_at DESC, id DESC").Limit(limit)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to get security events: %w", err)
+	}
+	return events, nil
+}
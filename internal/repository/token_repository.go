@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+// CreateSession saves a new session along with the first refresh token of its family.
+func (repo *TokenRepository) CreateSession(session *models.Session, token *models.RefreshToken) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return fmt.Errorf("failed to save session for user %d: %w", session.UserID, err)
+		}
+		if err := tx.Create(token).Error; err != nil {
+			return fmt.Errorf("failed to save refresh token for user %d: %w", token.UserID, err)
+		}
+		return nil
+	})
+}
+
+func (repo *TokenRepository) GetRefreshTokenByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	result := repo.db.Where("token_hash = ?", hash).First(&token)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", result.Error)
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken revokes the current token and stores its replacement atomically.
+// It fails if the current token was already revoked, so two concurrent refreshes
+// with the same token can't both succeed.
+func (repo *TokenRepository) RotateRefreshToken(currentID int64, next *models.RefreshToken) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", currentID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke refresh token %d: %w", currentID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("refresh token %d was already used", currentID)
+		}
+
+		if err := tx.Create(next).Error; err != nil {
+			return fmt.Errorf("failed to save rotated refresh token: %w", err)
+		}
+		return nil
+	})
+}
+
+// RevokeFamily revokes the refresh token family and its session.
+func (repo *TokenRepository) RevokeFamily(familyID string) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke refresh token family %s: %w", familyID, result.Error)
+		}
+		result = tx.Model(&models.Session{}).
+			Where("id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke session %s: %w", familyID, result.Error)
+		}
+		return nil
+	})
+}
+
+// RevokeUserFamilies revokes every refresh token family and session of the user but the kept one, "" keeps none.
+func (repo *TokenRepository) RevokeUserFamilies(userID int64, keptFamilyID string) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, keptFamilyID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke refresh tokens of user %d: %w", userID, result.Error)
+		}
+		result = tx.Model(&models.Session{}).
+			Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keptFamilyID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke sessions of user %d: %w", userID, result.Error)
+		}
+		return nil
+	})
+}
+
+// RevokeUserSession revokes one of the user's sessions, returning false if the user has no such active session.
+func (repo *TokenRepository) RevokeUserSession(userID int64, sessionID string) (bool, error) {
+	var revoked bool
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke session %s: %w", sessionID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		revoked = true
+		result = tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", sessionID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke refresh token family %s: %w", sessionID, result.Error)
+		}
+		return nil
+	})
+	return revoked, err
+}
+
+// GetSession returns the session, nil if there is none.
+func (repo *TokenRepository) GetSession(sessionID string) (*models.Session, error) {
+	var session models.Session
+	err := repo.db.Where("id = ?", sessionID).First(&session).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get session %s: %w", sessionID, err)
+	}
+	return &session, nil
+}
+
+// GetUserSessions lists the user's sessions that can still be used, the most recently seen first.
+func (repo *TokenRepository) GetUserSessions(userID int64) ([]models.Session, error) {
+	sessions := []models.Session{}
+	err := repo.db.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions of user %d: %w", userID, err)
+	}
+	return sessions, nil
+}
+
+// TouchSession records that the session was just used from the device, returning false if there is
+// no such session. The label is kept, it is only set at login.
+func (repo *TokenRepository) TouchSession(sessionID string, device models.SessionDevice, expiresAt *time.Time) (bool, error) {
+	updates := map[string]interface{}{
+		"last_seen_at": time.Now(),
+		"ip":           device.IP,
+		"user_agent":   device.UserAgent,
+	}
+	if expiresAt != nil {
+		updates["expires_at"] = *expiresAt
+	}
+	result := repo.db.Model(&models.Session{}).Where("id = ?", sessionID).Updates(updates)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update session %s: %w", sessionID, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// SaveSession stores a session for a family that predates sessions, on its first refresh.
+func (repo *TokenRepository) SaveSession(session *models.Session) error {
+	if err := repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(session).Error; err != nil {
+		return fmt.Errorf("failed to save session %s: %w", session.ID, err)
+	}
+	return nil
+}
+
+func (repo *TokenRepository) DenylistAccessToken(token *models.RevokedAccessToken) error {
+	err := repo.db.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke access token %s: %w", token.JTI, err)
+	}
+	return nil
+}
+
+func (repo *TokenRepository) IsAccessTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := repo.db.Model(&models.RevokedAccessToken{}).Where("jti = ?", jti).Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check access token %s: %w", jti, err)
+	}
+	return count > 0, nil
+}
+
+// SaveCalendarToken replaces the user's calendar feed token, invalidating the previous feed URL.
+func (repo *TokenRepository) SaveCalendarToken(token *models.CalendarToken) error {
+	err := repo.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token_hash", "created_at"}),
+	}).Create(token).Error
+	if err != nil {
+		return fmt.Errorf("failed to save calendar token for user %d: %w", token.UserID, err)
+	}
+	return nil
+}
+
+func (repo *TokenRepository) GetCalendarTokenByHash(hash string) (*models.CalendarToken, error) {
+	var token models.CalendarToken
+	result := repo.db.Where("token_hash = ?", hash).First(&token)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get calendar token: %w", result.Error)
+	}
+	return &token, nil
+}
+
+// PurgeExpired drops denylist entries, refresh tokens and sessions that can no longer be used anyway.
+func (repo *TokenRepository) PurgeExpired() error {
+	now := time.Now()
+	if err := repo.db.Where("expires_at < ?", now).Delete(&models.RevokedAccessToken{}).Error; err != nil {
+		return fmt.Errorf("failed to purge revoked access tokens: %w", err)
+	}
+	if err := repo.db.Where("expires_at < ?", now).Delete(&models.RefreshToken{}).Error; err != nil {
+		return fmt.Errorf("failed to purge refresh tokens: %w", err)
+	}
+	if err := repo.db.Where("expires_at < ?", now).Delete(&models.Session{}).Error; err != nil {
+		return fmt.Errorf("failed to purge sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TwoFactorRepository struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorRepository(db *gorm.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+// Get returns the user's authenticator, nil if they never started enrolling one.
+func (repo *TwoFactorRepository) Get(userID int64) (*models.TwoFactor, error) {
+	var twoFactor models.TwoFactor
+	err := repo.db.Where("user_id = ?", userID).First(&twoFactor).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get two-factor of user %d: %w", userID, err)
+	}
+	return &twoFactor, nil
+}
+
+// SavePending stores a new secret for the user to confirm, replacing an earlier pending one.
+// Enabled authenticators are kept, they have to be disabled first.
+func (repo *TwoFactorRepository) SavePending(twoFactor *models.TwoFactor) error {
+	result := repo.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"secret": twoFactor.Secret, "last_step": 0, "created_at": time.Now()}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "two_factors.enabled_at IS NULL"}}},
+	}).Create(twoFactor)
+	if result.Error != nil {
+		return fmt.Errorf("failed to save two-factor of user %d: %w", twoFactor.UserID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("two-factor of user %d is already enabled: %w", twoFactor.UserID, core.ErrConflict)
+	}
+	return nil
+}
+
+// Enable turns on the user's pending authenticator with the step of the code that confirmed it,
+// replacing any recovery codes with the given hashes.
+func (repo *TwoFactorRepository) Enable(userID, step int64, codeHashes []string) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.TwoFactor{}).
+			Where("user_id = ? AND enabled_at IS NULL", userID).
+			Updates(map[string]interface{}{"enabled_at": time.Now(), "last_step": step})
+		if result.Error != nil {
+			return fmt.Errorf("failed to enable two-factor of user %d: %w", userID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("two-factor of user %d is already enabled: %w", userID, core.ErrConflict)
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// UseStep records that a code of the step was used, returning false if it or a later one already was.
+func (repo *TwoFactorRepository) UseStep(userID, step int64) (bool, error) {
+	result := repo.db.Model(&models.TwoFactor{}).
+		Where("user_id = ? AND last_step < ?", userID, step).
+		Update("last_step", step)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record two-factor code of user %d: %w", userID, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UseRecoveryCode uses up the user's unused code with the hash, returning false if there is none.
+func (repo *TwoFactorRepository) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	result := repo.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to use recovery code of user %d: %w", userID, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (repo *TwoFactorRepository) CountRecoveryCodes(userID int64) (int64, error) {
+	var count int64
+	err := repo.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes of user %d: %w", userID, err)
+	}
+	return count, nil
+}
+
+func (repo *TwoFactorRepository) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID int64, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return fmt.Errorf("failed to delete recovery codes of user %d: %w", userID, err)
+	}
+	codes := make([]models.RecoveryCode, 0, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+	}
+	if err := tx.Create(&codes).Error; err != nil {
+		return fmt.Errorf("failed to save recovery codes of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+// Delete removes the user's authenticator, recovery codes and pending login challenges,
+// returning false if there was no authenticator.
+func (repo *TwoFactorRepository) Delete(userID int64) (bool, error) {
+	var deleted bool
+	err := repo.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ?", userID).Delete(&models.TwoFactor{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete two-factor of user %d: %w", userID, result.Error)
+		}
+		deleted = result.RowsAffected > 0
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("failed to delete recovery codes of user %d: %w", userID, err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.LoginChallenge{}).Error; err != nil {
+			return fmt.Errorf("failed to delete login challenges of user %d: %w", userID, err)
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// CreateChallenge saves a login challenge, purging expired ones along the way.
+func (repo *TwoFactorRepository) CreateChallenge(challenge *models.LoginChallenge) error {
+	if err := repo.db.Where("expires_at < ?", time.Now()).Delete(&models.LoginChallenge{}).Error; err != nil {
+		return fmt.Errorf("failed to purge expired login challenges: %w", err)
+	}
+	if err := repo.db.Create(challenge).Error; err != nil {
+		return fmt.Errorf("failed to save login challenge for user %d: %w", challenge.UserID, err)
+	}
+	return nil
+}
+
+// GetChallenge returns the unused challenge with the hash, nil if there is none.
+func (repo *TwoFactorRepository) GetChallenge(tokenHash string) (*models.LoginChallenge, error) {
+	var challenge models.LoginChallenge
+	err := repo.db.Where("token_hash = ? AND consumed_at IS NULL", tokenHash).First(&challenge).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get login challenge: %w", err)
+	}
+	return &challenge, nil
+}
+
+// RecordChallengeAttempt counts a code tried against the challenge before it is checked,
+// so concurrent guesses can't exceed maxAttempts. It returns false once the attempts are used up.
+func (repo *TwoFactorRepository) RecordChallengeAttempt(challengeID int64, maxAttempts int) (bool, error) {
+	result := repo.db.Model(&models.LoginChallenge{}).
+		Where("id = ? AND attempts < ?", challengeID, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record attempt at login challenge %d: %w", challengeID, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ConsumeChallenge uses up the challenge, returning false if it already was.
+func (repo *TwoFactorRepository) ConsumeChallenge(challengeID int64) (bool, error) {
+	result := repo.db.Model(&models.LoginChallenge{}).
+		Where("id = ? AND consumed_at IS NULL", challengeID).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to consume login challenge %d: %w", challengeID, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
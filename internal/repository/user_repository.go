@@ -5,6 +5,7 @@ import (
 	"mime/multipart"
 
 	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
 	"github.com/wmfadel/wander-base/internal/models/requests"
 	"github.com/wmfadel/wander-base/pkg/utils"
 	"gorm.io/gorm"
@@ -12,10 +13,10 @@ import (
 
 type UserRepository struct {
 	db      *gorm.DB
-	storage *utils.Storage
+	storage utils.Storage
 }
 
-func NewUserRepository(db *gorm.DB, storage *utils.Storage) *UserRepository {
+func NewUserRepository(db *gorm.DB, storage utils.Storage) *UserRepository {
 	return &UserRepository{db: db, storage: storage}
 }
 
@@ -75,45 +76,64 @@ func (repo *UserRepository) GetUserByPhoneAndPassword(phone, password string) (*
 	return &user, nil
 }
 
+// ValidateCredintials checks the phone and password, setting the request's ID to the user's.
+// Unknown phones still cost a password check and fail with the same error as wrong passwords,
+// so neither the response nor its timing tell whether a phone is signed up.
 func (repo *UserRepository) ValidateCredintials(loginRequest *requests.LoginRequest) error {
-
 	var user models.User
-	repo.db.Where("phone = ?", loginRequest.Phone).Find(&user)
+	if err := repo.db.Where("phone = ?", loginRequest.Phone).Find(&user).Error; err != nil {
+		return fmt.Errorf("failed to query user by phone: %w", err)
+	}
 
 	if user.ID == 0 {
-		return fmt.Errorf("failed to find user: %w", gorm.ErrRecordNotFound)
+		utils.CheckDummyPasswordHash(loginRequest.Password)
+		return core.ErrInvalidCredentials
 	}
-	loginRequest.ID = user.ID
-
-	isValidPassword := utils.CheckPasswordHash(loginRequest.Password, user.Password)
-
-	if !isValidPassword {
-		return fmt.Errorf("invalid password, compared request password hash to existing password")
+	if !utils.CheckPasswordHash(loginRequest.Password, user.Password) {
+		return core.ErrInvalidCredentials
 	}
+	loginRequest.ID = user.ID
 	return nil
 }
 
-func (repo *UserRepository) AddPhoto(user *models.User, photo *multipart.FileHeader) (string, error) {
-	// Upload new photo
-	url, err := repo.storage.UploadFile(photo, "user_photos", user.ID)
-	if err != nil {
-		return "", fmt.Errorf("failed to upload photo: %w", err)
+// AddPhoto replaces the user's photo with the uploaded image and deletes the previous one's files.
+func (repo *UserRepository) AddPhoto(userID int64, photo *multipart.FileHeader) (*models.ImageVariants, error) {
+	var user models.User
+	if err := repo.db.Select("id", "photo", "photo_original_url", "photo_medium_url", "photo_thumbnail_url").First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get user %d: %w", userID, err)
 	}
 
-	// Delete old photo if it exists
-	if user.Photo != "" {
-		if err := repo.storage.DeleteFile(user.Photo); err != nil {
-			fmt.Printf("warning: failed to delete old photo %s: %v\n", user.Photo, err)
-		}
+	// Upload new photo
+	variants, err := utils.UploadImage(repo.storage, photo, "user_photos", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload photo: %w", err)
 	}
 
 	// Update database
-	user.Photo = url
+	err = repo.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"photo":                  variants.Original.URL,
+		"photo_original_url":     variants.Original.URL,
+		"photo_original_width":   variants.Original.Width,
+		"photo_original_height":  variants.Original.Height,
+		"photo_medium_url":       variants.Medium.URL,
+		"photo_medium_width":     variants.Medium.Width,
+		"photo_medium_height":    variants.Medium.Height,
+		"photo_thumbnail_url":    variants.Thumbnail.URL,
+		"photo_thumbnail_width":  variants.Thumbnail.Width,
+		"photo_thumbnail_height": variants.Thumbnail.Height,
+	}).Error
+	if err != nil {
+		utils.DeleteImage(repo.storage, variants)
+		return nil, fmt.Errorf("failed to update user photo: %w", err)
+	}
 
-	if err := repo.db.Save(user).Error; err != nil {
-		return "", fmt.Errorf("failed to update user photo: %w", err)
+	// Delete old photo if it exists, photos uploaded before variants existed only have the original
+	old := user.PhotoVariants
+	if old.Original.URL == "" {
+		old.Original.URL = user.Photo
 	}
-	return url, nil
+	utils.DeleteImage(repo.storage, old)
+	return &variants, nil
 }
 
 func (r *UserRepository) UpdatePartially(userID int64, patch requests.PatchUser) error {
@@ -143,3 +163,67 @@ func (r *UserRepository) UpdatePartially(userID int64, patch requests.PatchUser)
 
 	return nil
 }
+
+// GetUserSummary returns the public part of a user, blocked or not, nil if there is none.
+func (repo *UserRepository) GetUserSummary(id int64) (*models.UserSummary, error) {
+	var user models.UserSummary
+	err := repo.db.First(&user, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find user %d: %w", id, err)
+	}
+	return &user, nil
+}
+
+// PhoneTaken tells if another user than exceptID has the phone.
+func (repo *UserRepository) PhoneTaken(phone string, exceptID int64) (bool, error) {
+	var count int64
+	err := repo.db.Model(&models.User{}).Where("phone = ? AND id <> ?", phone, exceptID).Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to look up phone %s: %w", phone, err)
+	}
+	return count > 0, nil
+}
+
+// GetUserByPhone returns the user with the phone, nil if there is none.
+func (repo *UserRepository) GetUserByPhone(phone string) (*models.User, error) {
+	var user models.User
+	err := repo.db.Where("phone = ?", phone).First(&user).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query user by phone: %w", err)
+	}
+	return &user, nil
+}
+
+// UpdatePassword hashes and saves the user's new password.
+func (repo *UserRepository) UpdatePassword(userID int64, password string) error {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash user password %w", err)
+	}
+	result := repo.db.Model(&models.User{}).Where("id = ?", userID).Update("password", hashedPassword)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update password of user %d: %w", userID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no user found with id %d", userID)
+	}
+	return nil
+}
+
+func (repo *UserRepository) GetPhone(id int64) (string, error) {
+	var user models.User
+	err := repo.db.Select("id", "phone").First(&user, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return "", fmt.Errorf("user %d: %w", id, core.ErrNotFound)
+		}
+		return "", fmt.Errorf("failed to find user %d: %w", id, err)
+	}
+	return user.Phone, nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"gorm.io/gorm"
+)
+
+type VerificationRepository struct {
+	db *gorm.DB
+}
+
+func NewVerificationRepository(db *gorm.DB) *VerificationRepository {
+	return &VerificationRepository{db: db}
+}
+
+// Create saves a new code and expires the user's previous ones for the same purpose,
+// only the latest code can be confirmed.
+func (repo *VerificationRepository) Create(verification *models.PhoneVerification) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.PhoneVerification{}).
+			Where("user_id = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?", verification.UserID, verification.Purpose, time.Now()).
+			Update("expires_at", time.Now()).Error
+		if err != nil {
+			return fmt.Errorf("failed to expire codes of user %d: %w", verification.UserID, err)
+		}
+		if err := tx.Create(verification).Error; err != nil {
+			return fmt.Errorf("failed to save code for user %d: %w", verification.UserID, err)
+		}
+		return nil
+	})
+}
+
+// CountSentSince counts the codes sent to the phone since the given time, whoever asked for them.
+func (repo *VerificationRepository) CountSentSince(phone string, since time.Time) (int64, error) {
+	var count int64
+	err := repo.db.Model(&models.PhoneVerification{}).
+		Where("phone = ? AND created_at > ?", phone, since).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count codes sent to %s: %w", phone, err)
+	}
+	return count, nil
+}
+
+// GetActive returns the user's latest code for one of the purposes if it can still be confirmed, nil otherwise.
+func (repo *VerificationRepository) GetActive(userID int64, purposes ...models.VerificationPurpose) (*models.PhoneVerification, error) {
+	var verification models.PhoneVerification
+	err := repo.db.
+		Where("user_id = ? AND purpose IN ? AND consumed_at IS NULL AND expires_at > ?", userID, purposes, time.Now()).
+		Order("created_at DESC, id DESC").
+		First(&verification).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get code of user %d: %w", userID, err)
+	}
+	return &verification, nil
+}
+
+// RecordAttempt counts an attempt at the code before it is checked, so concurrent guesses can't
+// exceed maxAttempts. It returns false once the attempts are used up.
+func (repo *VerificationRepository) RecordAttempt(verificationID int64, maxAttempts int) (bool, error) {
+	result := repo.db.Model(&models.PhoneVerification{}).
+		Where("id = ? AND attempts < ?", verificationID, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record attempt at code %d: %w", verificationID, result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Consume uses up the code and marks its phone verified, moving the user to it for phone changes.
+// The user updates, e.g. a new password, are applied along with it.
+func (repo *VerificationRepository) Consume(verification *models.PhoneVerification, userUpdates map[string]interface{}) error {
+	return repo.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.PhoneVerification{}).
+			Where("id = ? AND consumed_at IS NULL", verification.ID).
+			Update("consumed_at", now)
+		if result.Error != nil {
+			return fmt.Errorf("failed to consume code %d: %w", verification.ID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("code %d was already used: %w", verification.ID, core.ErrConflict)
+		}
+
+		updates := map[string]interface{}{
+			"phone":             verification.Phone,
+			"phone_verified_at": now,
+		}
+		for column, value := range userUpdates {
+			updates[column] = value
+		}
+		err := tx.Model(&models.User{}).Where("id = ?", verification.UserID).Updates(updates).Error
+		if err != nil {
+			return fmt.Errorf("failed to verify phone of user %d: %w", verification.UserID, err)
+		}
+		return nil
+	})
+}
@@ -3,10 +3,11 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/wmfadel/wander-base/internal/di"
+	"github.com/wmfadel/wander-base/internal/models"
 )
 
 func RegisterActivityRoutes(r *gin.Engine, c di.DIContainer) {
-	guarded := r.Group("/activity", c.AuthMiddleware.Authenticate, c.AuthMiddleware.RequiresAdmin)
+	guarded := r.Group("/activity", c.AuthMiddleware.Authenticate, c.AuthMiddleware.RequireVerifiedPhone, c.AuthMiddleware.RequirePermission(models.PermissionCatalogManage))
 	// Public event routes
 	guarded.GET("/:id", c.ActivityHandler.GetActivity)
 	guarded.GET("/slug/:slug", c.ActivityHandler.GetActivityBySlug)
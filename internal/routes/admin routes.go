@@ -3,18 +3,47 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/wmfadel/wander-base/internal/di"
+	"github.com/wmfadel/wander-base/internal/models"
 )
 
 func RegisterAdminRoutes(r *gin.Engine, c di.DIContainer) {
-	guared := r.Group("/admin", c.AuthMiddleware.Authenticate, c.AuthMiddleware.RequiresAdmin)
+	guared := r.Group("/admin", c.AuthMiddleware.Authenticate, c.AuthMiddleware.RequireVerifiedPhone)
 	handler := c.AdminHandler
-	guared.GET("/all", handler.GetAllRoles)             // lists all roles
-	guared.GET("/admins", handler.GetAllAdmins)         // lists admins
-	guared.GET("/organizers", handler.GetAllOrganizers) // lists organizers
-	guared.POST("/create", handler.AddRole)             // creates a new role
-	guared.POST("/delete", handler.DeleteRole)          // deletes a role
-	guared.POST("/block", handler.BlockUser)            // blocks a user
-	guared.POST("/roles", handler.AssignRoleToUser)     // assigns a role to a user
-	guared.DELETE("/roles", handler.RemoveRoleFromUser) // removes a role from a user
 
+	roles := guared.Group("/", c.AuthMiddleware.RequirePermission(models.PermissionRolesManage))
+	roles.GET("/all", handler.GetAllRoles)                            // lists all roles
+	roles.GET("/admins", handler.GetAllAdmins)                        // lists admins
+	roles.GET("/organizers", handler.GetAllOrganizers)                // lists organizers
+	roles.POST("/create", handler.AddRole)                            // creates a new role
+	roles.POST("/delete", handler.DeleteRole)                         // deletes a role
+	roles.POST("/roles", handler.AssignRoleToUser)                    // assigns a role to a user
+	roles.DELETE("/roles", handler.RemoveRoleFromUser)                // removes a role from a user
+	roles.GET("/permissions", handler.GetAllPermissions)              // lists all permissions
+	roles.GET("/roles/:id/permissions", handler.GetRolePermissions)   // lists a role's permissions
+	roles.POST("/roles/:id/permissions", handler.GrantPermissions)    // grants permissions to a role
+	roles.DELETE("/roles/:id/permissions", handler.RevokePermissions) // revokes permissions from a role
+
+	users := guared.Group("/", c.AuthMiddleware.RequirePermission(models.PermissionUsersManage))
+	users.POST("/block/:id", handler.BlockUser)                                // blocks a user
+	users.GET("/lockouts", c.SecurityHandler.GetLockouts)                      // lists phones and addresses locked out after failed logins
+	users.DELETE("/lockouts/:key", c.SecurityHandler.Unlock)                   // lifts a lockout by its key, e.g. ip:203.0.113.7
+	users.POST("/users/:id/unlock", c.SecurityHandler.UnlockUser)              // lifts the lockout of a user's phone
+	users.GET("/users/:id/sessions", handler.GetUserSessions)                  // lists a user's sessions
+	users.DELETE("/users/:id/sessions/:session_id", handler.RevokeUserSession) // logs a user's device out
+	users.DELETE("/users/:id/sessions", handler.RevokeUserSessions)            // logs a user out everywhere
+	users.DELETE("/users/:id/2fa", c.SecurityHandler.ResetTwoFactor)           // removes the two-factor of a user who lost it
+	users.GET("/security-events", c.SecurityHandler.GetSecurityEvents)         // lists lockouts and unlocks, optionally of one ?user_id
+
+	moderation := guared.Group("/", c.AuthMiddleware.RequirePermission(models.PermissionCommentsModerate))
+	moderation.GET("/comments", c.CommentHandler.GetModerationQueue)          // lists comments by status, pending by default
+	moderation.POST("/comments/:id/approve", c.CommentHandler.ApproveComment) // shows a comment whatever the moderator said
+	moderation.POST("/comments/:id/hide", c.CommentHandler.HideComment)       // hides a comment whatever the moderator said
+
+	reports := guared.Group("/", c.AuthMiddleware.RequirePermission(models.PermissionReportsManage))
+	reports.GET("/reports", c.ReportHandler.GetQueue)                                 // lists reported items, most reported first
+	reports.GET("/reports/:target_type/:target_id", c.ReportHandler.GetDetails)       // lists an item's open reports and past actions
+	reports.POST("/reports/:target_type/:target_id/dismiss", c.ReportHandler.Dismiss) // closes the reports, showing auto-hidden content again
+	reports.POST("/reports/:target_type/:target_id/remove", c.ReportHandler.Remove)   // deletes the reported comment or photo
+	reports.POST("/reports/:target_type/:target_id/block", c.ReportHandler.Block)     // blocks the reported user or the author, needs users:manage too
+	reports.GET("/moderation-log", c.ReportHandler.GetActions)                        // lists the latest actions taken on reports
 }
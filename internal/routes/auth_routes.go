@@ -8,5 +8,10 @@ import (
 func RegisterAuthRoutes(r *gin.Engine, c di.DIContainer) {
 	r.POST("/signup", c.AuthHandler.SignupHandler)
 	r.POST("/login", c.AuthHandler.LoginHandler)
+	r.POST("/login/2fa", c.AuthHandler.LoginTwoFactorHandler)
+	r.POST("/token/refresh", c.AuthHandler.RefreshHandler)
 	r.POST("/logout", c.AuthMiddleware.Authenticate, c.AuthHandler.LogoutHandler)
+	r.PUT("/users/me/password", c.AuthMiddleware.Authenticate, c.AuthHandler.ChangePasswordHandler)
+	r.POST("/password/forgot", c.AuthHandler.ForgotPasswordHandler)
+	r.POST("/password/reset", c.AuthHandler.ResetPasswordHandler)
 }
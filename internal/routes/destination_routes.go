@@ -3,10 +3,11 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/wmfadel/wander-base/internal/di"
+	"github.com/wmfadel/wander-base/internal/models"
 )
 
 func RegisterDestinationRoutes(r *gin.Engine, c di.DIContainer) {
-	guarded := r.Group("/destination", c.AuthMiddleware.Authenticate, c.AuthMiddleware.RequiresAdmin)
+	guarded := r.Group("/destination", c.AuthMiddleware.Authenticate, c.AuthMiddleware.RequireVerifiedPhone, c.AuthMiddleware.RequirePermission(models.PermissionCatalogManage))
 	// Public event routes
 	guarded.GET("/:id", c.DestinationHandler.GetDestinationById)
 	guarded.GET("/", c.DestinationHandler.GetAllDestinations)
@@ -1,31 +1,89 @@
 package routes
 
 import (
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	"github.com/wmfadel/wander-base/internal/di"
+	"github.com/wmfadel/wander-base/internal/models"
 )
 
 func RegisterEventRoutes(r *gin.Engine, c di.DIContainer) {
-	// Public event routes
-	r.GET("/events", c.EventHandler.GetEvents)
-	r.GET("/events/:id", c.EventHandler.GetEvent)
+	// Public event routes, drafts are only shown to their managers
+	public := r.Group("/", c.AuthMiddleware.OptionalAuthenticate)
+	public.GET("/events", c.EventHandler.GetEvents)
+	// gin can't register /events/:id.ics next to /events/:id, so the .ics suffix is dispatched here
+	public.GET("/events/:id", func(ctx *gin.Context) {
+		if strings.HasSuffix(ctx.Param("id"), ".ics") {
+			c.CalendarHandler.GetEventCalendar(ctx)
+			return
+		}
+		c.EventHandler.GetEvent(ctx)
+	})
+	public.GET("/events/:id/itinerary", c.ItineraryHandler.GetItinerary)
+	public.GET("/events/:id/albums", c.PhotoAlbumHandler.GetAlbums)
 
-	// Guarded event routes (require authentication)
-	guarded := r.Group("/", c.AuthMiddleware.Authenticate)
+	// Guarded event routes (require authentication and a verified phone)
+	guarded := r.Group("/", c.AuthMiddleware.Authenticate, c.AuthMiddleware.RequireVerifiedPhone)
 
 	// Event edit routes (require authentication + authorization)
-	editGuarded := guarded.Group("/", c.AuthMiddleware.RequiresAdmin)
-	editGuarded.POST("/events", c.EventHandler.CreateEvent)
+	guarded.POST("/events", c.AuthMiddleware.RequirePermission(models.PermissionEventsCreate), c.EventHandler.CreateEvent)
+	editGuarded := guarded.Group("/", c.AuthMiddleware.RequiresEventManager)
 	editGuarded.PUT("/events/:id", c.EventHandler.UpdateEvent)
 	editGuarded.DELETE("/events/:id", c.EventHandler.DeleteEvent)
+	editGuarded.POST("/events/:id/publish", c.EventHandler.PublishEvent)
+	editGuarded.POST("/events/:id/unpublish", c.EventHandler.UnpublishEvent)
+	editGuarded.POST("/events/:id/cancel", c.EventHandler.CancelEvent)
+	editGuarded.POST("/events/:id/complete", c.EventHandler.CompleteEvent)
 	editGuarded.POST("/events/photos/:id", c.EventHandler.AddPhotos)
 	editGuarded.DELETE("/events/photos/:id", c.EventHandler.DeletePhotos)
+	editGuarded.GET("/events/:id/photos", c.EventHandler.GetPhotos)
+	editGuarded.POST("/events/:id/photos/approve", c.EventHandler.ApprovePhotos)
+	editGuarded.POST("/events/:id/photos/reject", c.EventHandler.RejectPhotos)
+	editGuarded.PUT("/events/:id/photos/order", c.EventHandler.ReorderPhotos)
+	editGuarded.DELETE("/events/:id/photos", c.EventHandler.DeletePhoto)
+	editGuarded.DELETE("/events/:id/photos/:photo_id", c.EventHandler.DeletePhoto)
+	editGuarded.PATCH("/events/:id/photos/:photo_id", c.EventHandler.UpdatePhoto)
+	editGuarded.PUT("/events/:id/photos/:photo_id/cover", c.EventHandler.SetCoverPhoto)
+	editGuarded.DELETE("/events/:id/cover", c.EventHandler.ClearCoverPhoto)
+	editGuarded.POST("/events/:id/albums", c.PhotoAlbumHandler.CreateAlbum)
+	editGuarded.PUT("/events/:id/albums/:album_id", c.PhotoAlbumHandler.UpdateAlbum)
+	editGuarded.DELETE("/events/:id/albums/:album_id", c.PhotoAlbumHandler.DeleteAlbum)
+	editGuarded.POST("/events/:id/cohosts", c.EventHandler.AddCoHost)
+	editGuarded.DELETE("/events/:id/cohosts/:user_id", c.EventHandler.RemoveCoHost)
+	editGuarded.POST("/events/:id/destinations", c.EventHandler.SetEventDestinations)
+	editGuarded.DELETE("/events/:id/destinations", c.EventHandler.RemoveDestinations)
+	editGuarded.POST("/events/:id/activities", c.EventHandler.AddActivities)
+	editGuarded.DELETE("/events/:id/activities", c.EventHandler.RemoveActivities)
+	editGuarded.PUT("/events/:id/itinerary", c.ItineraryHandler.SetItinerary)
+
+	// Registration approval routes (event managers only)
+	editGuarded.GET("/events/:id/registrations", c.RegistrationHandler.GetEventRegistrations)
+	editGuarded.POST("/events/:id/registrations/approve", c.RegistrationHandler.ApproveRegistrations)
+	editGuarded.POST("/events/:id/registrations/reject", c.RegistrationHandler.RejectRegistrations)
+	editGuarded.POST("/events/:id/registrations/:user_id/approve", c.RegistrationHandler.ApproveRegistrations)
+	editGuarded.POST("/events/:id/registrations/:user_id/reject", c.RegistrationHandler.RejectRegistrations)
+	editGuarded.POST("/events/:id/cancellations/approve", c.RegistrationHandler.ApproveCancellations)
+	editGuarded.POST("/events/:id/cancellations/reject", c.RegistrationHandler.RejectCancellations)
+	editGuarded.POST("/events/:id/cancellations/:user_id/approve", c.RegistrationHandler.ApproveCancellations)
+	editGuarded.POST("/events/:id/cancellations/:user_id/reject", c.RegistrationHandler.RejectCancellations)
 
 	// Registration routes (authentication only)
 	guarded.POST("/events/:id/register", c.RegistrationHandler.RegisterForEvent)
 	guarded.DELETE("/events/:id/register", c.RegistrationHandler.CancelRegistrationEvent)
 
+	// Photographers upload to events they attend, managers get their photos approved right away
+	guarded.POST("/events/:id/photos", c.AuthMiddleware.RequirePermission(models.PermissionPhotosUpload), c.EventHandler.UploadPhotos)
+	guarded.GET("/events/:id/photos/archive", c.EventHandler.DownloadPhotoArchive) // attendees and managers only
+
 	// Comments
 	guarded.GET("/events/:id/comments", c.CommentHandler.GetEventComments)
 	guarded.POST("/events/:id/comments", c.CommentHandler.Create)
+	guarded.GET("/comments/:id", c.CommentHandler.GetComment)
+	guarded.PATCH("/comments/:id", c.CommentHandler.EditComment)
+	guarded.DELETE("/comments/:id", c.CommentHandler.DeleteComment)
+	guarded.GET("/comments/:id/replies", c.CommentHandler.GetReplies)
+	guarded.GET("/comments/:id/history", c.CommentHandler.GetCommentHistory)
+	guarded.POST("/comments/:id/reactions", c.CommentHandler.React)
+	guarded.DELETE("/comments/:id/reactions", c.CommentHandler.Unreact)
 }
@@ -8,7 +8,26 @@ import (
 func RegisterProfileRoutes(r *gin.Engine, c di.DIContainer) {
 	guarded := r.Group("/", c.AuthMiddleware.Authenticate)
 	// Public event routes
-	guarded.GET("/users", c.ProfileHandler.GetProfile)    // Get Profile
-	guarded.PUT("/users", c.ProfileHandler.UpdateProfile) // Update Profile
-	guarded.POST("/photo", c.ProfileHandler.UpdatePhoto)  // Update Profile
+	guarded.GET("/users", c.ProfileHandler.GetProfile)                                       // Get Profile
+	guarded.PUT("/users", c.ProfileHandler.UpdateProfile)                                    // Update Profile
+	guarded.POST("/photo", c.ProfileHandler.UpdatePhoto)                                     // Update Profile
+	guarded.POST("/users/me/calendar/token", c.CalendarHandler.RotateFeedToken)              // Issue calendar feed URL
+	guarded.POST("/users/me/phone/code", c.VerificationHandler.SendCode)                     // Resend the signup verification code
+	guarded.POST("/users/me/phone/verify", c.VerificationHandler.VerifyPhone)                // Confirm a verification code
+	guarded.PUT("/users/me/phone", c.VerificationHandler.ChangePhone)                        // Send a code to a new phone
+	guarded.GET("/users/me/2fa", c.TwoFactorHandler.GetStatus)                               // Two-factor status
+	guarded.POST("/users/me/2fa/code", c.TwoFactorHandler.SendEnrollmentCode)                // Text the code roles requiring two-factor enroll with
+	guarded.POST("/users/me/2fa", c.TwoFactorHandler.Enroll)                                 // Start setting up an authenticator
+	guarded.POST("/users/me/2fa/confirm", c.TwoFactorHandler.Confirm)                        // Enable two-factor with a first code
+	guarded.DELETE("/users/me/2fa", c.TwoFactorHandler.Disable)                              // Disable two-factor
+	guarded.POST("/users/me/2fa/recovery-codes", c.TwoFactorHandler.RegenerateRecoveryCodes) // Replace the recovery codes
+	guarded.GET("/users/me/sessions", c.SessionHandler.GetSessions)                          // List the devices logged in
+	guarded.DELETE("/users/me/sessions/:id", c.SessionHandler.RevokeSession)                 // Log a device out
+	guarded.DELETE("/users/me/sessions", c.SessionHandler.RevokeOtherSessions)               // Log out everywhere else
+
+	verified := guarded.Group("/", c.AuthMiddleware.RequireVerifiedPhone)
+	verified.POST("/reports", c.ReportHandler.Report) // Report a comment, photo or user
+
+	// Calendar apps can't authenticate with a bearer token, the feed URL carries its own token
+	r.GET("/users/me/calendar.ics", c.CalendarHandler.GetUserFeed)
 }
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/repository"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+type CalendarService struct {
+	tokenRepo        *repository.TokenRepository
+	registrationRepo *repository.RegistrationRepository
+}
+
+func NewCalendarService(tokenRepo *repository.TokenRepository, registrationRepo *repository.RegistrationRepository) *CalendarService {
+	return &CalendarService{tokenRepo: tokenRepo, registrationRepo: registrationRepo}
+}
+
+// EventCalendar exports a single event, each scheduled destination becomes its own VEVENT related to the event.
+func (s *CalendarService) EventCalendar(event *models.Event) []byte {
+	calendar := utils.NewICalendar(event.Name)
+	stamp := time.Now()
+	writeEvent(calendar, event, "CONFIRMED", stamp)
+	for _, stop := range event.Schedule {
+		calendar.Begin("VEVENT")
+		calendar.WriteLine("UID", fmt.Sprintf("event-%d-destination-%d@wander-base", event.ID, stop.DestinationID))
+		calendar.WriteTime("DTSTAMP", stamp)
+		calendar.WriteTime("DTSTART", stop.DateTime)
+		calendar.WriteText("SUMMARY", stop.Destination.Name)
+		calendar.WriteText("LOCATION", stop.Destination.Location)
+		if stop.Destination.Description != "" {
+			calendar.WriteText("DESCRIPTION", stop.Destination.Description)
+		}
+		calendar.WriteLine("RELATED-TO", eventUID(event.ID))
+		calendar.End("VEVENT")
+	}
+	return calendar.Finish()
+}
+
+// RotateFeedToken issues a new calendar feed token for the user, the previous feed URL stops working.
+func (s *CalendarService) RotateFeedToken(userID int64) (string, error) {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = s.tokenRepo.SaveCalendarToken(&models.CalendarToken{UserID: userID, TokenHash: utils.HashToken(token)})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// UserFeed lists every event the feed token's owner holds a seat for.
+func (s *CalendarService) UserFeed(token string) ([]byte, error) {
+	feedToken, err := s.tokenRepo.GetCalendarTokenByHash(utils.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if feedToken == nil {
+		return nil, fmt.Errorf("unknown calendar token: %w", core.ErrNotFound)
+	}
+
+	registrations, err := s.registrationRepo.GetUserRegistrations(feedToken.UserID, models.SeatHoldingStatuses)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := utils.NewICalendar("Wander events")
+	stamp := time.Now()
+	for _, registration := range registrations {
+		status := "CONFIRMED"
+		if registration.Status == models.PendingRegistration {
+			status = "TENTATIVE"
+		}
+		writeEvent(calendar, &registration.Event, status, stamp)
+	}
+	return calendar.Finish(), nil
+}
+
+func writeEvent(calendar *utils.ICalendar, event *models.Event, status string, stamp time.Time) {
+	if event.Status == models.EventCancelled {
+		status = "CANCELLED"
+	}
+	calendar.Begin("VEVENT")
+	calendar.WriteLine("UID", eventUID(event.ID))
+	calendar.WriteTime("DTSTAMP", stamp)
+	// Series are written in their timezone, clients expand their rule in the zone of DTSTART
+	start := event.DateTime.UTC()
+	if event.IsRecurring() {
+		start = event.SeriesStart()
+	}
+	calendar.WriteLocalTime("DTSTART", start)
+	if event.EndDateTime != nil {
+		calendar.WriteLocalTime("DTEND", event.EndDateTime.In(start.Location()))
+	}
+	calendar.WriteText("SUMMARY", event.Name)
+	if event.Description != "" {
+		calendar.WriteText("DESCRIPTION", event.Description)
+	}
+	calendar.WriteText("LOCATION", event.Location)
+	if event.IsRecurring() {
+		calendar.WriteLine("RRULE", event.RRule)
+		exdates, err := utils.ParseExDates(event.ExDates)
+		if err != nil {
+			log.Printf("Warning: event %d has invalid exdates %q: %v", event.ID, event.ExDates, err)
+		}
+		for _, exdate := range exdates {
+			calendar.WriteLocalTime("EXDATE", exdate.In(start.Location()))
+		}
+	}
+	calendar.WriteLine("STATUS", status)
+	calendar.End("VEVENT")
+}
+
+func eventUID(eventID int64) string {
+	return fmt.Sprintf("event-%d@wander-base", eventID)
+}
@@ -1,38 +1,311 @@
 package service
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
 	"github.com/wmfadel/wander-base/internal/repository"
+	"github.com/wmfadel/wander-base/pkg/utils"
 )
 
+// ModerationQueueLimit caps how many comments the admins' moderation queue returns at once.
+const ModerationQueueLimit = 100
+
+// RepliesPerThread caps the replies listed with each thread, the rest are paged through GetThreadReplies.
+const RepliesPerThread = 20
+
 type CommentService struct {
-	repo         *repository.CommentRepository
-	auditService *ModerationService
+	repo  *repository.CommentRepository
+	queue *ModerationQueue
 }
 
-func NewCommentService(repo *repository.CommentRepository, auditService *ModerationService) *CommentService {
+func NewCommentService(repo *repository.CommentRepository, queue *ModerationQueue) *CommentService {
 	return &CommentService{
-		repo:         repo,
-		auditService: auditService,
+		repo:  repo,
+		queue: queue,
+	}
+}
+
+// Create saves the comment as pending right away and leaves its moderation to the queue.
+// Replies have to answer a comment of the same event that the author can see and that isn't deleted.
+func (service *CommentService) Create(comment *models.Comment, author *models.User) error {
+	if comment.ParentID != nil {
+		parent, err := service.GetCommentById(*comment.ParentID, author)
+		if err != nil {
+			return err
+		}
+		if parent.EventID != comment.EventID {
+			return fmt.Errorf("comment %d belongs to another event: %w", parent.ID, core.ErrInvalidInput)
+		}
+		if parent.IsDeleted() {
+			return fmt.Errorf("comment %d is deleted: %w", parent.ID, core.ErrConflict)
+		}
+		comment.RootID = parent.RootID
+		if comment.RootID == nil {
+			comment.RootID = &parent.ID
+		}
+	}
+
+	comment.Status, comment.Visible = models.CommentPending, false
+	if err := service.repo.Create(comment); err != nil {
+		return err
 	}
+	service.queue.Enqueue(comment.ID)
+	return nil
 }
 
-func (service *CommentService) Create(comment *models.Comment) error {
-	// TODO: Uncomment this when we have a moderation service
-	// if err := service.auditService.AuditComment(comment); err != nil {
-	// 	return fmt.Errorf("failed to audit comment: %w", err)
-	// }
-	return service.repo.Create(comment)
+// Edit lets authors change their comments, the new content goes through moderation again.
+func (service *CommentService) Edit(commentId int64, content string, editor *models.User) (*models.Comment, error) {
+	comment, err := service.GetCommentById(commentId, editor)
+	if err != nil {
+		return nil, err
+	}
+	if comment.UserID != editor.ID {
+		return nil, fmt.Errorf("only the author may edit comment %d: %w", commentId, core.ErrForbidden)
+	}
+
+	comment, err = service.repo.Edit(commentId, content, editor.ID)
+	if err != nil {
+		return nil, err
+	}
+	service.queue.Enqueue(comment.ID)
+	return comment, nil
 }
 
-func (service *CommentService) Delete(commentId int64) error {
-	return service.repo.Delete(commentId)
+// GetEdits returns the comment's edit history to its author and to moderators.
+func (service *CommentService) GetEdits(commentId int64, viewer *models.User) ([]models.CommentEdit, error) {
+	comment, err := service.GetCommentById(commentId, viewer)
+	if err != nil {
+		return nil, err
+	}
+	if comment.UserID != viewer.ID && !viewer.HasPermission(models.PermissionCommentsModerate) {
+		return nil, fmt.Errorf("only the author and moderators may see the history of comment %d: %w", commentId, core.ErrForbidden)
+	}
+	return service.repo.GetEdits(commentId)
 }
 
-func (service *CommentService) GetCommentById(commentId int64) (*models.Comment, error) {
-	return service.repo.GetCommentById(commentId)
+// Delete soft deletes a comment for its author or a moderator.
+func (service *CommentService) Delete(commentId int64, user *models.User) error {
+	comment, err := service.GetCommentById(commentId, user)
+	if err != nil {
+		return err
+	}
+	if comment.UserID != user.ID && !user.HasPermission(models.PermissionCommentsModerate) {
+		return fmt.Errorf("only the author and moderators may delete comment %d: %w", commentId, core.ErrForbidden)
+	}
+	return service.repo.SoftDelete(commentId, user.ID)
 }
 
-func (service *CommentService) GetEventComments(EventID int64) ([]models.Comment, error) {
-	return service.repo.GetEventComments(EventID)
+// GetCommentById returns the comment if the viewer may see it, with its reactions.
+func (service *CommentService) GetCommentById(commentId int64, viewer *models.User) (*models.Comment, error) {
+	comment, err := service.repo.GetCommentById(commentId)
+	if err != nil {
+		return nil, err
+	}
+	moderator := viewer.HasPermission(models.PermissionCommentsModerate)
+	if comment == nil || !(moderator || comment.Status == models.CommentVisible ||
+		(comment.Status == models.CommentPending && comment.UserID == viewer.ID)) {
+		return nil, fmt.Errorf("comment %d: %w", commentId, core.ErrNotFound)
+	}
+
+	counts, err := service.repo.GetReactionCounts([]int64{comment.ID}, viewer.ID)
+	if err != nil {
+		return nil, err
+	}
+	comment.Reactions = counts[comment.ID]
+	if !moderator {
+		redactDeleted(comment)
+	}
+	return comment, nil
+}
+
+// React adds the viewer's emoji to a comment they can see.
+func (service *CommentService) React(commentId int64, emoji string, user *models.User) error {
+	if !models.ValidEmoji(emoji) {
+		return fmt.Errorf("%q is not an emoji: %w", emoji, core.ErrInvalidInput)
+	}
+	comment, err := service.GetCommentById(commentId, user)
+	if err != nil {
+		return err
+	}
+	if comment.IsDeleted() {
+		return fmt.Errorf("comment %d is deleted: %w", commentId, core.ErrConflict)
+	}
+	return service.repo.AddReaction(commentId, user.ID, emoji)
+}
+
+func (service *CommentService) Unreact(commentId int64, emoji string, user *models.User) error {
+	return service.repo.RemoveReaction(commentId, user.ID, emoji)
+}
+
+// GetEventComments returns a page of threads. Visible comments are listed, authors also see their
+// own pending ones and moderators see every comment. Deleted comments are kept as placeholders
+// while they have replies. Threads list their first RepliesPerThread replies and a replies_cursor
+// to page through the rest.
+func (service *CommentService) GetEventComments(EventID int64, viewer *models.User, query requests.CommentQuery) (*models.CommentPage, error) {
+	moderator := viewer.HasPermission(models.PermissionCommentsModerate)
+	page, err := service.repo.GetThreads(EventID, viewer.ID, moderator, query)
+	if err != nil {
+		return nil, err
+	}
+
+	rootIDs := make([]int64, 0, len(page.Comments))
+	for _, root := range page.Comments {
+		rootIDs = append(rootIDs, root.ID)
+	}
+	loaded, err := service.repo.GetReplies(rootIDs, viewer.ID, moderator, RepliesPerThread)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replies come oldest first, so the ones kept never miss the comment they reply to
+	listed := map[int64][]models.Comment{}
+	more := map[int64]string{}
+	replies := make([]models.Comment, 0, len(loaded))
+	for _, reply := range loaded {
+		root := *reply.RootID
+		if thread := listed[root]; len(thread) == RepliesPerThread {
+			last := thread[len(thread)-1]
+			more[root] = utils.EncodeCursor(last.CreatedAt.Format(time.RFC3339Nano), last.ID)
+			continue
+		}
+		listed[root] = append(listed[root], reply)
+		replies = append(replies, reply)
+	}
+	for i := range page.Comments {
+		page.Comments[i].RepliesCursor = more[page.Comments[i].ID]
+	}
+
+	ids := rootIDs
+	for _, reply := range replies {
+		ids = append(ids, reply.ID)
+	}
+	counts, err := service.repo.GetReactionCounts(ids, viewer.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Comments = buildCommentTree(page.Comments, replies, counts, moderator)
+	return page, nil
+}
+
+// GetThreadReplies pages through the replies of a thread the viewer can see, oldest first, picking up
+// from the replies_cursor of the thread in GetEventComments. Replies are listed flat, parent_id nests them.
+func (service *CommentService) GetThreadReplies(commentId int64, viewer *models.User, query requests.CommentQuery) (*models.CommentPage, error) {
+	root, err := service.GetCommentById(commentId, viewer)
+	if err != nil {
+		return nil, err
+	}
+	if root.IsReply() {
+		return nil, fmt.Errorf("comment %d is a reply, page through its thread instead: %w", commentId, core.ErrInvalidInput)
+	}
+	moderator := viewer.HasPermission(models.PermissionCommentsModerate)
+	page, err := service.repo.GetThreadReplies(root.ID, viewer.ID, moderator, query)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(page.Comments))
+	for _, reply := range page.Comments {
+		ids = append(ids, reply.ID)
+	}
+	counts, err := service.repo.GetReactionCounts(ids, viewer.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range page.Comments {
+		page.Comments[i].Reactions = counts[page.Comments[i].ID]
+		if !moderator {
+			redactDeleted(&page.Comments[i])
+		}
+	}
+	return page, nil
+}
+
+// buildCommentTree nests the replies under their parents. Replies whose parent isn't shown to
+// the viewer are left out along with their own replies.
+func buildCommentTree(roots, replies []models.Comment, counts map[int64][]models.ReactionCount, moderator bool) []models.Comment {
+	children := map[int64][]models.Comment{}
+	for _, reply := range replies {
+		children[*reply.ParentID] = append(children[*reply.ParentID], reply)
+	}
+
+	var attach func(comment models.Comment) (models.Comment, bool)
+	attach = func(comment models.Comment) (models.Comment, bool) {
+		comment.Reactions = counts[comment.ID]
+		comment.Replies = nil
+		for _, child := range children[comment.ID] {
+			if child, keep := attach(child); keep {
+				comment.Replies = append(comment.Replies, child)
+			}
+		}
+		if comment.IsDeleted() && !moderator {
+			redactDeleted(&comment)
+			return comment, len(comment.Replies) > 0
+		}
+		return comment, true
+	}
+
+	tree := make([]models.Comment, 0, len(roots))
+	for _, root := range roots {
+		if root, keep := attach(root); keep {
+			tree = append(tree, root)
+		}
+	}
+	return tree
+}
+
+// redactDeleted hides what a deleted comment said, moderators still see it.
+func redactDeleted(comment *models.Comment) {
+	if comment.IsDeleted() {
+		comment.Content, comment.Scores, comment.Reactions = "", nil, nil
+	}
+}
+
+func (service *CommentService) GetModerationQueue(status models.CommentStatus) ([]models.Comment, error) {
+	if status == "" {
+		status = models.CommentPending
+	}
+	if !status.Valid() {
+		return nil, fmt.Errorf("unknown comment status %q: %w", status, core.ErrInvalidInput)
+	}
+	return service.repo.GetModerationQueue(status, ModerationQueueLimit)
+}
+
+func (service *CommentService) Approve(commentId int64, reviewerId int64) (*models.Comment, error) {
+	return service.repo.Override(commentId, models.CommentVisible, reviewerId)
+}
+
+func (service *CommentService) Hide(commentId int64, reviewerId int64) (*models.Comment, error) {
+	return service.repo.Override(commentId, models.CommentHidden, reviewerId)
+}
+
+// Remove soft deletes a reported comment for the admin handling its reports, whether they may see it or not.
+// Comments that are already deleted are left as they are.
+func (service *CommentService) Remove(commentId int64, removedById int64) error {
+	comment, err := service.repo.GetCommentById(commentId)
+	if err != nil {
+		return err
+	}
+	if comment == nil {
+		return fmt.Errorf("comment %d: %w", commentId, core.ErrNotFound)
+	}
+	if comment.IsDeleted() {
+		return nil
+	}
+	return service.repo.SoftDelete(commentId, removedById)
+}
+
+func (service *CommentService) GetAuthorId(commentId int64) (int64, error) {
+	comment, err := service.repo.GetCommentById(commentId)
+	if err != nil {
+		return 0, err
+	}
+	if comment == nil {
+		return 0, fmt.Errorf("comment %d: %w", commentId, core.ErrNotFound)
+	}
+	return comment.UserID, nil
 }
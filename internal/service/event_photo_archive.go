@@ -0,0 +1,147 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+)
+
+const archiveManifestName = "manifest.csv"
+
+// CanDownloadArchive makes sure the user manages the event or attends it.
+func (s *EventPhotoService) CanDownloadArchive(event *models.Event, user *models.User) error {
+	if event.CanBeManagedBy(user) {
+		return nil
+	}
+	attends, err := s.attends(event.ID, user.ID)
+	if err != nil {
+		return err
+	}
+	if !attends {
+		return fmt.Errorf("user %d does not attend event %d: %w", user.ID, event.ID, core.ErrForbidden)
+	}
+	return nil
+}
+
+// WriteArchive streams a ZIP of the event's approved photos to w, one photo at a time straight from storage.
+// Photos are named by their position and caption, in a folder per album, and a manifest CSV lists
+// every photo with its caption and uploader. Photos whose files can't be read are left out of the
+// archive and listed in the manifest without a file.
+func (s *EventPhotoService) WriteArchive(w io.Writer, eventID int64) error {
+	photos, err := s.repo.GetPhotos(eventID, models.PhotoApproved)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	rows := [][]string{{"file", "caption", "album", "uploaded_by", "uploaded_at", "url"}}
+	used := map[string]bool{archiveManifestName: true}
+	for i, photo := range photos {
+		name := archiveFileName(i+1, photo, used)
+		file, err := s.repo.OpenPhoto(photo)
+		if err != nil {
+			log.Printf("Warning: leaving photo %d out of the archive of event %d: %v", photo.ID, eventID, err)
+			name = ""
+		} else {
+			err = writeArchiveEntry(archive, name, photo.UploadedAt, file)
+			file.Close()
+			if err != nil {
+				// The client gets a truncated archive, nothing sensible can follow a partly written entry
+				return err
+			}
+		}
+
+		album, uploader := "", ""
+		if photo.Album != nil {
+			album = photo.Album.Name
+		}
+		if photo.Photographer != nil {
+			uploader = strings.TrimSpace(photo.Photographer.FirstName + " " + photo.Photographer.LastName)
+		}
+		rows = append(rows, []string{name, csvText(photo.Caption), csvText(album), csvText(uploader), photo.UploadedAt.UTC().Format(time.RFC3339), photo.URL})
+	}
+
+	manifest, err := archive.CreateHeader(&zip.FileHeader{Name: archiveManifestName, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return fmt.Errorf("failed to add manifest: %w", err)
+	}
+	if err := csv.NewWriter(manifest).WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return archive.Close()
+}
+
+func writeArchiveEntry(archive *zip.Writer, name string, modified time.Time, content io.Reader) error {
+	// Photos are already compressed, storing them saves the CPU time
+	entry, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	if _, err := io.Copy(entry, content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// archiveFileName names a photo e.g. "day-one/003-sunset-at-the-dunes.jpg", keeping names unique.
+func archiveFileName(index int, photo models.EventPhoto, used map[string]bool) string {
+	name := fmt.Sprintf("%03d", index)
+	if caption := slugify(photo.Caption); caption != "" {
+		name += "-" + caption
+	}
+	if photo.Album != nil {
+		if album := slugify(photo.Album.Name); album != "" {
+			name = album + "/" + name
+		}
+	}
+
+	extension := strings.ToLower(path.Ext(photo.URL))
+	if extension == "" {
+		extension = ".jpg"
+	}
+	unique := name + extension
+	for n := 2; used[unique]; n++ {
+		unique = fmt.Sprintf("%s-%d%s", name, n, extension)
+	}
+	used[unique] = true
+	return unique
+}
+
+// csvText keeps spreadsheets from running user text as a formula, cells starting with
+// =, +, -, @, a tab or a carriage return are prefixed with a quote.
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// slugify keeps letters and digits of any script, joined by dashes, and caps the length.
+func slugify(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+		if b.Len() >= 60 {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,22 @@
+package service
+
+import "testing"
+
+func TestCSVText(t *testing.T) {
+	tests := map[string]string{
+		"":                        "",
+		"Sunset at the dunes":     "Sunset at the dunes",
+		"=HYPERLINK(\"x\",\"y\")": "'=HYPERLINK(\"x\",\"y\")",
+		"+1 555 0100":             "'+1 555 0100",
+		"-2+3":                    "'-2+3",
+		"@SUM(A1)":                "'@SUM(A1)",
+		"\t=1":                    "'\t=1",
+		"\r=1":                    "'\r=1",
+		"a=1":                     "a=1",
+	}
+	for text, want := range tests {
+		if got := csvText(text); got != want {
+			t.Errorf("csvText(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
@@ -5,28 +5,146 @@ import (
 	"mime/multipart"
 
 	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
 	"github.com/wmfadel/wander-base/internal/repository"
 )
 
 type EventPhotoService struct {
-	repo *repository.EventPhotoRepository
+	repo             *repository.EventPhotoRepository
+	albumRepo        *repository.PhotoAlbumRepository
+	registrationRepo *repository.RegistrationRepository
 }
 
-func NewEventPhotoService(repo *repository.EventPhotoRepository) *EventPhotoService {
-	return &EventPhotoService{repo: repo}
+func NewEventPhotoService(repo *repository.EventPhotoRepository, albumRepo *repository.PhotoAlbumRepository, registrationRepo *repository.RegistrationRepository) *EventPhotoService {
+	return &EventPhotoService{repo: repo, albumRepo: albumRepo, registrationRepo: registrationRepo}
 }
 
-func (s *EventPhotoService) AddPhotos(eventID int64, photos []*multipart.FileHeader) error {
+// AddPhotos uploads photos to the event. Photos of the event's managers are approved right away,
+// photographers may only upload to events they attend and their photos wait for review.
+// Cancelled and completed events take no more photos.
+func (s *EventPhotoService) AddPhotos(event *models.Event, uploader *models.User, photos []*multipart.FileHeader) (models.PhotoStatus, error) {
 	if len(photos) == 0 {
-		return fmt.Errorf("no photos provided")
+		return "", fmt.Errorf("no photos provided: %w", core.ErrInvalidInput)
 	}
-	return s.repo.AddPhotos(eventID, photos)
+	if event.Status.Final() {
+		return "", fmt.Errorf("event %d is %s: %w", event.ID, event.Status, core.ErrConflict)
+	}
+
+	status := models.PhotoApproved
+	if !event.CanBeManagedBy(uploader) {
+		if !uploader.HasPermission(models.PermissionPhotosUpload) {
+			return "", fmt.Errorf("user %d may not upload photos: %w", uploader.ID, core.ErrForbidden)
+		}
+		attends, err := s.attends(event.ID, uploader.ID)
+		if err != nil {
+			return "", err
+		}
+		if !attends {
+			return "", fmt.Errorf("user %d does not attend event %d: %w", uploader.ID, event.ID, core.ErrForbidden)
+		}
+		status = models.PhotoPending
+	}
+	return status, s.repo.AddPhotos(event.ID, uploader.ID, status, photos)
+}
+
+// GetPhotos lists the event's photos in the given status, all of them when the status is empty.
+func (s *EventPhotoService) GetPhotos(eventID int64, status models.PhotoStatus) ([]models.EventPhoto, error) {
+	if status != "" && !status.Valid() {
+		return nil, fmt.Errorf("unknown photo status %q: %w", status, core.ErrInvalidInput)
+	}
+	return s.repo.GetPhotos(eventID, status)
+}
+
+func (s *EventPhotoService) ApprovePhotos(eventID int64, photoIDs []int64, review models.PhotoReview) error {
+	_, err := s.repo.ReviewPhotos(eventID, photoIDs, models.PhotoApproved, review)
+	return err
+}
+
+// RejectPhotos rejects pending photos and deletes their files once that is saved, the rows stay to keep
+// the review. Files that fail to delete are logged, the photos are rejected either way.
+func (s *EventPhotoService) RejectPhotos(eventID int64, photoIDs []int64, review models.PhotoReview) error {
+	photos, err := s.repo.ReviewPhotos(eventID, photoIDs, models.PhotoRejected, review)
+	if err != nil {
+		return err
+	}
+	s.repo.DeleteFiles(photos)
+	return nil
+}
+
+func (s *EventPhotoService) UpdatePhoto(eventID, photoID int64, patch requests.PatchPhotoRequest) (*models.EventPhoto, error) {
+	return s.repo.UpdatePhoto(eventID, photoID, patch)
+}
+
+func (s *EventPhotoService) ReorderPhotos(eventID int64, photoIDs []int64) error {
+	return s.repo.ReorderPhotos(eventID, photoIDs)
+}
+
+func (s *EventPhotoService) SetCover(eventID, photoID int64) error {
+	return s.repo.SetCover(eventID, photoID)
+}
+
+func (s *EventPhotoService) ClearCover(eventID int64) error {
+	return s.repo.ClearCover(eventID)
 }
 
-func (s *EventPhotoService) GetPhotos(eventID int64) ([]models.EventPhoto, error) {
-	return s.repo.GetPhotos(eventID)
+func (s *EventPhotoService) GetPhotoById(photoID int64) (*models.EventPhoto, error) {
+	return s.repo.GetPhotoById(photoID)
 }
 
-func (s *EventPhotoService) DeletEventPhotos(eventId int64, urls []string) error {
-	return s.repo.DeletePhotos(eventId, urls)
+func (s *EventPhotoService) DeletePhotos(eventID int64, photoIDs []int64) error {
+	return s.repo.DeletePhotos(eventID, photoIDs)
+}
+
+func (s *EventPhotoService) DeletePhotosByURL(eventID int64, urls []string) error {
+	return s.repo.DeletePhotosByURL(eventID, urls)
+}
+
+// DeletePhotoFiles deletes the files of photos whose rows were deleted along with their events.
+func (s *EventPhotoService) DeletePhotoFiles(photos []models.EventPhoto) {
+	s.repo.DeleteFiles(photos)
+}
+
+func (s *EventPhotoService) GetAlbums(eventID int64) ([]models.PhotoAlbum, error) {
+	return s.albumRepo.GetAlbums(eventID)
+}
+
+func (s *EventPhotoService) CreateAlbum(eventID int64, request requests.AlbumRequest) (*models.PhotoAlbum, error) {
+	album := &models.PhotoAlbum{
+		EventID:       eventID,
+		Name:          request.Name,
+		DestinationID: request.DestinationID,
+		Position:      request.Position,
+	}
+	if err := s.albumRepo.CreateAlbum(album); err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+func (s *EventPhotoService) UpdateAlbum(eventID, albumID int64, request requests.AlbumRequest) (*models.PhotoAlbum, error) {
+	album := &models.PhotoAlbum{
+		ID:            albumID,
+		EventID:       eventID,
+		Name:          request.Name,
+		DestinationID: request.DestinationID,
+		Position:      request.Position,
+	}
+	if err := s.albumRepo.UpdateAlbum(album); err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+func (s *EventPhotoService) DeleteAlbum(eventID, albumID int64) error {
+	return s.albumRepo.DeleteAlbum(eventID, albumID)
+}
+
+// attends tells whether the user holds a confirmed seat at the event.
+func (s *EventPhotoService) attends(eventID, userID int64) (bool, error) {
+	registration, err := s.registrationRepo.GetRegistration(eventID, userID)
+	if err != nil {
+		return false, err
+	}
+	return registration != nil && (registration.Status == models.Registered || registration.Status == models.PendingCancellation), nil
 }
@@ -1,9 +1,26 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
 	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
 	"github.com/wmfadel/wander-base/internal/models/requests"
 	"github.com/wmfadel/wander-base/internal/repository"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+const (
+	// RecurrenceHorizon is how far ahead occurrences are materialized ahead of time and when GET /events
+	// has no date range, ranges past it are materialized when they are queried
+	RecurrenceHorizon = 90 * 24 * time.Hour
+	// MaxOccurrencesPerSeries caps the occurrences materialized per series at a time
+	MaxOccurrencesPerSeries = 500
+
+	recurrenceRefreshInterval = time.Hour
 )
 
 type EventService struct {
@@ -16,7 +33,66 @@ func NewEventService(repo *repository.EventRepository, photoService *EventPhotoS
 }
 
 func (s *EventService) CreateEvent(event *models.Event) error {
-	return s.repo.Save(event)
+	if err := validateEventWindow(event.DateTime, event.EndDateTime); err != nil {
+		return err
+	}
+
+	// Events start out as drafts and only show up publicly once published
+	event.Status, event.CancellationReason = models.EventDraft, ""
+
+	// Occurrences are only created by expanding a series
+	event.SeriesID, event.OccurrenceAt = nil, nil
+	if event.RRule != "" {
+		rule, exdates, err := normalizeRecurrence(event.RRule, event.ExDates)
+		if err != nil {
+			return err
+		}
+		event.RRule, event.ExDates = rule, exdates
+	} else if event.ExDates != "" {
+		return fmt.Errorf("exdates require an rrule: %w", core.ErrInvalidInput)
+	}
+	timezone, err := normalizeTimezone(event.Timezone)
+	if err != nil {
+		return err
+	}
+	event.Timezone = timezone
+	if err := s.repo.Save(event); err != nil {
+		return err
+	}
+	if event.IsRecurring() {
+		s.materializeSeries(event.ID)
+	}
+	return nil
+}
+
+// StartMaterializer keeps the occurrences of every series materialized up to RecurrenceHorizon ahead
+// until ctx is done, so listing the coming events rarely finds any missing.
+func (s *EventService) StartMaterializer(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(recurrenceRefreshInterval)
+		defer ticker.Stop()
+		for {
+			now := time.Now()
+			if err := s.repo.MaterializeOccurrences(now, now.Add(RecurrenceHorizon), MaxOccurrencesPerSeries); err != nil {
+				log.Printf("Failed to materialize occurrences: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// materializeSeries brings a created or edited series' occurrences up to date right away instead of
+// waiting for the materializer, failures are only logged since the materializer catches up.
+func (s *EventService) materializeSeries(masterID int64) {
+	now := time.Now()
+	if err := s.repo.MaterializeSeries(masterID, now, now.Add(RecurrenceHorizon), MaxOccurrencesPerSeries); err != nil {
+		log.Printf("Warning: failed to materialize occurrences of event %d: %v", masterID, err)
+	}
 }
 
 func (s *EventService) SetDestinations(destinations []models.EventDestinationRequest, eventID int64) error {
@@ -39,14 +115,205 @@ func (s *EventService) GetEventById(eventId int64) (*models.Event, error) {
 	return s.repo.GetEventById(eventId)
 }
 
-func (s *EventService) GetAllEvents() ([]models.Event, error) {
-	return s.repo.GetAllEvents()
+// GetVisibleEvent returns the event only if the viewer, nil when anonymous, may see it.
+func (s *EventService) GetVisibleEvent(eventId int64, viewer *models.User) (*models.Event, error) {
+	event, err := s.repo.GetEventById(eventId)
+	if err != nil || event == nil {
+		return nil, err
+	}
+	if !event.VisibleTo(viewer) {
+		return nil, nil
+	}
+	return event, nil
+}
+
+// GetAllEvents materializes the occurrences of recurring events within the queried date range
+// before listing, so every listed occurrence is a real event that can be registered for.
+// Without a date range the occurrences StartMaterializer keeps up to date are listed.
+func (s *EventService) GetAllEvents(query requests.EventQuery, viewer *models.User) (*models.EventPage, error) {
+	if !query.From.IsZero() || !query.To.IsZero() {
+		from := query.From
+		if from.IsZero() {
+			from = time.Now()
+		}
+		to := query.To
+		if to.IsZero() {
+			to = from.Add(RecurrenceHorizon)
+		}
+		if !to.Before(from) {
+			if err := s.repo.MaterializeOccurrences(from, to, MaxOccurrencesPerSeries); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.repo.GetAllEvents(query, viewer)
+}
+
+// UpdatePartially edits an event. For an occurrence of a recurring event the scope picks
+// whether only this occurrence or this and the following ones change, editing a series master
+// always changes the whole series.
+func (s *EventService) UpdatePartially(event *models.Event, patch requests.PatchEvent, scope string) error {
+	if err := validateScope(scope); err != nil {
+		return err
+	}
+
+	start, end := event.DateTime, event.EndDateTime
+	if patch.DateTime != nil {
+		start = *patch.DateTime
+	}
+	if patch.EndDateTime != nil {
+		end = patch.EndDateTime
+	}
+	if err := validateEventWindow(start, end); err != nil {
+		return err
+	}
+
+	if patch.RRule != nil {
+		rule, err := utils.ParseRRule(*patch.RRule)
+		if err != nil {
+			return fmt.Errorf("%v: %w", err, core.ErrInvalidInput)
+		}
+		normalized := rule.String()
+		patch.RRule = &normalized
+	}
+	if patch.ExDates != nil {
+		exdates, err := utils.ParseExDates(*patch.ExDates)
+		if err != nil {
+			return fmt.Errorf("%v: %w", err, core.ErrInvalidInput)
+		}
+		normalized := utils.FormatExDates(exdates)
+		patch.ExDates = &normalized
+	}
+	if patch.Timezone != nil {
+		normalized, err := normalizeTimezone(*patch.Timezone)
+		if err != nil {
+			return err
+		}
+		patch.Timezone = &normalized
+	}
+
+	switch {
+	case event.IsRecurring():
+		return s.updateSeries(event.ID, event.DateTime, patch)
+	case event.IsOccurrence() && scope == requests.ScopeFollowing:
+		return s.updateSeries(*event.SeriesID, *event.OccurrenceAt, patch)
+	case patch.ChangesRecurrence():
+		return fmt.Errorf("recurrence can only be changed on a series: %w", core.ErrInvalidInput)
+	}
+	return s.repo.UpdatePartially(event.ID, patch)
+}
+
+// updateSeries edits the series from its occurrence starting at from on and materializes the occurrences
+// the edit brought within the horizon.
+func (s *EventService) updateSeries(masterID int64, from time.Time, patch requests.PatchEvent) error {
+	master, err := s.repo.UpdateSeries(masterID, from, patch)
+	if err != nil {
+		return err
+	}
+	s.materializeSeries(master.ID)
+	return nil
+}
+
+// Delete removes an event. Deleting a single occurrence excludes it from its series,
+// deleting it with the following scope ends the series there.
+func (s *EventService) Delete(event *models.Event, scope string) error {
+	if err := validateScope(scope); err != nil {
+		return err
+	}
+
+	if event.IsOccurrence() {
+		if scope == requests.ScopeFollowing {
+			photos, err := s.repo.EndSeries(*event.SeriesID, *event.OccurrenceAt)
+			if err != nil {
+				return err
+			}
+			s.photoService.DeletePhotoFiles(photos)
+			return nil
+		}
+		photos, err := s.repo.DeleteOccurrence(event)
+		if err != nil {
+			return err
+		}
+		s.photoService.DeletePhotoFiles(photos)
+		return nil
+	}
+
+	if event.IsRecurring() {
+		// Occurrences are deleted along with their master
+		photos, err := s.repo.EndSeries(event.ID, event.DateTime)
+		if err != nil {
+			return err
+		}
+		s.photoService.DeletePhotoFiles(photos)
+		return nil
+	}
+
+	// The files only go once the rows are, a failed delete leaves the event with its photos
+	photos, err := s.repo.Delete(event.ID)
+	if err != nil {
+		return err
+	}
+	s.photoService.DeletePhotoFiles(photos)
+	return nil
+}
+
+func (s *EventService) Publish(eventId int64) (*models.Event, error) {
+	return s.repo.TransitionStatus(eventId, models.EventPublished, models.RegistrationDecision{})
 }
-func (s *EventService) UpdatePartially(eventId int64, patch requests.PatchEvent) error {
-	return s.repo.UpdatePartially(eventId, patch)
+
+func (s *EventService) Unpublish(eventId int64) (*models.Event, error) {
+	return s.repo.TransitionStatus(eventId, models.EventDraft, models.RegistrationDecision{})
+}
+
+// Cancel cancels the event and all of its registrations, recording who cancelled it and why.
+func (s *EventService) Cancel(eventId int64, decision models.RegistrationDecision) (*models.Event, error) {
+	return s.repo.TransitionStatus(eventId, models.EventCancelled, decision)
+}
+
+func (s *EventService) Complete(eventId int64) (*models.Event, error) {
+	return s.repo.TransitionStatus(eventId, models.EventCompleted, models.RegistrationDecision{})
+}
+
+func (s *EventService) AddCoHost(eventId int64, userId int64) error {
+	return s.repo.AddCoHost(eventId, userId)
+}
+
+func (s *EventService) RemoveCoHost(eventId int64, userId int64) error {
+	return s.repo.RemoveCoHost(eventId, userId)
+}
+
+func validateEventWindow(start time.Time, end *time.Time) error {
+	if end != nil && !end.After(start) {
+		return fmt.Errorf("event must end after it starts: %w", core.ErrInvalidInput)
+	}
+	return nil
+}
+
+func validateScope(scope string) error {
+	if scope != "" && scope != requests.ScopeThis && scope != requests.ScopeFollowing {
+		return fmt.Errorf("unknown scope %q: %w", scope, core.ErrInvalidInput)
+	}
+	return nil
+}
+
+// normalizeTimezone checks the IANA zone a series repeats in, events without one repeat in UTC.
+func normalizeTimezone(timezone string) (string, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil || location == time.Local {
+		return "", fmt.Errorf("unknown timezone %q: %w", timezone, core.ErrInvalidInput)
+	}
+	return location.String(), nil
 }
 
-func (s *EventService) Delete(eventId int64, photos []string) error {
-	go s.photoService.DeletEventPhotos(eventId, photos)
-	return s.repo.Delete(eventId)
+// normalizeRecurrence validates a series' rule and exclusions and returns them in canonical form.
+func normalizeRecurrence(rrule, exdates string) (string, string, error) {
+	rule, err := utils.ParseRRule(rrule)
+	if err != nil {
+		return "", "", fmt.Errorf("%v: %w", err, core.ErrInvalidInput)
+	}
+	excluded, err := utils.ParseExDates(exdates)
+	if err != nil {
+		return "", "", fmt.Errorf("%v: %w", err, core.ErrInvalidInput)
+	}
+	return rule.String(), utils.FormatExDates(excluded), nil
 }
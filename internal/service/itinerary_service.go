@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
+	"github.com/wmfadel/wander-base/internal/repository"
+)
+
+type ItineraryService struct {
+	repo *repository.ItineraryRepository
+}
+
+func NewItineraryService(repo *repository.ItineraryRepository) *ItineraryService {
+	return &ItineraryService{repo: repo}
+}
+
+func (s *ItineraryService) GetItinerary(event *models.Event) (*models.Itinerary, error) {
+	stops, err := s.repo.GetStops(event.ID)
+	if err != nil {
+		return nil, err
+	}
+	return buildItinerary(event, stops), nil
+}
+
+// SetItinerary validates and replaces the event's itinerary. Stops are ordered by arrival time,
+// must not overlap and must fall within the event's start and end.
+func (s *ItineraryService) SetItinerary(event *models.Event, request requests.ItineraryRequest) (*models.Itinerary, error) {
+	stopRequests := append([]requests.ItineraryStopRequest{}, request.Stops...)
+	sort.SliceStable(stopRequests, func(i, j int) bool {
+		return stopRequests[i].ArrivalAt.Before(stopRequests[j].ArrivalAt)
+	})
+
+	if err := validateStops(event, stopRequests); err != nil {
+		return nil, fmt.Errorf("invalid itinerary: %w", err)
+	}
+
+	stops := make([]models.ItineraryStop, 0, len(stopRequests))
+	for i, stopRequest := range stopRequests {
+		stop := models.ItineraryStop{
+			EventID:       event.ID,
+			DestinationID: stopRequest.DestinationID,
+			Position:      i + 1,
+			ArrivalAt:     stopRequest.ArrivalAt,
+			DepartureAt:   stopRequest.DepartureAt,
+			Notes:         stopRequest.Notes,
+		}
+		for _, activityID := range stopRequest.ActivityIDs {
+			stop.Activities = append(stop.Activities, models.Activity{ID: activityID})
+		}
+		stops = append(stops, stop)
+	}
+
+	if err := s.repo.ReplaceStops(event.ID, stops); err != nil {
+		return nil, err
+	}
+	return s.GetItinerary(event)
+}
+
+// validateStops expects the stops sorted by arrival time.
+func validateStops(event *models.Event, stops []requests.ItineraryStopRequest) error {
+	for i, stop := range stops {
+		if !stop.DepartureAt.After(stop.ArrivalAt) {
+			return fmt.Errorf("stop at destination %d departs before it arrives: %w", stop.DestinationID, core.ErrInvalidInput)
+		}
+		if !event.Contains(stop.ArrivalAt) || !event.Contains(stop.DepartureAt) {
+			return fmt.Errorf("stop at destination %d is outside the event's time window: %w", stop.DestinationID, core.ErrInvalidInput)
+		}
+		if i > 0 && stop.ArrivalAt.Before(stops[i-1].DepartureAt) {
+			return fmt.Errorf("stop at destination %d overlaps the stop at destination %d: %w",
+				stop.DestinationID, stops[i-1].DestinationID, core.ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+// buildItinerary groups the ordered stops by the UTC day they arrive on.
+func buildItinerary(event *models.Event, stops []models.ItineraryStop) *models.Itinerary {
+	itinerary := &models.Itinerary{
+		EventID:  event.ID,
+		StartsAt: event.DateTime,
+		EndsAt:   event.EndDateTime,
+		Days:     []models.ItineraryDay{},
+	}
+
+	for _, stop := range stops {
+		date := stop.ArrivalAt.UTC().Format("2006-01-02")
+		last := len(itinerary.Days) - 1
+		if last < 0 || itinerary.Days[last].Date != date {
+			itinerary.Days = append(itinerary.Days, models.ItineraryDay{Date: date})
+			last++
+		}
+		itinerary.Days[last].Stops = append(itinerary.Days[last].Stops, stop)
+	}
+	return itinerary
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
+	"github.com/wmfadel/wander-base/internal/repository"
+)
+
+// LoginPolicy throttles failed logins: after DelayAfter failures every further attempt has to wait,
+// twice as long each time up to MaxDelay, and LockAfter failures lock the key out for LockFor.
+type LoginPolicy struct {
+	DelayAfter int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	LockAfter  int
+	LockFor    time.Duration
+	Window     time.Duration // failures older than this are forgotten
+}
+
+var (
+	// AccountLoginPolicy applies per phone, signed up or not.
+	AccountLoginPolicy = LoginPolicy{DelayAfter: 3, BaseDelay: time.Second, MaxDelay: time.Minute, LockAfter: 10, LockFor: 30 * time.Minute, Window: time.Hour}
+	// IPLoginPolicy applies per client address, it is looser since many users can share an address.
+	IPLoginPolicy = LoginPolicy{DelayAfter: 20, BaseDelay: time.Second, MaxDelay: time.Minute, LockAfter: 100, LockFor: time.Hour, Window: time.Hour}
+)
+
+// wait returns how long the throttle has to wait before its next attempt.
+func (p LoginPolicy) wait(throttle models.LoginThrottle, now time.Time) time.Duration {
+	if throttle.Locked(now) {
+		return throttle.LockedUntil.Sub(now)
+	}
+	if throttle.Failures < p.DelayAfter || now.Sub(throttle.LastFailureAt) > p.Window {
+		return 0
+	}
+	delay := p.MaxDelay
+	if shift := throttle.Failures - p.DelayAfter; shift < 16 {
+		delay = min(p.BaseDelay<<shift, p.MaxDelay)
+	}
+	return max(throttle.LastFailureAt.Add(delay).Sub(now), 0)
+}
+
+// ThrottledError rejects a login attempt without checking it, the same way for every phone.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many failed logins, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return core.ErrRateLimited
+}
+
+// LockoutsLimit caps how many lockouts and security events the admins get at once.
+const LockoutsLimit = 100
+
+type LoginGuardService struct {
+	repo      *repository.LoginThrottleRepository
+	events    *repository.SecurityEventRepository
+	userRepo  *repository.UserRepository
+	twoFactor *TwoFactorService
+}
+
+func NewLoginGuardService(repo *repository.LoginThrottleRepository, events *repository.SecurityEventRepository, userRepo *repository.UserRepository, twoFactor *TwoFactorService) *LoginGuardService {
+	return &LoginGuardService{repo: repo, events: events, userRepo: userRepo, twoFactor: twoFactor}
+}
+
+// Login checks the credentials unless the phone or the client's address are throttled.
+// Failures are counted for both, a successful login only clears the phone's count.
+// Users with two-factor enabled get a challenge to complete with LoginTwoFactor, nil means the login is done.
+func (s *LoginGuardService) Login(loginRequest *requests.LoginRequest, ip string) (*models.TwoFactorChallenge, error) {
+	phoneKey := models.PhoneThrottleKey(loginRequest.Phone)
+	err := s.Guard(loginRequest.Phone, ip, func() error {
+		return s.userRepo.ValidateCredintials(loginRequest)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := s.twoFactor.Enabled(loginRequest.ID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		// The failures are kept until the second factor passed too, so wrong codes keep adding up
+		return s.twoFactor.StartChallenge(loginRequest.ID)
+	}
+	s.clear(phoneKey)
+	return nil, nil
+}
+
+// LoginTwoFactor completes a login with the challenge and a TOTP or recovery code, returning the user's ID
+// and phone. Wrong codes count as failed logins of the user's phone.
+func (s *LoginGuardService) LoginTwoFactor(challengeToken, code, ip string) (int64, string, error) {
+	challenge, err := s.twoFactor.GetChallenge(challengeToken)
+	if err != nil {
+		return 0, "", err
+	}
+	phone, err := s.userRepo.GetPhone(challenge.UserID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	err = s.Guard(phone, ip, func() error {
+		return s.twoFactor.CompleteChallenge(challenge, code)
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	s.clear(models.PhoneThrottleKey(phone))
+	return challenge.UserID, phone, nil
+}
+
+// Guard runs the check unless the phone or the address are throttled, counting wrong credentials against both.
+// The attempt is counted before the check and taken back if it passes, so a burst of concurrent attempts
+// can't all get past the delay while the first ones are still being checked.
+func (s *LoginGuardService) Guard(phone, ip string, check func() error) error {
+	keys := []struct {
+		key    string
+		policy LoginPolicy
+		phone  string // to attribute lockouts to
+	}{
+		{models.PhoneThrottleKey(phone), AccountLoginPolicy, phone},
+		{models.IPThrottleKey(ip), IPLoginPolicy, ""},
+	}
+
+	reserved := make([]*models.LoginThrottle, 0, len(keys))
+	for _, key := range keys {
+		throttle, err := s.repo.Reserve(key.key, key.policy.Window, func(throttle models.LoginThrottle) error {
+			if wait := key.policy.wait(throttle, time.Now()); wait > 0 {
+				return &ThrottledError{RetryAfter: wait}
+			}
+			return nil
+		})
+		if err != nil {
+			for _, throttle := range reserved {
+				s.release(throttle.Key)
+			}
+			return err
+		}
+		reserved = append(reserved, throttle)
+	}
+
+	err := check()
+	if errors.Is(err, core.ErrInvalidCredentials) || errors.Is(err, ErrInvalidTwoFactorCode) {
+		for i, key := range keys {
+			s.lockIfExceeded(reserved[i], key.policy, key.phone, ip)
+		}
+		return err
+	}
+	for _, throttle := range reserved {
+		s.release(throttle.Key)
+	}
+	return err
+}
+
+func (s *LoginGuardService) clear(key string) {
+	if _, err := s.repo.Clear(key); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+func (s *LoginGuardService) release(key string) {
+	if err := s.repo.Release(key); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// lockIfExceeded locks the key out once the failure counted for it makes too many, logging the lockout.
+// Failing to lock is only logged, the login fails either way.
+func (s *LoginGuardService) lockIfExceeded(throttle *models.LoginThrottle, policy LoginPolicy, phone, ip string) {
+	if throttle.Failures < policy.LockAfter {
+		return
+	}
+
+	key := throttle.Key
+	until := time.Now().Add(policy.LockFor)
+	locked, err := s.repo.Lock(key, policy.LockAfter, until)
+	if err != nil || !locked {
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return
+	}
+
+	event := &models.SecurityEvent{
+		Type:    models.EventLockout,
+		Subject: key,
+		IP:      ip,
+		Details: fmt.Sprintf("%d failed logins, locked until %s", throttle.Failures, until.Format(time.RFC3339)),
+	}
+	if phone != "" {
+		user, err := s.userRepo.GetUserByPhone(phone)
+		if err == nil && user != nil {
+			event.UserID = &user.ID
+		}
+	}
+	if err := s.events.Create(event); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// GetLockouts lists the phones and addresses locked out right now.
+func (s *LoginGuardService) GetLockouts() ([]models.LoginThrottle, error) {
+	return s.repo.GetLocked()
+}
+
+// UnlockUser lifts the lockout and forgets the failed logins of the user's phone.
+func (s *LoginGuardService) UnlockUser(userID int64, admin *models.User) error {
+	phone, err := s.userRepo.GetPhone(userID)
+	if err != nil {
+		return err
+	}
+	return s.unlock(models.PhoneThrottleKey(phone), &userID, admin)
+}
+
+// Unlock lifts the lockout of a throttle key, e.g. "ip:203.0.113.7".
+func (s *LoginGuardService) Unlock(key string, admin *models.User) error {
+	return s.unlock(key, nil, admin)
+}
+
+func (s *LoginGuardService) unlock(key string, userID *int64, admin *models.User) error {
+	cleared, err := s.repo.Clear(key)
+	if err != nil {
+		return err
+	}
+	if !cleared {
+		return fmt.Errorf("no failed logins for %s: %w", key, core.ErrNotFound)
+	}
+	return s.events.Create(&models.SecurityEvent{
+		Type:    models.EventUnlock,
+		Subject: key,
+		UserID:  userID,
+		ActorID: &admin.ID,
+	})
+}
+
+// GetSecurityEvents returns the latest events, only the user's when userID isn't 0.
+func (s *LoginGuardService) GetSecurityEvents(userID int64) ([]models.SecurityEvent, error) {
+	return s.events.GetEvents(userID, LockoutsLimit)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/repository"
+)
+
+const (
+	ModerationWorkers     = 4
+	ModerationMaxAttempts = 5 // failed attempts before a comment is left to the admins
+
+	moderationRetryBase    = 30 * time.Second
+	moderationRetryMax     = 30 * time.Minute
+	moderationPollInterval = 15 * time.Second
+	moderationLease        = 2 * time.Minute // a claimed comment is picked up again after this if its worker died
+	moderationTimeout      = 30 * time.Second
+)
+
+// ModerationQueue moderates pending comments in the background with a pool of workers.
+// New comments are handed over right away, a poller picks up retries, comments the queue had
+// no room for and comments left pending by a restart, so the pending rows are the real queue.
+type ModerationQueue struct {
+	repo       *repository.CommentRepository
+	moderation *ModerationService
+	jobs       chan int64
+}
+
+func NewModerationQueue(repo *repository.CommentRepository, moderation *ModerationService) *ModerationQueue {
+	return &ModerationQueue{repo: repo, moderation: moderation, jobs: make(chan int64, 256)}
+}
+
+// Start runs the workers and the poller until ctx is done.
+func (q *ModerationQueue) Start(ctx context.Context, workers int) {
+	for i := 0; i < workers; i++ {
+		go q.work(ctx)
+	}
+	go q.poll(ctx)
+}
+
+// Enqueue hands a pending comment to the workers without blocking, the poller catches it if the queue is full.
+func (q *ModerationQueue) Enqueue(commentId int64) {
+	select {
+	case q.jobs <- commentId:
+	default:
+	}
+}
+
+func (q *ModerationQueue) poll(ctx context.Context) {
+	ticker := time.NewTicker(moderationPollInterval)
+	defer ticker.Stop()
+	for {
+		ids, err := q.repo.DueForModeration(ModerationMaxAttempts, cap(q.jobs))
+		if err != nil {
+			log.Printf("Failed to get comments due for moderation: %v", err)
+		}
+		for _, id := range ids {
+			q.Enqueue(id)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *ModerationQueue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-q.jobs:
+			q.moderate(ctx, id)
+		}
+	}
+}
+
+func (q *ModerationQueue) moderate(ctx context.Context, commentId int64) {
+	comment, err := q.repo.ClaimForModeration(commentId, ModerationMaxAttempts, moderationLease)
+	if err != nil {
+		log.Printf("Failed to claim comment %d for moderation: %v", commentId, err)
+		return
+	}
+	if comment == nil {
+		return // Already moderated, overridden by an admin or claimed by another worker
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, moderationTimeout)
+	defer cancel()
+	if err := q.moderation.AuditComment(ctx, comment); err != nil {
+		attempts := comment.ModerationAttempts + 1
+		var next *time.Time
+		if attempts < ModerationMaxAttempts {
+			retryAt := time.Now().Add(moderationBackoff(attempts))
+			next = &retryAt
+		}
+		log.Printf("Moderation attempt %d of comment %d failed: %v", attempts, commentId, err)
+		if err := q.repo.RecordModerationFailure(commentId, attempts, next, err.Error()); err != nil {
+			log.Printf("Failed to record moderation failure: %v", err)
+		}
+		return
+	}
+
+	if err := q.repo.SaveModeration(comment); err != nil {
+		log.Printf("Failed to save moderation: %v", err)
+	}
+}
+
+// moderationBackoff doubles the delay with every failed attempt, with some jitter so
+// comments that failed together during a provider outage don't all retry at once.
+func moderationBackoff(attempts int) time.Duration {
+	delay := moderationRetryBase << (attempts - 1)
+	if delay > moderationRetryMax || delay <= 0 {
+		delay = moderationRetryMax
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
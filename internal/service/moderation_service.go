@@ -3,73 +3,36 @@ package service
 import (
 	"context"
 	"fmt"
-	"log"
 
-	"github.com/sashabaranov/go-openai"
 	"github.com/wmfadel/wander-base/internal/models"
 )
 
 type ModerationService struct {
-	openaiClient *openai.Client
-	threshold    float32 // Threshold for hiding comments (e.g., 0.7)
+	moderator Moderator
+	threshold float32 // Threshold for hiding comments (e.g., 0.7)
 }
 
-// NewAuditService creates a new instance of AuditService
-func NewAuditService(openaiClient *openai.Client, threshold float32) *ModerationService {
+// NewModerationService creates a new instance of ModerationService
+func NewModerationService(moderator Moderator, threshold float32) *ModerationService {
 	return &ModerationService{
-		openaiClient: openaiClient,
-		threshold:    threshold,
+		moderator: moderator,
+		threshold: threshold,
 	}
 }
 
-func (s *ModerationService) AuditComment(comment *models.Comment) error {
-	// Call OpenAI's moderation API
-	resp, err := s.openaiClient.Moderations(context.Background(), openai.ModerationRequest{
-		Input: comment.Content,
-		Model: openai.ModerationTextStable,
-	})
-	log.Println(resp)
+// AuditComment scores the comment and hides it if any category scores above the threshold.
+func (s *ModerationService) AuditComment(ctx context.Context, comment *models.Comment) error {
+	scores, err := s.moderator.Moderate(ctx, comment.Content)
 	if err != nil {
-		return fmt.Errorf("failed to call OpenAI moderation API: %w", err)
+		return fmt.Errorf("failed to moderate comment: %w", err)
 	}
 
-	if len(resp.Results) == 0 {
-		return fmt.Errorf("no moderation results returned")
+	comment.Scores = scores
+	comment.Score = scores.Max()
+	comment.Visible = comment.Score <= s.threshold
+	comment.Status = models.CommentHidden
+	if comment.Visible {
+		comment.Status = models.CommentVisible
 	}
-
-	result := resp.Results[0]
-
-	// List all category scores
-	scores := []float32{
-		result.CategoryScores.Hate,
-		result.CategoryScores.HateThreatening,
-		result.CategoryScores.SelfHarm,
-		result.CategoryScores.Sexual,
-		result.CategoryScores.SexualMinors,
-		result.CategoryScores.Violence,
-		result.CategoryScores.ViolenceGraphic,
-	}
-
-	// Find the maximum score
-	maxScore := float32(0.0)
-	for _, score := range scores {
-		if score > maxScore {
-			maxScore = score
-		}
-	}
-	comment.Score = maxScore
-
-	// Determine visibility based on threshold
-	comment.Visible = true
-	if result.CategoryScores.Hate > s.threshold ||
-		result.CategoryScores.HateThreatening > s.threshold ||
-		result.CategoryScores.SelfHarm > s.threshold ||
-		result.CategoryScores.Sexual > s.threshold ||
-		result.CategoryScores.SexualMinors > s.threshold ||
-		result.CategoryScores.Violence > s.threshold ||
-		result.CategoryScores.ViolenceGraphic > s.threshold {
-		comment.Visible = false
-	}
-
 	return nil
 }
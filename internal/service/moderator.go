@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+// Moderator scores text per category, from 0 for harmless to 1 for certainly harmful.
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (models.ModerationScores, error)
+}
+
+// NoopModerator lets everything through, for development and deployments without moderation.
+type NoopModerator struct{}
+
+func (NoopModerator) Moderate(ctx context.Context, text string) (models.ModerationScores, error) {
+	return models.ModerationScores{}, nil
+}
+
+// ChainModerator asks every moderator in turn and keeps the highest score of each category,
+// so the stored scores always hold what every provider said. A failing moderator fails the chain,
+// the comment stays pending and is retried with all of them.
+type ChainModerator struct {
+	Moderators []Moderator
+}
+
+func (m ChainModerator) Moderate(ctx context.Context, text string) (models.ModerationScores, error) {
+	scores := models.ModerationScores{}
+	for _, moderator := range m.Moderators {
+		result, err := moderator.Moderate(ctx, text)
+		if err != nil {
+			return nil, fmt.Errorf("moderator %T failed: %w", moderator, err)
+		}
+		scores.Merge(result)
+	}
+	return scores, nil
+}
+
+// NewModeratorFromEnv picks the moderators from MODERATION_PROVIDERS, a comma separated list of
+// "rules" (default), "openai" and "none". Several providers are chained in the given order.
+// The rule-based moderator reads its word lists and patterns from MODERATION_RULES_FILE if set.
+func NewModeratorFromEnv(openaiClient *openai.Client) (Moderator, error) {
+	providers := strings.Split(utils.GetFromEnvOr("MODERATION_PROVIDERS", "rules"), ",")
+	moderators := make([]Moderator, 0, len(providers))
+	for _, provider := range providers {
+		switch provider = strings.TrimSpace(provider); provider {
+		case "rules":
+			config, err := ruleModeratorConfigFromEnv()
+			if err != nil {
+				return nil, err
+			}
+			moderator, err := NewRuleModerator(config)
+			if err != nil {
+				return nil, err
+			}
+			moderators = append(moderators, moderator)
+		case "openai":
+			moderators = append(moderators, NewOpenAIModerator(openaiClient))
+		case "none":
+			moderators = append(moderators, NoopModerator{})
+		default:
+			return nil, fmt.Errorf("unknown moderation provider %q", provider)
+		}
+	}
+
+	if len(moderators) == 1 {
+		return moderators[0], nil
+	}
+	return ChainModerator{Moderators: moderators}, nil
+}
+
+// ModerationThresholdFromEnv reads MODERATION_THRESHOLD, comments scoring above it are hidden.
+func ModerationThresholdFromEnv() (float32, error) {
+	value := utils.GetFromEnvOr("MODERATION_THRESHOLD", "0.7")
+	threshold, err := strconv.ParseFloat(value, 32)
+	if err != nil || threshold <= 0 || threshold > 1 {
+		return 0, fmt.Errorf("MODERATION_THRESHOLD has to be a number in (0, 1], got %q", value)
+	}
+	return float32(threshold), nil
+}
+
+func ruleModeratorConfigFromEnv() (RuleModeratorConfig, error) {
+	config := DefaultRuleModeratorConfig()
+	path := utils.GetFromEnv("MODERATION_RULES_FILE")
+	if path == "" {
+		return config, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read moderation rules: %w", err)
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return config, fmt.Errorf("failed to parse moderation rules %s: %w", path, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/wmfadel/wander-base/internal/models"
+)
+
+// OpenAIModerator scores text with OpenAI's moderation API, its scores use OpenAI's category names.
+type OpenAIModerator struct {
+	client *openai.Client
+}
+
+func NewOpenAIModerator(client *openai.Client) *OpenAIModerator {
+	return &OpenAIModerator{client: client}
+}
+
+func (m *OpenAIModerator) Moderate(ctx context.Context, text string) (models.ModerationScores, error) {
+	// Call OpenAI's moderation API
+	resp, err := m.client.Moderations(ctx, openai.ModerationRequest{
+		Input: text,
+		Model: openai.ModerationTextStable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call OpenAI moderation API: %w", err)
+	}
+	if len(resp.Results) == 0 {
+		return nil, fmt.Errorf("no moderation results returned")
+	}
+
+	scores := resp.Results[0].CategoryScores
+	return models.ModerationScores{
+		"hate":                   scores.Hate,
+		"hate/threatening":       scores.HateThreatening,
+		"harassment":             scores.Harassment,
+		"harassment/threatening": scores.HarassmentThreatening,
+		"self-harm":              scores.SelfHarm,
+		"self-harm/intent":       scores.SelfHarmIntent,
+		"self-harm/instructions": scores.SelfHarmInstructions,
+		"sexual":                 scores.Sexual,
+		"sexual/minors":          scores.SexualMinors,
+		"violence":               scores.Violence,
+		"violence/graphic":       scores.ViolenceGraphic,
+	}, nil
+}
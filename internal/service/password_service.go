@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/repository"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+type PasswordService struct {
+	userRepo      *repository.UserRepository
+	tokens        *TokenService
+	verifications *VerificationService
+}
+
+func NewPasswordService(userRepo *repository.UserRepository, tokens *TokenService, verifications *VerificationService) *PasswordService {
+	return &PasswordService{userRepo: userRepo, tokens: tokens, verifications: verifications}
+}
+
+// Change sets a new password once the current one is confirmed.
+// The user's other sessions are logged out, the one changing the password stays logged in.
+func (s *PasswordService) Change(user *models.User, current, next, sessionID string) error {
+	if !utils.CheckPasswordHash(current, user.Password) {
+		return fmt.Errorf("wrong current password: %w", core.ErrForbidden)
+	}
+	if current == next {
+		return fmt.Errorf("the new password has to differ from the current one: %w", core.ErrInvalidInput)
+	}
+
+	if err := s.userRepo.UpdatePassword(user.ID, next); err != nil {
+		return err
+	}
+	return s.tokens.RevokeOtherSessions(user.ID, sessionID)
+}
+
+// RequestReset sends a reset code to the phone if a user has it.
+// Unknown phones are not reported, so the endpoint can't be used to find out who is signed up.
+func (s *PasswordService) RequestReset(phone string) error {
+	user, err := s.userRepo.GetUserByPhone(phone)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		log.Printf("password reset requested for unknown phone %s", phone)
+		return nil
+	}
+	return s.verifications.SendPasswordResetCode(user)
+}
+
+// Reset sets a new password with a code sent by RequestReset and ends all of the user's sessions,
+// access tokens issued before the reset are rejected from then on.
+func (s *PasswordService) Reset(phone, code, password string) error {
+	user, err := s.userRepo.GetUserByPhone(phone)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("no code to confirm, request a new one: %w", core.ErrNotFound)
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash user password %w", err)
+	}
+	err = s.verifications.ConfirmPasswordReset(user, code, map[string]interface{}{
+		"password":          hashedPassword,
+		"sessions_reset_at": time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Refresh tokens issued before the reset are rejected anyway, revoking them is only cleanup
+	if err := s.tokens.RevokeOtherSessions(user.ID, ""); err != nil {
+		log.Printf("Warning: failed to revoke the sessions of user %d: %v", user.ID, err)
+	}
+	return nil
+}
@@ -1,7 +1,10 @@
 package service
 
 import (
+	"fmt"
+
 	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
 	"github.com/wmfadel/wander-base/internal/repository"
 )
 
@@ -13,22 +16,41 @@ func NewRegistrationService(repo *repository.RegistrationRepository) *Registrati
 	return &RegistrationService{repo: repo}
 }
 
-func (s *RegistrationService) Register(userId int64, eventId int64) error {
+func (s *RegistrationService) Register(userId int64, eventId int64) (models.RegistrationStatus, error) {
 	return s.repo.Register(userId, eventId)
 }
 
-func (s *RegistrationService) ApproveRegistration(userId int64, eventId int64) error {
-	return s.repo.ApproveRegistration(userId, eventId)
+func (s *RegistrationService) ApproveRegistration(eventId int64, userIds []int64, decision models.RegistrationDecision) error {
+	return s.decide(eventId, userIds, models.PendingRegistration, models.Registered, decision)
+}
+
+func (s *RegistrationService) RejectRegistration(eventId int64, userIds []int64, decision models.RegistrationDecision) error {
+	return s.decide(eventId, userIds, models.PendingRegistration, models.Rejected, decision)
 }
 
 func (s *RegistrationService) CancelRegister(userId int64, eventId int64) error {
-	return s.repo.Register(userId, eventId)
+	return s.repo.CancelRegister(userId, eventId)
+}
+
+func (s *RegistrationService) ApproveCancelRegister(eventId int64, userIds []int64, decision models.RegistrationDecision) error {
+	return s.decide(eventId, userIds, models.PendingCancellation, models.Cancelled, decision)
+}
+
+// RejectCancelRegister keeps the users registered.
+func (s *RegistrationService) RejectCancelRegister(eventId int64, userIds []int64, decision models.RegistrationDecision) error {
+	return s.decide(eventId, userIds, models.PendingCancellation, models.Registered, decision)
 }
 
-func (s *RegistrationService) ApproveCancelRegister(userId int64, eventId int64) error {
-	return s.repo.ApproveCancelRegister(userId, eventId)
+func (s *RegistrationService) GetRegistrations(eventID int64, status models.RegistrationStatus) ([]models.Registration, error) {
+	if status != "" && !status.Valid() {
+		return nil, fmt.Errorf("unknown registration status %q: %w", status, core.ErrInvalidInput)
+	}
+	return s.repo.GetRegistrations(eventID, status)
 }
 
-func (s *RegistrationService) GetUsersWithStatus(eventID int64, status models.RegistrationStatus) ([]models.User, error) {
-	return s.repo.GetUsersWithStatus(eventID, status)
+func (s *RegistrationService) decide(eventId int64, userIds []int64, from, to models.RegistrationStatus, decision models.RegistrationDecision) error {
+	if len(userIds) == 0 {
+		return fmt.Errorf("no users provided: %w", core.ErrInvalidInput)
+	}
+	return s.repo.UpdateStatuses(eventId, userIds, from, to, decision)
 }
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/models/requests"
+	"github.com/wmfadel/wander-base/internal/repository"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+// ReportQueueLimit caps how many reported items and log entries the admins get at once.
+const ReportQueueLimit = 100
+
+type ReportService struct {
+	repo      *repository.ReportRepository
+	comments  *CommentService
+	photos    *EventPhotoService
+	events    *EventService
+	users     *UserService
+	roles     *RoleService
+	threshold int // open reports that hide a comment or photo
+}
+
+func NewReportService(repo *repository.ReportRepository, comments *CommentService, photos *EventPhotoService, events *EventService, users *UserService, roles *RoleService, threshold int) *ReportService {
+	return &ReportService{
+		repo:      repo,
+		comments:  comments,
+		photos:    photos,
+		events:    events,
+		users:     users,
+		roles:     roles,
+		threshold: threshold,
+	}
+}
+
+// ReportThresholdFromEnv reads how many open reports hide a comment or photo, from REPORT_HIDE_THRESHOLD.
+func ReportThresholdFromEnv() (int, error) {
+	value := utils.GetFromEnvOr("REPORT_HIDE_THRESHOLD", "3")
+	threshold, err := strconv.Atoi(value)
+	if err != nil || threshold < 1 {
+		return 0, fmt.Errorf("REPORT_HIDE_THRESHOLD has to be a positive number, got %q", value)
+	}
+	return threshold, nil
+}
+
+// Report files the reporter's report, only content the reporter can see may be reported and never their own.
+// It returns whether the report got the target hidden.
+func (s *ReportService) Report(reporter *models.User, request requests.ReportRequest) (*models.Report, bool, error) {
+	if !request.TargetType.Valid() {
+		return nil, false, fmt.Errorf("unknown report target %q: %w", request.TargetType, core.ErrInvalidInput)
+	}
+	if !request.Reason.Valid() {
+		return nil, false, fmt.Errorf("unknown report reason %q: %w", request.Reason, core.ErrInvalidInput)
+	}
+
+	authorID, err := s.visibleAuthor(request.TargetType, request.TargetID, reporter)
+	if err != nil {
+		return nil, false, err
+	}
+	if authorID != nil && *authorID == reporter.ID {
+		return nil, false, fmt.Errorf("you can't report yourself: %w", core.ErrInvalidInput)
+	}
+
+	report := &models.Report{
+		ReporterID: reporter.ID,
+		TargetType: request.TargetType,
+		TargetID:   request.TargetID,
+		Reason:     request.Reason,
+		Details:    request.Details,
+		Status:     models.ReportOpen,
+	}
+	hidden, err := s.repo.Create(report, s.threshold)
+	if err != nil {
+		return nil, false, err
+	}
+	return report, hidden, nil
+}
+
+// visibleAuthor returns who is responsible for the target, if the viewer may see it.
+func (s *ReportService) visibleAuthor(targetType models.ReportTarget, targetID int64, viewer *models.User) (*int64, error) {
+	switch targetType {
+	case models.ReportComment:
+		comment, err := s.comments.GetCommentById(targetID, viewer)
+		if err != nil {
+			return nil, err
+		}
+		if comment.IsDeleted() {
+			return nil, fmt.Errorf("comment %d is deleted: %w", targetID, core.ErrConflict)
+		}
+		return &comment.UserID, nil
+	case models.ReportPhoto:
+		photo, err := s.photos.GetPhotoById(targetID)
+		if err != nil {
+			return nil, err
+		}
+		if photo == nil || photo.Status != models.PhotoApproved {
+			return nil, fmt.Errorf("photo %d: %w", targetID, core.ErrNotFound)
+		}
+		event, err := s.events.GetVisibleEvent(photo.EventID, viewer)
+		if err != nil {
+			return nil, err
+		}
+		if event == nil {
+			return nil, fmt.Errorf("photo %d: %w", targetID, core.ErrNotFound)
+		}
+		return photo.UploadedByID, nil
+	default:
+		user, err := s.users.GetUserSummary(targetID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("user %d: %w", targetID, core.ErrNotFound)
+		}
+		return &user.ID, nil
+	}
+}
+
+func (s *ReportService) GetQueue(targetType models.ReportTarget) ([]models.ReportedItem, error) {
+	if targetType != "" && !targetType.Valid() {
+		return nil, fmt.Errorf("unknown report target %q: %w", targetType, core.ErrInvalidInput)
+	}
+	return s.repo.GetQueue(targetType, ReportQueueLimit)
+}
+
+func (s *ReportService) GetDetails(targetType models.ReportTarget, targetID int64) (*models.ReportDetails, error) {
+	if !targetType.Valid() {
+		return nil, fmt.Errorf("unknown report target %q: %w", targetType, core.ErrInvalidInput)
+	}
+	return s.repo.GetDetails(targetType, targetID)
+}
+
+func (s *ReportService) GetActions() ([]models.ModerationAction, error) {
+	return s.repo.GetActions(ReportQueueLimit)
+}
+
+// Act resolves the target's open reports with the action and records it.
+// Dismissing shows automatically hidden content again, removing deletes the comment or photo,
+// blocking blocks its author the same way admins block users.
+func (s *ReportService) Act(targetType models.ReportTarget, targetID int64, actionType models.ModerationActionType, note string, actor *models.User) (*models.ModerationAction, error) {
+	if !targetType.Valid() {
+		return nil, fmt.Errorf("unknown report target %q: %w", targetType, core.ErrInvalidInput)
+	}
+	if !actionType.Valid() {
+		return nil, fmt.Errorf("unknown action %q: %w", actionType, core.ErrInvalidInput)
+	}
+
+	action := &models.ModerationAction{
+		Action:     actionType,
+		TargetType: targetType,
+		TargetID:   targetID,
+		ActorID:    &actor.ID,
+		Note:       note,
+	}
+	if actionType == models.ActionDismiss {
+		if err := s.repo.Dismiss(action); err != nil {
+			return nil, err
+		}
+		return action, nil
+	}
+
+	// Nothing is done to content nobody reported
+	open, err := s.repo.CountOpenReports(targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	if open == 0 {
+		return nil, fmt.Errorf("no open reports of %s %d: %w", targetType, targetID, core.ErrNotFound)
+	}
+
+	switch actionType {
+	case models.ActionRemove:
+		err = s.remove(targetType, targetID, actor)
+	case models.ActionBlock:
+		action.UserID, err = s.block(targetType, targetID, actor)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Resolve(action); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
+// remove deletes the reported comment or photo, a photo that was already deleted counts as removed.
+func (s *ReportService) remove(targetType models.ReportTarget, targetID int64, actor *models.User) error {
+	switch targetType {
+	case models.ReportComment:
+		return s.comments.Remove(targetID, actor.ID)
+	case models.ReportPhoto:
+		photo, err := s.photos.GetPhotoById(targetID)
+		if err != nil || photo == nil {
+			return err
+		}
+		return s.photos.DeletePhotos(photo.EventID, []int64{photo.ID})
+	default:
+		return fmt.Errorf("users can't be removed, block them instead: %w", core.ErrInvalidInput)
+	}
+}
+
+// block blocks the reported user or the author of the reported content, returning who was blocked.
+func (s *ReportService) block(targetType models.ReportTarget, targetID int64, actor *models.User) (*int64, error) {
+	if !actor.HasPermission(models.PermissionUsersManage) {
+		return nil, fmt.Errorf("blocking users needs the %s permission: %w", models.PermissionUsersManage, core.ErrForbidden)
+	}
+
+	var userID int64
+	switch targetType {
+	case models.ReportComment:
+		authorID, err := s.comments.GetAuthorId(targetID)
+		if err != nil {
+			return nil, err
+		}
+		userID = authorID
+	case models.ReportPhoto:
+		photo, err := s.photos.GetPhotoById(targetID)
+		if err != nil {
+			return nil, err
+		}
+		if photo == nil {
+			return nil, fmt.Errorf("photo %d: %w", targetID, core.ErrNotFound)
+		}
+		if photo.UploadedByID == nil {
+			return nil, fmt.Errorf("the uploader of photo %d is unknown: %w", targetID, core.ErrConflict)
+		}
+		userID = *photo.UploadedByID
+	default:
+		userID = targetID
+	}
+
+	if userID == actor.ID {
+		return nil, fmt.Errorf("you can't block yourself: %w", core.ErrInvalidInput)
+	}
+	if err := s.roles.DeleteUserRoles(userID); err != nil {
+		return nil, err
+	}
+	return &userID, nil
+}
@@ -45,6 +45,10 @@ func (s *RoleService) GetUsersByRoleId(roleId int64) ([]models.User, error) {
 	return s.repo.GetUsersByRoleId(roleId)
 }
 
+func (s *RoleService) GetUsersByRoleName(name string) ([]models.User, error) {
+	return s.repo.GetUsersByRoleName(name)
+}
+
 func (s *RoleService) RemoveRoleFromUser(userId, roleId int64) error {
 	return s.repo.RemoveRoleFromUser(userId, roleId)
 }
@@ -60,3 +64,19 @@ func (s *RoleService) PatchAssignRoleToUsers(users []any, roleId int64) error {
 func (s *RoleService) DeleteUserRoles(userId int64) error {
 	return s.repo.DeleteUserRoles(userId)
 }
+
+func (s *RoleService) GetAllPermissions() ([]models.Permission, error) {
+	return s.repo.GetAllPermissions()
+}
+
+func (s *RoleService) GetRolePermissions(roleId int64) ([]models.Permission, error) {
+	return s.repo.GetRolePermissions(roleId)
+}
+
+func (s *RoleService) GrantPermissions(roleId int64, permissions []string) error {
+	return s.repo.GrantPermissions(roleId, permissions)
+}
+
+func (s *RoleService) RevokePermissions(roleId int64, permissions []string) error {
+	return s.repo.RevokePermissions(roleId, permissions)
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/wmfadel/wander-base/internal/models"
+)
+
+// RuleModeratorConfig configures the local rule-based moderator, it can be loaded from a JSON file.
+type RuleModeratorConfig struct {
+	Words    map[string][]string `json:"words"`     // words and phrases per category, e.g. {"profanity": [...]}
+	Patterns map[string][]string `json:"patterns"`  // regular expressions per category, matched case-insensitively
+	MaxLinks int                 `json:"max_links"` // links a comment may have before it looks like spam
+}
+
+// DefaultRuleModeratorConfig only holds spam rules, word lists depend on the audience and come from configuration.
+func DefaultRuleModeratorConfig() RuleModeratorConfig {
+	return RuleModeratorConfig{
+		Patterns: map[string][]string{
+			models.CategorySpam: {
+				`\b(buy now|click here|limited offer|free money|make money fast|work from home)\b`,
+				`\b(whats ?app|telegram) me\b`,
+				`\b(crypto|bitcoin|forex) (investment|profit|signals)\b`,
+			},
+		},
+		MaxLinks: 2,
+	}
+}
+
+// RuleModerator scores text locally without network access. Every hit of a word list or pattern
+// raises its category's score, links, repetition and shouting raise the spam score.
+type RuleModerator struct {
+	words    map[string][]string // normalized
+	patterns map[string][]*regexp.Regexp
+	maxLinks int
+}
+
+var (
+	linkPattern  = regexp.MustCompile(`(?i)\b(https?://|www\.)\S+`)
+	leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+)
+
+func NewRuleModerator(config RuleModeratorConfig) (*RuleModerator, error) {
+	m := &RuleModerator{
+		words:    map[string][]string{},
+		patterns: map[string][]*regexp.Regexp{},
+		maxLinks: config.MaxLinks,
+	}
+	for category, words := range config.Words {
+		for _, word := range words {
+			if normalized := normalizeForModeration(word); normalized != "" {
+				m.words[category] = append(m.words[category], normalized)
+			}
+		}
+	}
+	for category, patterns := range config.Patterns {
+		for _, pattern := range patterns {
+			compiled, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s moderation pattern %q: %w", category, pattern, err)
+			}
+			m.patterns[category] = append(m.patterns[category], compiled)
+		}
+	}
+	return m, nil
+}
+
+func (m *RuleModerator) Moderate(ctx context.Context, text string) (models.ModerationScores, error) {
+	scores := models.ModerationScores{}
+	raise := func(category string, score float32) {
+		if score > 1 {
+			score = 1
+		}
+		if score > scores[category] {
+			scores[category] = score
+		}
+	}
+
+	// Words are matched as whole words on the normalized text, so "class" doesn't match "ass"
+	normalized := " " + normalizeForModeration(text) + " "
+	for category, words := range m.words {
+		hits := 0
+		for _, word := range words {
+			hits += strings.Count(normalized, " "+word+" ")
+		}
+		if hits > 0 {
+			raise(category, hitScore(hits))
+		}
+	}
+	for category, patterns := range m.patterns {
+		hits := 0
+		for _, pattern := range patterns {
+			hits += len(pattern.FindAllStringIndex(text, -1))
+		}
+		if hits > 0 {
+			raise(category, hitScore(hits))
+		}
+	}
+
+	spam := linkScore(len(linkPattern.FindAllStringIndex(text, -1)), m.maxLinks) + repetitionScore(text) + shoutingScore(text)
+	if spam > 0 {
+		raise(models.CategorySpam, spam)
+	}
+	return scores, nil
+}
+
+// hitScore puts a single hit above the default threshold, two hits make it certain.
+func hitScore(hits int) float32 {
+	return 0.5 + 0.3*float32(hits)
+}
+
+// linkScore grows with every link and passes the default threshold once there are more than maxLinks.
+func linkScore(links, maxLinks int) float32 {
+	if links == 0 {
+		return 0
+	}
+	return 0.75 * float32(links) / float32(maxLinks+1)
+}
+
+// repetitionScore catches comments repeating the same words over and over, or stretching single characters.
+func repetitionScore(text string) float32 {
+	score := float32(0)
+
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) >= 6 {
+		unique := map[string]bool{}
+		for _, word := range words {
+			unique[word] = true
+		}
+		repeated := 1 - float32(len(unique))/float32(len(words))
+		if repeated > 0.4 {
+			score += (repeated - 0.4) * 2
+		}
+	}
+
+	run, previous := 0, rune(0)
+	for _, r := range text {
+		if r == previous {
+			run++
+		} else {
+			run, previous = 1, r
+		}
+		if run == 6 && !unicode.IsSpace(r) {
+			score += 0.3
+			break
+		}
+	}
+	return score
+}
+
+// shoutingScore flags comments written mostly in capitals.
+func shoutingScore(text string) float32 {
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters < 20 || float32(upper)/float32(letters) < 0.7 {
+		return 0
+	}
+	return 0.3
+}
+
+// normalizeForModeration lowercases the text, undoes leetspeak, squeezes stretched letters
+// and keeps only words separated by single spaces.
+func normalizeForModeration(text string) string {
+	text = leetReplacer.Replace(strings.ToLower(text))
+	runes := []rune(strings.Join(strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " "))
+
+	var b strings.Builder
+	for start := 0; start < len(runes); {
+		end := start
+		for end < len(runes) && runes[end] == runes[start] {
+			end++
+		}
+		// "fuuuuck" is read as "fuck", doubled letters as in "class" are kept
+		if end-start > 2 {
+			b.WriteRune(runes[start])
+		} else {
+			b.WriteString(string(runes[start:end]))
+		}
+		start = end
+	}
+	return b.String()
+}
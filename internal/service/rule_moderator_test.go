@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/wmfadel/wander-base/internal/models"
+)
+
+func testRuleModerator(t *testing.T) *RuleModerator {
+	t.Helper()
+	config := DefaultRuleModeratorConfig()
+	config.Words = map[string][]string{
+		models.CategoryProfanity: {"fuck", "ass", "son of a bitch"},
+	}
+	moderator, err := NewRuleModerator(config)
+	if err != nil {
+		t.Fatalf("NewRuleModerator: %v", err)
+	}
+	return moderator
+}
+
+func TestRuleModerator(t *testing.T) {
+	moderator := testRuleModerator(t)
+	service := NewModerationService(moderator, 0.7)
+
+	tests := []struct {
+		name   string
+		text   string
+		want   models.ModerationScores
+		hidden bool
+	}{
+		{"clean", "What a lovely view from the summit, thanks for organizing!", models.ModerationScores{}, false},
+
+		// banned words
+		{"banned word", "fuck this trail", models.ModerationScores{models.CategoryProfanity: 0.8}, true},
+		{"banned word in capitals", "Fuck this trail", models.ModerationScores{models.CategoryProfanity: 0.8}, true},
+		{"banned word in leetspeak", "what an @$$", models.ModerationScores{models.CategoryProfanity: 0.8}, true},
+		{"banned word stretched", "fuuuuuck", models.ModerationScores{models.CategoryProfanity: 0.8}, true},
+		{"banned word in punctuation", "what a pain in the...ass!!", models.ModerationScores{models.CategoryProfanity: 0.8}, true},
+		{"banned phrase", "that son-of-a-bitch guide", models.ModerationScores{models.CategoryProfanity: 0.8}, true},
+		{"banned words capped", "fuck fuck fuck", models.ModerationScores{models.CategoryProfanity: 1}, true},
+		{"banned word inside another", "first class trip, we passed the assembly hall", models.ModerationScores{}, false},
+
+		// patterns
+		{"spam pattern", "Click here for the best deals", models.ModerationScores{models.CategorySpam: 0.8}, true},
+		{"spam pattern spacing", "whats app me for tickets", models.ModerationScores{models.CategorySpam: 0.8}, true},
+		{"spam pattern needs the whole phrase", "I can't click on the map here", models.ModerationScores{}, false},
+		{"spam patterns capped", "Buy now! Telegram me about bitcoin profit", models.ModerationScores{models.CategorySpam: 1}, true},
+
+		// links
+		{"one link", "photos at https://example.com/trip", models.ModerationScores{models.CategorySpam: 0.25}, false},
+		{"two links", "https://example.com/a and www.example.com/b", models.ModerationScores{models.CategorySpam: 0.5}, false},
+		{"more links than allowed", "http://a.example http://b.example https://c.example", models.ModerationScores{models.CategorySpam: 0.75}, true},
+
+		// repetition
+		{"repeated words", "join join join join join join", models.ModerationScores{models.CategorySpam: 2 * (5.0/6 - 0.4)}, true},
+		{"some repeated words", "the view and the trail and the food", models.ModerationScores{}, false},
+		{"stretched characters", "sooooooo good", models.ModerationScores{models.CategorySpam: 0.3}, false},
+		{"stretched spaces", "good      trip", models.ModerationScores{}, false},
+
+		// shouting
+		{"shouting", "THIS WAS THE BEST TRIP EVER, AMAZING", models.ModerationScores{models.CategorySpam: 0.3}, false},
+		{"short shouting", "WOW GREAT", models.ModerationScores{}, false},
+		{"some capitals", "This Was The Best Trip Ever, Amazing", models.ModerationScores{}, false},
+
+		// the spam signals add up
+		{"shouting with links", "CHECK OUT MY CHANNEL FOR MORE AMAZING TRIPS EVERY SINGLE WEEK https://a.io https://b.io", models.ModerationScores{models.CategorySpam: 0.8}, true},
+		{"categories apart", "fuck, photos at https://example.com", models.ModerationScores{models.CategoryProfanity: 0.8, models.CategorySpam: 0.25}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scores, err := moderator.Moderate(context.Background(), test.text)
+			if err != nil {
+				t.Fatalf("Moderate: %v", err)
+			}
+			if !sameScores(scores, test.want) {
+				t.Errorf("Moderate(%q) = %v, want %v", test.text, scores, test.want)
+			}
+
+			comment := &models.Comment{Content: test.text}
+			if err := service.AuditComment(context.Background(), comment); err != nil {
+				t.Fatalf("AuditComment: %v", err)
+			}
+			if comment.Visible == test.hidden {
+				t.Errorf("comment with score %v is visible: %v, want %v", comment.Score, comment.Visible, !test.hidden)
+			}
+			wantStatus := models.CommentVisible
+			if test.hidden {
+				wantStatus = models.CommentHidden
+			}
+			if comment.Status != wantStatus {
+				t.Errorf("comment status = %s, want %s", comment.Status, wantStatus)
+			}
+		})
+	}
+}
+
+func TestRuleModeratorScoreThresholds(t *testing.T) {
+	const threshold = 0.7
+
+	if score := hitScore(1); score <= threshold {
+		t.Errorf("a single hit scores %v, not above %v", score, threshold)
+	}
+	if score := hitScore(2); score < 1 {
+		t.Errorf("two hits score %v, want at least 1", score)
+	}
+
+	for maxLinks := 0; maxLinks <= 4; maxLinks++ {
+		if score := linkScore(maxLinks, maxLinks); score > threshold {
+			t.Errorf("%d links score %v with %d allowed, above %v", maxLinks, score, maxLinks, threshold)
+		}
+		if score := linkScore(maxLinks+1, maxLinks); score <= threshold {
+			t.Errorf("%d links score %v with %d allowed, not above %v", maxLinks+1, score, maxLinks, threshold)
+		}
+	}
+	if score := linkScore(0, 2); score != 0 {
+		t.Errorf("no links score %v", score)
+	}
+
+	// Stretching and shouting alone stay visible, together with a link they don't
+	alone := []string{"sooooooo good", strings.Repeat("AMAZING ", 4)}
+	for _, text := range alone {
+		if score := repetitionScore(text) + shoutingScore(text); score > threshold {
+			t.Errorf("%q scores %v, above %v", text, score, threshold)
+		}
+	}
+	text := "SOOOOOOO GOOD, CHECK MY PROFILE FOR MORE https://a.example"
+	if score := repetitionScore(text) + shoutingScore(text) + linkScore(1, 2); score <= threshold {
+		t.Errorf("%q scores %v, not above %v", text, score, threshold)
+	}
+}
+
+func TestNewRuleModeratorInvalidPattern(t *testing.T) {
+	config := RuleModeratorConfig{Patterns: map[string][]string{models.CategorySpam: {`(unclosed`}}}
+	if _, err := NewRuleModerator(config); err == nil {
+		t.Error("NewRuleModerator accepted an invalid pattern")
+	}
+}
+
+func sameScores(got, want models.ModerationScores) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for category, score := range want {
+		if math.Abs(float64(got[category]-score)) > 1e-5 {
+			return false
+		}
+	}
+	return true
+}
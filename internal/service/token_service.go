@@ -0,0 +1,281 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/repository"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+const (
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	// SessionSeenInterval keeps authenticated requests from writing the session's last seen time every time
+	SessionSeenInterval = time.Minute
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrSessionRevoked      = errors.New("session revoked")
+)
+
+type TokenService struct {
+	repo     *repository.TokenRepository
+	userRepo *repository.UserRepository
+	events   *repository.SecurityEventRepository
+}
+
+func NewTokenService(repo *repository.TokenRepository, userRepo *repository.UserRepository, events *repository.SecurityEventRepository) *TokenService {
+	return &TokenService{repo: repo, userRepo: userRepo, events: events}
+}
+
+// IssueTokens starts a new session for the user on the device and returns its first token pair,
+// twoFactor tells if the login passed two-factor authentication.
+func (s *TokenService) IssueTokens(phone string, userID int64, twoFactor bool, device models.SessionDevice) (*models.TokenPair, error) {
+	familyID, err := utils.RandomToken(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token family: %w", err)
+	}
+
+	refreshToken, record, err := newRefreshToken(userID, familyID, twoFactor)
+	if err != nil {
+		return nil, err
+	}
+	session := &models.Session{
+		ID:          familyID,
+		UserID:      userID,
+		DeviceLabel: device.Label,
+		IP:          device.IP,
+		UserAgent:   device.UserAgent,
+		TwoFactor:   twoFactor,
+		LastSeenAt:  time.Now(),
+		ExpiresAt:   record.ExpiresAt,
+	}
+	if err := s.repo.CreateSession(session, record); err != nil {
+		return nil, err
+	}
+
+	return s.newPair(phone, userID, familyID, twoFactor, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new pair, rotating the refresh token.
+// Reusing a rotated token is treated as theft and revokes the whole family.
+func (s *TokenService) Refresh(refreshToken string, device models.SessionDevice) (*models.TokenPair, error) {
+	current, err := s.repo.GetRefreshTokenByHash(utils.HashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if current.Revoked() {
+		if err := s.repo.RevokeFamily(current.FamilyID); err != nil {
+			return nil, err
+		}
+		log.Printf("refresh token reuse detected for user %d, family %s revoked", current.UserID, current.FamilyID)
+		return nil, ErrInvalidRefreshToken
+	}
+	if current.Expired() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetUserByID(current.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load token owner: %w", err)
+	}
+	if user.IssuedBeforeReset(current.CreatedAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	nextToken, next, err := newRefreshToken(current.UserID, current.FamilyID, current.TwoFactor)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.RotateRefreshToken(current.ID, next); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRefreshToken, err)
+	}
+	s.refreshSession(next, device)
+
+	return s.newPair(user.Phone, user.ID, current.FamilyID, current.TwoFactor, nextToken)
+}
+
+// Logout revokes the refresh token family behind the access token and denylists the access token itself.
+func (s *TokenService) Logout(claims *utils.AccessClaims) error {
+	if claims.SessionID != "" {
+		if err := s.repo.RevokeFamily(claims.SessionID); err != nil {
+			return err
+		}
+	}
+
+	err := s.repo.DenylistAccessToken(&models.RevokedAccessToken{
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.PurgeExpired(); err != nil {
+		log.Printf("warning: %v", err)
+	}
+	return nil
+}
+
+// RevokeOtherSessions logs the user out everywhere but the kept session, "" keeps none.
+func (s *TokenService) RevokeOtherSessions(userID int64, keptSessionID string) error {
+	return s.repo.RevokeUserFamilies(userID, keptSessionID)
+}
+
+// refreshSession moves the session's expiry along with its rotated refresh token. Families from before
+// sessions were recorded get their session now. Failures are only logged, the refresh went through.
+func (s *TokenService) refreshSession(token *models.RefreshToken, device models.SessionDevice) {
+	touched, err := s.repo.TouchSession(token.FamilyID, device, &token.ExpiresAt)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	if touched {
+		return
+	}
+	err = s.repo.SaveSession(&models.Session{
+		ID:         token.FamilyID,
+		UserID:     token.UserID,
+		IP:         device.IP,
+		UserAgent:  device.UserAgent,
+		TwoFactor:  token.TwoFactor,
+		LastSeenAt: time.Now(),
+		ExpiresAt:  token.ExpiresAt,
+	})
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// CheckSession rejects access tokens of revoked sessions and records that the session was seen.
+// Tokens of families from before sessions were recorded are let through.
+func (s *TokenService) CheckSession(claims *utils.AccessClaims, device models.SessionDevice) error {
+	if claims.SessionID == "" {
+		return nil
+	}
+	session, err := s.repo.GetSession(claims.SessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return nil
+	}
+	if session.Revoked() {
+		return ErrSessionRevoked
+	}
+
+	if time.Since(session.LastSeenAt) > SessionSeenInterval {
+		if _, err := s.repo.TouchSession(session.ID, device, nil); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *TokenService) GetSession(sessionID string) (*models.Session, error) {
+	return s.repo.GetSession(sessionID)
+}
+
+// GetSessions lists the user's active sessions, marking the current one.
+func (s *TokenService) GetSessions(userID int64, currentSessionID string) ([]models.Session, error) {
+	sessions, err := s.repo.GetUserSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession logs one of the user's sessions out, its access tokens stop working right away.
+func (s *TokenService) RevokeSession(userID int64, sessionID string) error {
+	revoked, err := s.repo.RevokeUserSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return fmt.Errorf("session %s: %w", sessionID, core.ErrNotFound)
+	}
+	return nil
+}
+
+// RevokeUserSession is RevokeSession for admins, it is recorded as a security event.
+func (s *TokenService) RevokeUserSession(userID int64, sessionID string, admin *models.User) error {
+	phone, err := s.userRepo.GetPhone(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.RevokeSession(userID, sessionID); err != nil {
+		return err
+	}
+	s.logRevoked(userID, phone, admin, "session "+sessionID)
+	return nil
+}
+
+// RevokeUserSessions logs the user out everywhere for an admin, it is recorded as a security event.
+func (s *TokenService) RevokeUserSessions(userID int64, admin *models.User) error {
+	phone, err := s.userRepo.GetPhone(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.RevokeOtherSessions(userID, ""); err != nil {
+		return err
+	}
+	s.logRevoked(userID, phone, admin, "all sessions")
+	return nil
+}
+
+// logRevoked records the admin's revocation, failures are only logged since the sessions are revoked.
+func (s *TokenService) logRevoked(userID int64, phone string, admin *models.User, details string) {
+	err := s.events.Create(&models.SecurityEvent{
+		Type:    models.EventSessionRevoked,
+		Subject: phone,
+		UserID:  &userID,
+		ActorID: &admin.ID,
+		Details: details,
+	})
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+func (s *TokenService) IsAccessTokenRevoked(jti string) (bool, error) {
+	return s.repo.IsAccessTokenRevoked(jti)
+}
+
+func (s *TokenService) newPair(phone string, userID int64, familyID string, twoFactor bool, refreshToken string) (*models.TokenPair, error) {
+	accessToken, claims, err := utils.GernerateToken(phone, userID, familyID, twoFactor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create access token: %w", err)
+	}
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    claims.ExpiresAt.Time,
+	}, nil
+}
+
+func newRefreshToken(userID int64, familyID string, twoFactor bool) (string, *models.RefreshToken, error) {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return token, &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(token),
+		TwoFactor: twoFactor,
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}, nil
+}
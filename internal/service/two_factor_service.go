@@ -0,0 +1,359 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/repository"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+const (
+	RecoveryCodeCount         = 10
+	LoginChallengeTTL         = 5 * time.Minute
+	LoginChallengeMaxAttempts = 5 // wrong codes before the user has to log in with the password again
+	recoveryCodeAlphabet      = "abcdefghijklmnopqrstuvwxyz234567"
+	recoveryCodeHalf          = 5 // recovery codes look like "abcde-fgh23"
+)
+
+var (
+	ErrInvalidTwoFactorCode  = fmt.Errorf("invalid two-factor code: %w", core.ErrForbidden)
+	ErrInvalidLoginChallenge = errors.New("invalid or expired login challenge, log in again")
+)
+
+// TwoFactorRolesFromEnv reads the roles whose users have to use two-factor, from the comma separated
+// TWO_FACTOR_REQUIRED_ROLES. Admins have to by default, "none" requires it of nobody.
+func TwoFactorRolesFromEnv() []string {
+	value := utils.GetFromEnvOr("TWO_FACTOR_REQUIRED_ROLES", models.RoleAdmin)
+	if value == "none" {
+		return nil
+	}
+	var roles []string
+	for _, role := range strings.Split(value, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+type TwoFactorService struct {
+	repo          *repository.TwoFactorRepository
+	events        *repository.SecurityEventRepository
+	userRepo      *repository.UserRepository
+	verifications *VerificationService
+	requiredRoles []string
+	issuer        string
+}
+
+func NewTwoFactorService(repo *repository.TwoFactorRepository, events *repository.SecurityEventRepository, userRepo *repository.UserRepository, verifications *VerificationService, requiredRoles []string) *TwoFactorService {
+	return &TwoFactorService{
+		repo:          repo,
+		events:        events,
+		userRepo:      userRepo,
+		verifications: verifications,
+		requiredRoles: requiredRoles,
+		issuer:        utils.GetFromEnvOr("TOTP_ISSUER", "Wander"),
+	}
+}
+
+// Required tells if one of the user's roles requires two-factor. Such users can still log in without it
+// to enroll, but their permissions are only usable from sessions that passed two-factor.
+func (s *TwoFactorService) Required(user *models.User) bool {
+	for _, role := range s.requiredRoles {
+		if user.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithholdPermissions strips the permissions of the user's roles that require two-factor, for sessions
+// that didn't pass it. The roles stay so the user isn't taken for blocked, permissions granted by
+// their other roles are kept.
+func (s *TwoFactorService) WithholdPermissions(user *models.User) {
+	for i, role := range user.Roles {
+		if slices.Contains(s.requiredRoles, role.Name) {
+			user.Roles[i].Permissions = nil
+		}
+	}
+}
+
+func (s *TwoFactorService) Enabled(userID int64) (bool, error) {
+	twoFactor, err := s.repo.Get(userID)
+	if err != nil {
+		return false, err
+	}
+	return twoFactor != nil && twoFactor.Enabled(), nil
+}
+
+func (s *TwoFactorService) Status(user *models.User) (*models.TwoFactorStatus, error) {
+	status := &models.TwoFactorStatus{Required: s.Required(user)}
+	twoFactor, err := s.repo.Get(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor == nil || !twoFactor.Enabled() {
+		return status, nil
+	}
+
+	status.Enabled, status.EnabledAt = true, twoFactor.EnabledAt
+	status.RecoveryCodesLeft, err = s.repo.CountRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Enroll starts setting up an authenticator, returning its secret and the otpauth:// URI to scan.
+// Logins only ask for codes once the user confirmed a first one. Users whose role requires two-factor
+// also need a code sent to their phone, their first authenticator unlocks the role's permissions.
+func (s *TwoFactorService) Enroll(user *models.User, password, smsCode string) (string, string, error) {
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return "", "", fmt.Errorf("wrong password: %w", core.ErrForbidden)
+	}
+	if s.Required(user) {
+		if smsCode == "" {
+			return "", "", fmt.Errorf("your role requires a code sent to your phone to enroll: %w", core.ErrInvalidInput)
+		}
+		if err := s.verifications.ConfirmTwoFactorCode(user, smsCode); err != nil {
+			return "", "", err
+		}
+	}
+
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := utils.SealSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.repo.SavePending(&models.TwoFactor{UserID: user.ID, Secret: sealed}); err != nil {
+		return "", "", err
+	}
+	return secret, utils.TOTPURI(s.issuer, user.Phone, secret), nil
+}
+
+// Confirm enables the pending authenticator with a first code from it and returns the recovery codes,
+// they are only ever shown this once.
+func (s *TwoFactorService) Confirm(user *models.User, code string) ([]string, error) {
+	twoFactor, err := s.repo.Get(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor == nil {
+		return nil, fmt.Errorf("no authenticator to confirm, enroll first: %w", core.ErrNotFound)
+	}
+	if twoFactor.Enabled() {
+		return nil, fmt.Errorf("two-factor is already enabled: %w", core.ErrConflict)
+	}
+
+	secret, err := utils.OpenSecret(twoFactor.Secret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := utils.CheckTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Enable(user.ID, step, hashes); err != nil {
+		return nil, err
+	}
+	s.logEvent(models.EventTwoFactorEnabled, user.ID, user.Phone, nil)
+	return codes, nil
+}
+
+// Disable removes the user's authenticator once the password and a code confirm it is them.
+// Users whose roles require two-factor can't disable it.
+func (s *TwoFactorService) Disable(user *models.User, password, code string) error {
+	if s.Required(user) {
+		return fmt.Errorf("two-factor is required for your role: %w", core.ErrForbidden)
+	}
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return fmt.Errorf("wrong password: %w", core.ErrForbidden)
+	}
+	if err := s.verify(user.ID, code); err != nil {
+		return err
+	}
+
+	if _, err := s.repo.Delete(user.ID); err != nil {
+		return err
+	}
+	s.logEvent(models.EventTwoFactorDisabled, user.ID, user.Phone, nil)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes, a TOTP code has to confirm it.
+func (s *TwoFactorService) RegenerateRecoveryCodes(user *models.User, code string) ([]string, error) {
+	if err := s.verifyTOTP(user.ID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Reset removes a user's two-factor for an admin, when the user lost both the authenticator and the
+// recovery codes. Users whose roles require two-factor will have to enroll again.
+func (s *TwoFactorService) Reset(userID int64, admin *models.User) error {
+	phone, err := s.userRepo.GetPhone(userID)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.repo.Delete(userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("user %d has no two-factor: %w", userID, core.ErrNotFound)
+	}
+	s.logEvent(models.EventTwoFactorReset, userID, phone, &admin.ID)
+	return nil
+}
+
+// StartChallenge hands out the challenge a password login with two-factor enabled returns instead of tokens.
+func (s *TwoFactorService) StartChallenge(userID int64) (*models.TwoFactorChallenge, error) {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create login challenge: %w", err)
+	}
+	challenge := &models.LoginChallenge{
+		UserID:    userID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(LoginChallengeTTL),
+	}
+	if err := s.repo.CreateChallenge(challenge); err != nil {
+		return nil, err
+	}
+	return &models.TwoFactorChallenge{ChallengeToken: token, ExpiresAt: challenge.ExpiresAt}, nil
+}
+
+func (s *TwoFactorService) GetChallenge(token string) (*models.LoginChallenge, error) {
+	challenge, err := s.repo.GetChallenge(utils.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil || challenge.Expired() {
+		return nil, ErrInvalidLoginChallenge
+	}
+	return challenge, nil
+}
+
+// CompleteChallenge checks a TOTP or recovery code against the challenge and uses it up.
+func (s *TwoFactorService) CompleteChallenge(challenge *models.LoginChallenge, code string) error {
+	allowed, err := s.repo.RecordChallengeAttempt(challenge.ID, LoginChallengeMaxAttempts)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("too many wrong codes: %w", ErrInvalidLoginChallenge)
+	}
+	if err := s.verify(challenge.UserID, code); err != nil {
+		return err
+	}
+
+	consumed, err := s.repo.ConsumeChallenge(challenge.ID)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidLoginChallenge
+	}
+	return nil
+}
+
+// verify accepts a TOTP code or, failing that, one of the user's recovery codes.
+func (s *TwoFactorService) verify(userID int64, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == utils.TOTPDigits {
+		return s.verifyTOTP(userID, code)
+	}
+
+	used, err := s.repo.UseRecoveryCode(userID, recoveryCodeHash(userID, code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// verifyTOTP checks the code against the user's enabled authenticator, each code only works once.
+func (s *TwoFactorService) verifyTOTP(userID int64, code string) error {
+	twoFactor, err := s.repo.Get(userID)
+	if err != nil {
+		return err
+	}
+	if twoFactor == nil || !twoFactor.Enabled() {
+		return fmt.Errorf("two-factor is not enabled: %w", core.ErrNotFound)
+	}
+
+	secret, err := utils.OpenSecret(twoFactor.Secret)
+	if err != nil {
+		return err
+	}
+	step, ok := utils.CheckTOTP(secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	fresh, err := s.repo.UseStep(userID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return fmt.Errorf("code already used, wait for the next one: %w", ErrInvalidTwoFactorCode)
+	}
+	return nil
+}
+
+func (s *TwoFactorService) logEvent(eventType models.SecurityEventType, userID int64, phone string, actorID *int64) {
+	err := s.events.Create(&models.SecurityEvent{
+		Type:    eventType,
+		Subject: phone,
+		UserID:  &userID,
+		ActorID: actorID,
+	})
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// newRecoveryCodes returns RecoveryCodeCount new codes along with their hashes.
+func newRecoveryCodes(userID int64) ([]string, []string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([]string, 0, RecoveryCodeCount)
+	for range RecoveryCodeCount {
+		raw, err := utils.RandomString(2*recoveryCodeHalf, recoveryCodeAlphabet)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := raw[:recoveryCodeHalf] + "-" + raw[recoveryCodeHalf:]
+		codes = append(codes, code)
+		hashes = append(hashes, recoveryCodeHash(userID, code))
+	}
+	return codes, hashes, nil
+}
+
+// recoveryCodeHash ignores case and dashes, users copy codes by hand.
+func recoveryCodeHash(userID int64, code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return utils.HashCode(normalized, fmt.Sprintf("%d:recovery", userID))
+}
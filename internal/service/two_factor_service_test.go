@@ -0,0 +1,41 @@
+package service
+
+import "testing"
+
+func TestRecoveryCodeHash(t *testing.T) {
+	t.Setenv("CODE_SECRET", "test code secret")
+
+	want := recoveryCodeHash(7, "abcde-fgh23")
+	for _, code := range []string{"abcdefgh23", "ABCDE-FGH23", "AbCdE-fGh23", " abcde-fgh23 ", "ab-cde-fgh-23"} {
+		if got := recoveryCodeHash(7, code); got != want {
+			t.Errorf("recoveryCodeHash(%q) differs from the issued code's", code)
+		}
+	}
+
+	if recoveryCodeHash(8, "abcde-fgh23") == want {
+		t.Error("recoveryCodeHash is the same for another user")
+	}
+	if recoveryCodeHash(7, "abcde-fgh24") == want {
+		t.Error("recoveryCodeHash is the same for another code")
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	t.Setenv("CODE_SECRET", "test code secret")
+
+	codes, hashes, err := newRecoveryCodes(7)
+	if err != nil {
+		t.Fatalf("newRecoveryCodes: %v", err)
+	}
+	if len(codes) != RecoveryCodeCount || len(hashes) != RecoveryCodeCount {
+		t.Fatalf("got %d codes and %d hashes, want %d", len(codes), len(hashes), RecoveryCodeCount)
+	}
+	for i, code := range codes {
+		if len(code) != 2*recoveryCodeHalf+1 || code[recoveryCodeHalf] != '-' {
+			t.Errorf("code %q is not formatted like abcde-fgh23", code)
+		}
+		if hashes[i] != recoveryCodeHash(7, code) {
+			t.Errorf("hash of code %q doesn't match it", code)
+		}
+	}
+}
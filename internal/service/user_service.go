@@ -23,6 +23,7 @@ func NewUserService(repo *repository.UserRepository, rolesRepo *repository.RoleR
 }
 
 func (s *UserService) Create(user *models.User) error {
+	user.PhoneVerifiedAt = nil // only confirming a code verifies the phone
 	user, err := s.repo.Create(user)
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
@@ -59,9 +60,8 @@ func (s *UserService) ValidateCredintials(loginRequest *requests.LoginRequest) e
 	return s.repo.ValidateCredintials(loginRequest)
 }
 
-func (s *UserService) UpdatePhoto(userId int64, photo *multipart.FileHeader) (string, error) {
-	user := &models.User{ID: userId}
-	return s.repo.AddPhoto(user, photo)
+func (s *UserService) UpdatePhoto(userId int64, photo *multipart.FileHeader) (*models.ImageVariants, error) {
+	return s.repo.AddPhoto(userId, photo)
 }
 
 func (s *UserService) UpdateUser(user *models.User, patch *requests.PatchUser) error {
@@ -77,3 +77,7 @@ func (s *UserService) UpdateUser(user *models.User, patch *requests.PatchUser) e
 	user.Roles = roles
 	return nil
 }
+
+func (s *UserService) GetUserSummary(id int64) (*models.UserSummary, error) {
+	return s.repo.GetUserSummary(id)
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"github.com/wmfadel/wander-base/internal/repository"
+	"github.com/wmfadel/wander-base/pkg/utils"
+)
+
+const (
+	VerificationCodeLength  = 6
+	VerificationCodeTTL     = 10 * time.Minute
+	VerificationMaxAttempts = 5           // wrong guesses before the code is dead
+	VerificationCooldown    = time.Minute // between two codes sent to the same phone
+	VerificationMaxSends    = 5           // codes sent to the same phone per VerificationWindow
+	VerificationWindow      = time.Hour
+	verificationSendTimeout = 10 * time.Second
+)
+
+type VerificationService struct {
+	repo     *repository.VerificationRepository
+	userRepo *repository.UserRepository
+	sms      utils.SMSSender
+}
+
+func NewVerificationService(repo *repository.VerificationRepository, userRepo *repository.UserRepository, sms utils.SMSSender) *VerificationService {
+	return &VerificationService{repo: repo, userRepo: userRepo, sms: sms}
+}
+
+// SendSignupCode sends a code confirming the phone the user signed up with.
+func (s *VerificationService) SendSignupCode(user *models.User) error {
+	if user.PhoneVerified() {
+		return fmt.Errorf("phone %s is already verified: %w", user.Phone, core.ErrConflict)
+	}
+	return s.send(user.ID, user.Phone, models.VerifySignup)
+}
+
+// StartPhoneChange sends a code to the new phone, the user keeps their current phone until it is confirmed.
+// The password is asked again since the phone is what users log in with.
+func (s *VerificationService) StartPhoneChange(user *models.User, phone, password string) error {
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return fmt.Errorf("wrong password: %w", core.ErrForbidden)
+	}
+	if phone == user.Phone {
+		return fmt.Errorf("phone %s is already yours: %w", phone, core.ErrInvalidInput)
+	}
+	taken, err := s.userRepo.PhoneTaken(phone, user.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("phone %s is already used: %w", phone, core.ErrConflict)
+	}
+	return s.send(user.ID, phone, models.VerifyPhoneChange)
+}
+
+// send rate limits codes per phone rather than per user, so nobody can flood a number
+// by asking for codes from several accounts.
+func (s *VerificationService) send(userID int64, phone string, purpose models.VerificationPurpose) error {
+	recent, err := s.repo.CountSentSince(phone, time.Now().Add(-VerificationCooldown))
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		return fmt.Errorf("a code was sent to %s less than %s ago: %w", phone, VerificationCooldown, core.ErrRateLimited)
+	}
+	sent, err := s.repo.CountSentSince(phone, time.Now().Add(-VerificationWindow))
+	if err != nil {
+		return err
+	}
+	if sent >= VerificationMaxSends {
+		return fmt.Errorf("too many codes sent to %s, try again later: %w", phone, core.ErrRateLimited)
+	}
+
+	code, err := utils.RandomDigits(VerificationCodeLength)
+	if err != nil {
+		return err
+	}
+	verification := &models.PhoneVerification{
+		UserID:    userID,
+		Phone:     phone,
+		Purpose:   purpose,
+		CodeHash:  utils.HashCode(code, verificationSalt(userID, phone)),
+		ExpiresAt: time.Now().Add(VerificationCodeTTL),
+	}
+	// Saved before sending, so failed sends still count towards the limits
+	if err := s.repo.Create(verification); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), verificationSendTimeout)
+	defer cancel()
+	message := fmt.Sprintf("Your verification code is %s, it expires in %d minutes.", code, int(VerificationCodeTTL.Minutes()))
+	if err := s.sms.Send(ctx, phone, message); err != nil {
+		return fmt.Errorf("failed to send code to %s: %w", phone, err)
+	}
+	return nil
+}
+
+// Confirm checks the code against the user's latest signup or phone change code and verifies its phone.
+// It returns the phone that was verified.
+func (s *VerificationService) Confirm(user *models.User, code string) (string, error) {
+	verification, err := s.check(user.ID, code, models.VerifySignup, models.VerifyPhoneChange)
+	if err != nil {
+		return "", err
+	}
+
+	if verification.Purpose == models.VerifyPhoneChange {
+		taken, err := s.userRepo.PhoneTaken(verification.Phone, user.ID)
+		if err != nil {
+			return "", err
+		}
+		if taken {
+			return "", fmt.Errorf("phone %s is already used: %w", verification.Phone, core.ErrConflict)
+		}
+	}
+	if err := s.repo.Consume(verification, nil); err != nil {
+		return "", err
+	}
+	return verification.Phone, nil
+}
+
+// SendPasswordResetCode sends a code letting the user set a new password without the current one.
+func (s *VerificationService) SendPasswordResetCode(user *models.User) error {
+	return s.send(user.ID, user.Phone, models.VerifyPassword)
+}
+
+// ConfirmPasswordReset checks the user's latest password reset code and applies the user updates along with it.
+// Receiving the code proves owning the phone, so the phone is verified too.
+func (s *VerificationService) ConfirmPasswordReset(user *models.User, code string, userUpdates map[string]interface{}) error {
+	verification, err := s.check(user.ID, code, models.VerifyPassword)
+	if err != nil {
+		return err
+	}
+	return s.repo.Consume(verification, userUpdates)
+}
+
+// SendTwoFactorCode sends a code proving the user still holds their phone before they enroll an authenticator,
+// so a leaked password alone isn't enough to take over an account whose role requires two-factor.
+func (s *VerificationService) SendTwoFactorCode(user *models.User) error {
+	if !user.PhoneVerified() {
+		return fmt.Errorf("verify your phone first: %w", core.ErrForbidden)
+	}
+	return s.send(user.ID, user.Phone, models.VerifyTwoFactor)
+}
+
+// ConfirmTwoFactorCode checks and uses up the user's latest two-factor enrollment code.
+func (s *VerificationService) ConfirmTwoFactorCode(user *models.User, code string) error {
+	verification, err := s.check(user.ID, code, models.VerifyTwoFactor)
+	if err != nil {
+		return err
+	}
+	return s.repo.Consume(verification, nil)
+}
+
+// check counts an attempt at the user's latest code for the purposes and compares it with the given code.
+func (s *VerificationService) check(userID int64, code string, purposes ...models.VerificationPurpose) (*models.PhoneVerification, error) {
+	verification, err := s.repo.GetActive(userID, purposes...)
+	if err != nil {
+		return nil, err
+	}
+	if verification == nil {
+		return nil, fmt.Errorf("no code to confirm, request a new one: %w", core.ErrNotFound)
+	}
+
+	allowed, err := s.repo.RecordAttempt(verification.ID, VerificationMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, fmt.Errorf("too many wrong codes, request a new one: %w", core.ErrRateLimited)
+	}
+	if !utils.CheckCode(code, verificationSalt(userID, verification.Phone), verification.CodeHash) {
+		left := VerificationMaxAttempts - verification.Attempts - 1
+		return nil, fmt.Errorf("wrong code, %d attempts left: %w", left, core.ErrInvalidInput)
+	}
+	return verification, nil
+}
+
+func verificationSalt(userID int64, phone string) string {
+	return fmt.Sprintf("%d:%s", userID, phone)
+}
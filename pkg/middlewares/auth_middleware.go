@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wmfadel/wander-base/internal/models/core"
@@ -11,14 +14,18 @@ import (
 )
 
 type AuthMiddleware struct {
-	userService  *service.UserService
-	eventService *service.EventService
+	userService      *service.UserService
+	eventService     *service.EventService
+	tokenService     *service.TokenService
+	twoFactorService *service.TwoFactorService
 }
 
-func NewAuthMiddleware(userService *service.UserService, eventService *service.EventService) *AuthMiddleware {
+func NewAuthMiddleware(userService *service.UserService, eventService *service.EventService, tokenService *service.TokenService, twoFactorService *service.TwoFactorService) *AuthMiddleware {
 	return &AuthMiddleware{
-		userService:  userService,
-		eventService: eventService,
+		userService:      userService,
+		eventService:     eventService,
+		tokenService:     tokenService,
+		twoFactorService: twoFactorService,
 	}
 }
 
@@ -32,12 +39,33 @@ func (amw *AuthMiddleware) Authenticate(context *gin.Context) {
 		return
 	}
 
-	userId, err := utils.VerifyToken(token)
-	log.Printf("userId from token: %v", userId)
+	claims, err := utils.VerifyToken(token)
 	if err != nil {
 		context.AbortWithStatusJSON(http.StatusUnauthorized, core.NewESError("Invalid token", err))
 		return
 	}
+	userId := claims.UserID
+	log.Printf("userId from token: %v", userId)
+
+	revoked, err := amw.tokenService.IsAccessTokenRevoked(claims.ID)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusInternalServerError, core.NewESError("Could not verify token", err))
+		return
+	}
+	if revoked {
+		context.AbortWithStatusJSON(http.StatusUnauthorized, core.NewESError("Token revoked", nil))
+		return
+	}
+
+	err = amw.tokenService.CheckSession(claims, utils.GetSessionDevice(context, ""))
+	if errors.Is(err, service.ErrSessionRevoked) {
+		context.AbortWithStatusJSON(http.StatusUnauthorized, core.NewESError("Session revoked", err))
+		return
+	}
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusInternalServerError, core.NewESError("Could not verify session", err))
+		return
+	}
 
 	if userId == 0 {
 		context.AbortWithStatusJSON(http.StatusUnauthorized, core.NewESError("user not found", err))
@@ -60,32 +88,103 @@ func (amw *AuthMiddleware) Authenticate(context *gin.Context) {
 		return
 	}
 
+	// Tokens issued before a password reset belong to sessions the reset ended
+	if claims.IssuedAt == nil || user.IssuedBeforeReset(claims.IssuedAt.Time) {
+		context.AbortWithStatusJSON(http.StatusUnauthorized, core.NewESError("Token revoked", nil))
+		return
+	}
+
+	// Every permission check reads the user's roles, so sessions that skipped a required two-factor
+	// lose the grants of those roles here rather than at each check
+	if !claims.TwoFactor {
+		amw.twoFactorService.WithholdPermissions(user)
+	}
+
 	log.Printf("user in middleware: %v", user)
 	context.Set("userId", userId)
 	context.Set("user", *user)
+	context.Set("claims", *claims)
 	context.Next()
 }
 
-func (amw *AuthMiddleware) RequiresAdmin(context *gin.Context) {
+// OptionalAuthenticate authenticates the request when it carries a token and lets anonymous requests through,
+// for public routes whose response depends on who is asking.
+func (amw *AuthMiddleware) OptionalAuthenticate(context *gin.Context) {
+	if context.Request.Header.Get("Authorization") == "" {
+		context.Next()
+		return
+	}
+	amw.Authenticate(context)
+}
 
+// RequireVerifiedPhone only lets users through once they confirmed their phone number,
+// unverified users can still log in, see public routes and manage their profile.
+func (amw *AuthMiddleware) RequireVerifiedPhone(context *gin.Context) {
 	user, err := utils.GetUserFromContext(context)
-
 	if err != nil {
 		context.AbortWithStatusJSON(http.StatusBadRequest, core.NewESError("Could not find user", err))
 		return
 	}
 
-	isAdmin := false
-	for _, role := range user.Roles {
-		if role.ID == 1 {
-			isAdmin = true
-			break
+	if !user.PhoneVerified() {
+		context.AbortWithStatusJSON(http.StatusForbidden, core.NewESError("Phone number not verified", nil))
+		return
+	}
+	context.Next()
+}
+
+// RequirePermission only lets the request through if one of the user's roles grants the permission.
+func (amw *AuthMiddleware) RequirePermission(permission string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user, err := utils.GetUserFromContext(context)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, core.NewESError("Could not find user", err))
+			return
+		}
+
+		if !user.HasPermission(permission) {
+			// The permission may only be withheld, tell the user how to get it back
+			if claims, err := utils.GetClaimsFromContext(context); err == nil && !claims.TwoFactor && amw.twoFactorService.Required(user) {
+				context.AbortWithStatusJSON(http.StatusForbidden, core.NewESError("Two-factor authentication required, enable it and log in with it", nil))
+				return
+			}
+			context.AbortWithStatusJSON(http.StatusForbidden, core.NewESError(fmt.Sprintf("Missing permission %s", permission), nil))
+			return
 		}
+		context.Next()
+	}
+}
+
+// RequiresEventManager loads the event from the :id param into the context and only lets
+// its creator, co-hosts or admins through.
+func (amw *AuthMiddleware) RequiresEventManager(context *gin.Context) {
+	user, err := utils.GetUserFromContext(context)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, core.NewESError("Could not find user", err))
+		return
+	}
+
+	eventId, err := strconv.ParseInt(context.Param("id"), 10, 64)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusBadRequest, core.NewESError("Failed to parse event ID", err))
+		return
+	}
+
+	event, err := amw.eventService.GetEventById(eventId)
+	if err != nil {
+		context.AbortWithStatusJSON(http.StatusInternalServerError, core.NewESError("Could not load event", err))
+		return
+	}
+	if event == nil {
+		context.AbortWithStatusJSON(http.StatusNotFound, core.NewESError("Event not found", nil))
+		return
 	}
 
-	if !isAdmin {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, core.NewESError("Unauthorized to create/edit events", nil))
+	if !event.CanBeManagedBy(user) {
+		context.AbortWithStatusJSON(http.StatusForbidden, core.NewESError("Not allowed to manage this event", nil))
 		return
 	}
+
+	context.Set("event", *event)
 	context.Next()
 }
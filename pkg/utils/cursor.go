@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// cursor is the position of the last item of a page for keyset pagination,
+// the sort key value plus the id to break ties.
+type cursor struct {
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+// EncodeCursor builds an opaque pagination cursor.
+func EncodeCursor(value string, id int64) string {
+	raw, _ := json.Marshal(cursor{Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor reads a cursor built by EncodeCursor.
+func DecodeCursor(encoded string) (string, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed cursor: %w", err)
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return "", 0, fmt.Errorf("malformed cursor: %w", err)
+	}
+	return c.Value, c.ID, nil
+}
@@ -17,3 +17,11 @@ func LoadEnv() error {
 func GetFromEnv(key string) string {
 	return os.Getenv(key)
 }
+
+// GetFromEnvOr returns the fallback when the variable is unset or empty.
+func GetFromEnvOr(key, fallback string) string {
+	if value := GetFromEnv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// exifSegment builds an APP1 segment holding only the orientation tag.
+func exifSegment(orientation int, order binary.ByteOrder) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8) // first IFD right after the header
+	order.PutUint16(tiff[8:], 1) // one entry
+	order.PutUint16(tiff[10:], exifOrientationTag)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], uint16(orientation))
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// withExif puts the segment right after the JPEG's SOI marker.
+func withExif(t *testing.T, jpegData, segment []byte) []byte {
+	t.Helper()
+	if !bytes.HasPrefix(jpegData, []byte{0xFF, 0xD8}) {
+		t.Fatal("not a JPEG")
+	}
+	return append(append([]byte{0xFF, 0xD8}, segment...), jpegData[2:]...)
+}
+
+func encodeTestJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatalf("failed to encode JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	plain := encodeTestJPEG(t, 4, 2)
+	for orientation := 1; orientation <= 8; orientation++ {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			data := withExif(t, plain, exifSegment(orientation, order))
+			if got := jpegOrientation(data); got != orientation {
+				t.Errorf("jpegOrientation with %d in %s = %d", orientation, order, got)
+			}
+		}
+	}
+
+	truncated := exifSegment(6, binary.BigEndian)
+	truncated = truncated[:len(truncated)-10]
+	tests := map[string][]byte{
+		"no EXIF":             plain,
+		"not a JPEG":          []byte("\x89PNG\r\n\x1a\n"),
+		"empty":               nil,
+		"invalid orientation": withExif(t, plain, exifSegment(9, binary.BigEndian)),
+		"zero orientation":    withExif(t, plain, exifSegment(0, binary.LittleEndian)),
+		"truncated segment":   append([]byte{0xFF, 0xD8}, truncated...),
+	}
+	for name, data := range tests {
+		if got := jpegOrientation(data); got != 1 {
+			t.Errorf("jpegOrientation of %s = %d, want 1", name, got)
+		}
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// A 3x2 image with marked corners, as stored by the camera
+	const width, height = 3, 2
+	topLeft := color.NRGBA{R: 255, A: 255}
+	topRight := color.NRGBA{G: 255, A: 255}
+	bottomLeft := color.NRGBA{B: 255, A: 255}
+	stored := image.NewNRGBA(image.Rect(0, 0, width, height))
+	stored.Set(0, 0, topLeft)
+	stored.Set(width-1, 0, topRight)
+	stored.Set(0, height-1, bottomLeft)
+
+	type point struct{ x, y int }
+	// Where the stored corners end up once the image is upright, per the EXIF orientation
+	tests := []struct {
+		orientation                   int
+		topLeft, topRight, bottomLeft point
+		swapped                       bool
+	}{
+		{1, point{0, 0}, point{2, 0}, point{0, 1}, false},
+		{2, point{2, 0}, point{0, 0}, point{2, 1}, false},
+		{3, point{2, 1}, point{0, 1}, point{2, 0}, false},
+		{4, point{0, 1}, point{2, 1}, point{0, 0}, false},
+		{5, point{0, 0}, point{0, 2}, point{1, 0}, true},
+		{6, point{1, 0}, point{1, 2}, point{0, 0}, true},
+		{7, point{1, 2}, point{1, 0}, point{0, 2}, true},
+		{8, point{0, 2}, point{0, 0}, point{1, 2}, true},
+	}
+	for _, test := range tests {
+		upright := applyOrientation(stored, test.orientation)
+		wantWidth, wantHeight := width, height
+		if test.swapped {
+			wantWidth, wantHeight = height, width
+		}
+		if bounds := upright.Bounds(); bounds.Dx() != wantWidth || bounds.Dy() != wantHeight {
+			t.Errorf("orientation %d: got %dx%d, want %dx%d", test.orientation, bounds.Dx(), bounds.Dy(), wantWidth, wantHeight)
+			continue
+		}
+		for _, corner := range []struct {
+			name  string
+			at    point
+			color color.NRGBA
+		}{{"top left", test.topLeft, topLeft}, {"top right", test.topRight, topRight}, {"bottom left", test.bottomLeft, bottomLeft}} {
+			if got := color.NRGBAModel.Convert(upright.At(corner.at.x, corner.at.y)); got != corner.color {
+				t.Errorf("orientation %d: stored %s corner not at %v", test.orientation, corner.name, corner.at)
+			}
+		}
+	}
+}
@@ -1,11 +1,20 @@
 package utils
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+const passwordCost = 14
+
+// dummyPasswordHash is a hash of a random password with passwordCost, it never matches anything users type.
+const dummyPasswordHash = "$2a$14$S7MU2Ndm60TkVXkHF.hngusz6xlMm8wcf/CDI00XEb791.xoL8RIO"
+
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
 	return string(bytes), err
 }
 
@@ -13,3 +22,30 @@ func CheckPasswordHash(password, hashedPassword string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err == nil
 }
+
+// CheckDummyPasswordHash takes as long as checking a real password, so logins with unknown phones
+// can't be told apart from wrong passwords by their timing. It always fails.
+func CheckDummyPasswordHash(password string) bool {
+	CheckPasswordHash(password, dummyPasswordHash)
+	return false
+}
+
+// HashToken hashes high entropy tokens (refresh tokens etc.) before they are stored.
+// A fast hash is enough here since the tokens are random and never chosen by users.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashCode hashes short one-time codes. Six digits are too few to survive a plain hash being leaked,
+// so the hash is keyed with CODE_SECRET (TOKEN_SECRET by default) and salted with what the code is for.
+func HashCode(code, salt string) string {
+	mac := hmac.New(sha256.New, []byte(GetFromEnvOr("CODE_SECRET", GetFromEnv("TOKEN_SECRET"))))
+	mac.Write([]byte(salt + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckCode compares a code against its HashCode hash in constant time.
+func CheckCode(code, salt, hash string) bool {
+	return hmac.Equal([]byte(HashCode(code, salt)), []byte(hash))
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const icalLineLimit = 75 // octets per content line, excluding CRLF (RFC 5545 section 3.1)
+
+// ICalendar writes an RFC 5545 VCALENDAR. Content lines are folded at 75 octets and
+// terminated by CRLF, timestamps are written in UTC unless WriteLocalTime is used.
+type ICalendar struct {
+	builder   strings.Builder
+	headerEnd int                  // where components start, VTIMEZONEs are put there
+	zones     map[string]*icalZone // by TZID
+}
+
+func NewICalendar(name string) *ICalendar {
+	c := &ICalendar{}
+	c.Begin("VCALENDAR")
+	c.WriteLine("VERSION", "2.0")
+	c.WriteLine("PRODID", "-//wander-base//events//EN")
+	c.WriteLine("CALSCALE", "GREGORIAN")
+	c.WriteLine("METHOD", "PUBLISH")
+	if name != "" {
+		c.WriteText("X-WR-CALNAME", name)
+	}
+	c.headerEnd = c.builder.Len()
+	return c
+}
+
+func (c *ICalendar) Begin(component string) {
+	c.WriteLine("BEGIN", component)
+}
+
+func (c *ICalendar) End(component string) {
+	c.WriteLine("END", component)
+}
+
+// WriteText writes a TEXT property, escaping its value.
+func (c *ICalendar) WriteText(name, value string) {
+	c.WriteLine(name, EscapeICalText(value))
+}
+
+// WriteTime writes a DATE-TIME property in UTC form, e.g. 20250102T150405Z.
+func (c *ICalendar) WriteTime(name string, t time.Time) {
+	c.WriteLine(name, t.UTC().Format("20060102T150405Z"))
+}
+
+// WriteLocalTime writes a DATE-TIME property in the local time of t's location with its TZID,
+// e.g. DTSTART;TZID=Europe/Berlin:20250102T150405, so clients repeat it across DST changes
+// at the same local time. The calendar gets a VTIMEZONE for every TZID used.
+// Times in UTC or the server's unnamed Local zone are written in UTC form.
+func (c *ICalendar) WriteLocalTime(name string, t time.Time) {
+	location := t.Location()
+	if location == time.UTC || location == time.Local || location.String() == "UTC" || location.String() == "Local" {
+		c.WriteTime(name, t)
+		return
+	}
+	c.useZone(t)
+	c.WriteLine(name+";TZID="+location.String(), t.Format("20060102T150405"))
+}
+
+// WriteLine writes a property whose value is already encoded.
+func (c *ICalendar) WriteLine(name, value string) {
+	c.builder.WriteString(foldICalLine(name + ":" + value))
+}
+
+// Finish closes the calendar and returns its content.
+func (c *ICalendar) Finish() []byte {
+	c.End("VCALENDAR")
+	content := c.builder.String()
+	if len(c.zones) == 0 {
+		return []byte(content)
+	}
+	return []byte(content[:c.headerEnd] + c.timezones() + content[c.headerEnd:])
+}
+
+// EscapeICalText escapes backslashes, semicolons, commas and newlines in TEXT values.
+func EscapeICalText(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	)
+	return replacer.Replace(value)
+}
+
+// foldICalLine splits a content line into CRLF terminated lines of at most 75 octets.
+// Continuation lines start with a space, and multi-byte characters are never split.
+func foldICalLine(line string) string {
+	var folded strings.Builder
+	limit := icalLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		folded.WriteString(line[:cut])
+		folded.WriteString("\r\n ")
+		line = line[cut:]
+		limit = icalLineLimit - 1 // the leading space counts towards the limit
+	}
+	folded.WriteString(line)
+	folded.WriteString("\r\n")
+	return folded.String()
+}
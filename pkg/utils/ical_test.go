@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestICalendarTimezones(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	calendar := NewICalendar("")
+	calendar.Begin("VEVENT")
+	calendar.WriteLocalTime("DTSTART", time.Date(2025, time.January, 6, 18, 0, 0, 0, berlin))
+	calendar.WriteLocalTime("EXDATE", time.Date(2025, time.July, 7, 18, 0, 0, 0, berlin))
+	calendar.WriteLocalTime("DTEND", time.Date(2025, time.January, 6, 18, 0, 0, 0, time.UTC))
+	calendar.End("VEVENT")
+	content := string(calendar.Finish())
+
+	for _, line := range []string{
+		"DTSTART;TZID=Europe/Berlin:20250106T180000",
+		"EXDATE;TZID=Europe/Berlin:20250707T180000",
+		"DTEND:20250106T180000Z",
+		"TZID:Europe/Berlin",
+		"DTSTART:20250330T020000",
+		"DTSTART:20251026T030000",
+		"TZOFFSETFROM:+0100",
+		"TZOFFSETTO:+0200",
+	} {
+		if !strings.Contains(content, line+"\r\n") {
+			t.Errorf("calendar is missing %q:\n%s", line, content)
+		}
+	}
+	if strings.Count(content, "BEGIN:VTIMEZONE") != 1 {
+		t.Errorf("want one VTIMEZONE:\n%s", content)
+	}
+	if strings.Index(content, "BEGIN:VTIMEZONE") > strings.Index(content, "BEGIN:VEVENT") {
+		t.Errorf("VTIMEZONE should come before the events:\n%s", content)
+	}
+	if !strings.Contains(content, "RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU\r\n") ||
+		!strings.Contains(content, "RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU\r\n") {
+		t.Errorf("calendar is missing the yearly DST rules:\n%s", content)
+	}
+}
+
+func TestICalendarWithoutZones(t *testing.T) {
+	calendar := NewICalendar("")
+	calendar.WriteLocalTime("DTSTART", time.Date(2025, time.January, 6, 18, 0, 0, 0, time.UTC))
+	calendar.WriteLocalTime("DTEND", time.Date(2025, time.January, 6, 18, 0, 0, 0, time.Local))
+	content := string(calendar.Finish())
+	if strings.Contains(content, "TZID") || strings.Contains(content, "VTIMEZONE") {
+		t.Errorf("UTC and Local times should be written in UTC:\n%s", content)
+	}
+}
+
+func TestZoneTransitions(t *testing.T) {
+	tests := []struct {
+		zone  string
+		year  int
+		want  []string // local times before each change
+		rules []string
+	}{
+		{"Europe/Berlin", 2025, []string{"20250330T020000", "20251026T030000"}, []string{"-1SU", "-1SU"}},
+		{"America/New_York", 2025, []string{"20250309T020000", "20251102T020000"}, []string{"2SU", "1SU"}},
+		{"Asia/Tokyo", 2025, nil, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.zone, func(t *testing.T) {
+			location := mustLocation(t, test.zone)
+			transitions := zoneTransitions(location, test.year)
+			if len(transitions) != len(test.want) {
+				t.Fatalf("got %d transitions, want %d", len(transitions), len(test.want))
+			}
+			for i, transition := range transitions {
+				local := transition.at.UTC().Add(time.Duration(transition.from) * time.Second)
+				if got := local.Format("20060102T150405"); got != test.want[i] {
+					t.Errorf("transition %d at %s, want %s", i, got, test.want[i])
+				}
+				if got := monthWeekday(local).String(); got != test.rules[i] {
+					t.Errorf("transition %d on %s, want %s", i, got, test.rules[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFormatUTCOffset(t *testing.T) {
+	tests := map[int]string{0: "+0000", 3600: "+0100", -16200: "-0430", 20700: "+0545", -2: "-000002"}
+	for offset, want := range tests {
+		if got := formatUTCOffset(offset); got != want {
+			t.Errorf("formatUTCOffset(%d) = %s, want %s", offset, got, want)
+		}
+	}
+}
+
+func TestEscapeICalText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Hike to the lake", "Hike to the lake"},
+		{`C:\trips\2025`, `C:\\trips\\2025`},
+		{"bring water; snacks", `bring water\; snacks`},
+		{"Berlin, Germany", `Berlin\, Germany`},
+		{"line one\r\nline two", `line one\nline two`},
+		{"line one\nline two", `line one\nline two`},
+		{"line one\rline two", `line one\nline two`},
+		{"\n\n", `\n\n`},
+		{`already \n escaped`, `already \\n escaped`},
+		{`a\;b,c`, `a\\\;b\,c`},
+		{"Café: 10€", "Café: 10€"},
+	}
+	for _, test := range tests {
+		if got := EscapeICalText(test.value); got != test.want {
+			t.Errorf("EscapeICalText(%q) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestFoldICalLine(t *testing.T) {
+	a := func(n int) string { return strings.Repeat("a", n) }
+
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"empty", "", "\r\n"},
+		{"short", "SUMMARY:Hike", "SUMMARY:Hike\r\n"},
+		{"75 octets", a(75), a(75) + "\r\n"},
+		{"76 octets", a(76), a(75) + "\r\n a\r\n"},
+		{"continuation of 74 octets", a(75 + 74), a(75) + "\r\n " + a(74) + "\r\n"},
+		{"two continuations", a(75 + 74 + 1), a(75) + "\r\n " + a(74) + "\r\n a\r\n"},
+		{"2-byte rune ending at 75", a(73) + "é", a(73) + "é\r\n"},
+		{"2-byte rune across 75", a(74) + "é", a(74) + "\r\n é\r\n"},
+		{"3-byte rune across 75", a(73) + "€", a(73) + "\r\n €\r\n"},
+		{"3-byte rune across 75 by one", a(74) + "€", a(74) + "\r\n €\r\n"},
+		{"4-byte rune across 75", a(72) + "🏔", a(72) + "\r\n 🏔\r\n"},
+		{"4-byte rune ending at 75", a(71) + "🏔", a(71) + "🏔\r\n"},
+		{"rune across a continuation", a(75) + a(73) + "é", a(75) + "\r\n " + a(73) + "\r\n é\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := foldICalLine(test.line); got != test.want {
+				t.Errorf("foldICalLine(%q) = %q, want %q", test.line, got, test.want)
+			}
+		})
+	}
+
+	// Whatever the runes, every line fits, stays valid UTF-8 and unfolds to the original
+	for _, r := range []string{"a", "é", "€", "🏔", "a€🏔é"} {
+		for length := 70; length < 300; length++ {
+			line := strings.Repeat(r, length)
+			folded := foldICalLine(line)
+			if !strings.HasSuffix(folded, "\r\n") {
+				t.Fatalf("folded %d × %q isn't CRLF terminated", length, r)
+			}
+			for i, part := range strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n") {
+				if len(part) > 75 {
+					t.Errorf("folded %d × %q has a line of %d octets", length, r, len(part))
+				}
+				if !utf8.ValidString(part) {
+					t.Errorf("folded %d × %q splits a rune: %q", length, r, part)
+				}
+				if i > 0 && !strings.HasPrefix(part, " ") {
+					t.Errorf("folded %d × %q has a continuation without a space: %q", length, r, part)
+				}
+			}
+			if unfolded := strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", ""); unfolded != line {
+				t.Errorf("folded %d × %q unfolds to %q", length, r, unfolded)
+			}
+		}
+	}
+}
+
+func TestICalendarWriteText(t *testing.T) {
+	calendar := NewICalendar("-//test//EN")
+	calendar.WriteText("DESCRIPTION", "Meet at the station; bring boots, water\nand snacks. Ask Zoë about the café on the way back")
+	content := string(calendar.Finish())
+
+	want := `DESCRIPTION:Meet at the station\; bring boots\, water\nand snacks. Ask Zoë` + "\r\n " +
+		` about the café on the way back` + "\r\n"
+	if !strings.Contains(content, want) {
+		t.Errorf("calendar doesn't contain %q:\n%s", want, content)
+	}
+}
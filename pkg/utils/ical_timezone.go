@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// icalZone is a timezone used by a calendar and the years of the times written in it.
+type icalZone struct {
+	location    *time.Location
+	first, last int
+}
+
+// zoneTransition is a change of a timezone's UTC offset, e.g. the start of summer time.
+type zoneTransition struct {
+	at       time.Time
+	from, to int // offsets in seconds east of UTC
+	name     string
+	dst      bool
+}
+
+func (c *ICalendar) useZone(t time.Time) {
+	if c.zones == nil {
+		c.zones = map[string]*icalZone{}
+	}
+	name := t.Location().String()
+	zone, ok := c.zones[name]
+	if !ok {
+		c.zones[name] = &icalZone{location: t.Location(), first: t.Year(), last: t.Year()}
+		return
+	}
+	zone.first = min(zone.first, t.Year())
+	zone.last = max(zone.last, t.Year())
+}
+
+// timezones writes a VTIMEZONE for every zone used. Each lists the zone's offset changes from the first year
+// a time was written for on, the changes of the last year (this year at the earliest) repeat yearly
+// so recurring events stay right in the years after.
+func (c *ICalendar) timezones() string {
+	names := make([]string, 0, len(c.zones))
+	for name := range c.zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	zones := &ICalendar{}
+	for _, name := range names {
+		zone := c.zones[name]
+		zones.writeTimezone(zone.location, zone.first, max(zone.last, time.Now().Year()))
+	}
+	return zones.builder.String()
+}
+
+func (c *ICalendar) writeTimezone(location *time.Location, from, to int) {
+	c.Begin("VTIMEZONE")
+	c.WriteLine("TZID", location.String())
+	start := time.Date(from, time.January, 1, 0, 0, 0, 0, location)
+	name, offset := start.Zone()
+	c.writeObservance(zoneTransition{at: start, from: offset, to: offset, name: name, dst: start.IsDST()}, false)
+	for year := from; year <= to; year++ {
+		for _, transition := range zoneTransitions(location, year) {
+			c.writeObservance(transition, year == to)
+		}
+	}
+	c.End("VTIMEZONE")
+}
+
+// writeObservance writes the STANDARD or DAYLIGHT part a transition starts. Its DTSTART is the
+// local time before the change, repeating ones recur on the same weekday of the month every year.
+func (c *ICalendar) writeObservance(transition zoneTransition, repeating bool) {
+	kind := "STANDARD"
+	if transition.dst {
+		kind = "DAYLIGHT"
+	}
+	local := transition.at.UTC().Add(time.Duration(transition.from) * time.Second)
+	c.Begin(kind)
+	c.WriteLine("DTSTART", local.Format("20060102T150405"))
+	c.WriteLine("TZOFFSETFROM", formatUTCOffset(transition.from))
+	c.WriteLine("TZOFFSETTO", formatUTCOffset(transition.to))
+	if repeating {
+		c.WriteLine("RRULE", fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%s", local.Month(), monthWeekday(local)))
+	}
+	c.WriteText("TZNAME", transition.name)
+	c.End(kind)
+}
+
+// zoneTransitions finds the offset changes of the location within the year, to the second.
+func zoneTransitions(location *time.Location, year int) []zoneTransition {
+	var transitions []zoneTransition
+	day := time.Date(year, time.January, 1, 0, 0, 0, 0, location)
+	end := day.AddDate(1, 0, 0)
+	_, offset := day.Zone()
+	for day.Before(end) {
+		next := day.Add(24 * time.Hour)
+		if _, nextOffset := next.Zone(); nextOffset != offset {
+			before, after := day.Unix(), next.Unix()
+			for after-before > 1 {
+				middle := (before + after) / 2
+				if _, middleOffset := time.Unix(middle, 0).In(location).Zone(); middleOffset == offset {
+					before = middle
+				} else {
+					after = middle
+				}
+			}
+			at := time.Unix(after, 0).In(location)
+			name, _ := at.Zone()
+			transitions = append(transitions, zoneTransition{at: at, from: offset, to: nextOffset, name: name, dst: at.IsDST()})
+			offset = nextOffset
+		}
+		day = next
+	}
+	return transitions
+}
+
+// monthWeekday is the BYDAY of the day's weekday within its month, e.g. 2SU or -1SU for the last Sunday.
+func monthWeekday(day time.Time) WeekdayNum {
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if day.Day()+7 > daysInMonth {
+		return WeekdayNum{N: -1, Day: day.Weekday()}
+	}
+	return WeekdayNum{N: (day.Day()-1)/7 + 1, Day: day.Weekday()}
+}
+
+// formatUTCOffset formats an offset in seconds east of UTC as a UTC-OFFSET value, e.g. +0100 or -0430.
+func formatUTCOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	value := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset/60%60)
+	if offset%60 != 0 {
+		value += fmt.Sprintf("%02d", offset%60)
+	}
+	return value
+}
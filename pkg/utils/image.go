@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/wmfadel/wander-base/internal/models"
+	"github.com/wmfadel/wander-base/internal/models/core"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // registers the WebP decoder
+)
+
+const (
+	MaxImageBytes  = 15 << 20   // largest accepted upload
+	MaxImageSide   = 12000      // widest or tallest accepted image
+	MaxImagePixels = 50_000_000 // checked before decoding so small files can't expand into huge bitmaps
+
+	ThumbnailSide = 320
+	MediumSide    = 1280
+	jpegQuality   = 85
+)
+
+// allowedImageTypes are the sniffed content types uploads may have, the client's Content-Type is ignored.
+var allowedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// UploadImage validates an uploaded image, re-encodes it into its variants and saves them under prefix.
+// Re-encoding drops all metadata, EXIF and GPS included, after applying the EXIF orientation.
+// Images with transparency are stored as PNG, everything else as JPEG.
+func UploadImage(s Storage, file *multipart.FileHeader, prefix string, id int64) (models.ImageVariants, error) {
+	var variants models.ImageVariants
+	if file.Size > MaxImageBytes {
+		return variants, fmt.Errorf("image is larger than %d bytes: %w", MaxImageBytes, core.ErrInvalidInput)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return variants, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, MaxImageBytes+1))
+	if err != nil {
+		return variants, fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(data) > MaxImageBytes {
+		return variants, fmt.Errorf("image is larger than %d bytes: %w", MaxImageBytes, core.ErrInvalidInput)
+	}
+
+	img, sourceType, err := decodeImage(data)
+	if err != nil {
+		return variants, err
+	}
+
+	// The same base key for every variant keeps them next to each other in storage
+	suffix, err := RandomToken(6)
+	if err != nil {
+		return variants, err
+	}
+	base := fmt.Sprintf("%s/%d-%s-%s", prefix, id, time.Now().Format("20060102T150405"), suffix)
+
+	encodeAsPNG := sourceType == "image/png" || !isOpaque(img)
+	saved := []string{}
+	save := func(name string, variant image.Image) (models.ImageVariant, error) {
+		content, contentType, extension, err := encodeImage(variant, encodeAsPNG)
+		if err != nil {
+			return models.ImageVariant{}, err
+		}
+		url, err := s.Save(fmt.Sprintf("%s-%s.%s", base, name, extension), bytes.NewReader(content), int64(len(content)), contentType)
+		if err != nil {
+			return models.ImageVariant{}, err
+		}
+		saved = append(saved, url)
+		bounds := variant.Bounds()
+		return models.ImageVariant{URL: url, Width: bounds.Dx(), Height: bounds.Dy()}, nil
+	}
+
+	variants.Original, err = save("original", img)
+	if err == nil {
+		variants.Medium, err = saveResized(save, "medium", img, MediumSide, variants.Original)
+	}
+	if err == nil {
+		variants.Thumbnail, err = saveResized(save, "thumbnail", img, ThumbnailSide, variants.Medium)
+	}
+	if err != nil {
+		// Don't leave a partial set of variants behind
+		for _, url := range saved {
+			if err := DeleteFile(s, url); err != nil {
+				log.Printf("Warning: failed to delete file %s from storage: %v", url, err)
+			}
+		}
+		return models.ImageVariants{}, err
+	}
+	return variants, nil
+}
+
+// DeleteImage deletes every file of the variants, logging failures since the DB is already updated by then.
+func DeleteImage(s Storage, variants models.ImageVariants) {
+	for _, url := range variants.URLs() {
+		if err := DeleteFile(s, url); err != nil {
+			log.Printf("Warning: failed to delete file %s from storage: %v", url, err)
+		}
+	}
+}
+
+// decodeImage sniffs the image type from its magic bytes and checks its dimensions before decoding it.
+func decodeImage(data []byte) (image.Image, string, error) {
+	contentType := http.DetectContentType(data)
+	if !allowedImageTypes[contentType] {
+		return nil, "", fmt.Errorf("unsupported image type %s, only JPEG, PNG and WebP are allowed: %w", contentType, core.ErrInvalidInput)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("malformed image: %w", core.ErrInvalidInput)
+	}
+	if config.Width > MaxImageSide || config.Height > MaxImageSide || config.Width*config.Height > MaxImagePixels {
+		return nil, "", fmt.Errorf("image of %dx%d is too large: %w", config.Width, config.Height, core.ErrInvalidInput)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("malformed image: %w", core.ErrInvalidInput)
+	}
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+	return img, contentType, nil
+}
+
+// saveResized saves img scaled down to fit within maxSide, reusing the larger variant if it already fits.
+func saveResized(save func(string, image.Image) (models.ImageVariant, error), name string, img image.Image, maxSide int, larger models.ImageVariant) (models.ImageVariant, error) {
+	if larger.Width <= maxSide && larger.Height <= maxSide {
+		return larger, nil
+	}
+	return save(name, resize(img, maxSide))
+}
+
+func resize(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width >= height {
+		height = max(1, height*maxSide/width)
+		width = maxSide
+	} else {
+		width = max(1, width*maxSide/height)
+		height = maxSide
+	}
+	resized := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, draw.Src, nil)
+	return resized
+}
+
+func encodeImage(img image.Image, asPNG bool) ([]byte, string, string, error) {
+	var buf bytes.Buffer
+	if asPNG {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", "", fmt.Errorf("failed to encode image: %w", err)
+		}
+		return buf.Bytes(), "image/png", "png", nil
+	}
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, "", "", fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), "image/jpeg", "jpg", nil
+}
+
+func isOpaque(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return opaque.Opaque()
+	}
+	return true
+}
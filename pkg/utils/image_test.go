@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/wmfadel/wander-base/internal/models/core"
+)
+
+// memoryStorage keeps files in a map, for tests.
+type memoryStorage struct {
+	files map[string][]byte
+}
+
+func (s *memoryStorage) Save(key string, content io.Reader, size int64, contentType string) (string, error) {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+	s.files[key] = data
+	return "mem://" + key, nil
+}
+
+func (s *memoryStorage) Open(key string) (io.ReadCloser, error) {
+	data, ok := s.files[key]
+	if !ok {
+		return nil, fmt.Errorf("no file %s", key)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memoryStorage) Delete(key string) error {
+	delete(s.files, key)
+	return nil
+}
+
+func (s *memoryStorage) KeyFromURL(url string) (string, error) {
+	return strings.TrimPrefix(url, "mem://"), nil
+}
+
+func encodeTestPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// pngClaiming is a small PNG whose header claims the given size, the pixel data never gets read.
+func pngClaiming(t *testing.T, width, height int) []byte {
+	t.Helper()
+	data := encodeTestPNG(t, 1, 1)
+	// signature (8), IHDR length (4) and type (4), then width and height
+	binary.BigEndian.PutUint32(data[16:], uint32(width))
+	binary.BigEndian.PutUint32(data[20:], uint32(height))
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+// fileHeader wraps data in an uploaded file as gin hands it to handlers.
+func fileHeader(t *testing.T, data []byte, contentType string) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="photo"; filename="photo"`)
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(32 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["photo"][0]
+}
+
+func TestDecodeImageRejects(t *testing.T) {
+	var gifData bytes.Buffer
+	if err := gif.Encode(&gifData, image.NewPaletted(image.Rect(0, 0, 2, 2), []color.Color{color.Black}), nil); err != nil {
+		t.Fatal(err)
+	}
+	truncated := encodeTestPNG(t, 8, 8)
+	truncated = truncated[:len(truncated)/2]
+
+	tests := map[string][]byte{
+		"empty":               nil,
+		"GIF":                 gifData.Bytes(),
+		"BMP":                 append([]byte("BM"), make([]byte, 64)...),
+		"PDF":                 []byte("%PDF-1.7\n1 0 obj\n<<>>\nendobj\n"),
+		"SVG":                 []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`),
+		"HTML":                []byte("<!DOCTYPE html><html><body>hi</body></html>"),
+		"ZIP":                 []byte("PK\x03\x04" + strings.Repeat("\x00", 40)),
+		"text":                []byte("just some text"),
+		"truncated PNG":       truncated,
+		"too wide":            pngClaiming(t, MaxImageSide+1, 1),
+		"too tall":            pngClaiming(t, 1, MaxImageSide+1),
+		"too many pixels":     pngClaiming(t, 8000, 7000),
+		"PNG signature only":  []byte("\x89PNG\r\n\x1a\n"),
+		"JPEG signature only": []byte{0xFF, 0xD8, 0xFF, 0xE0},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := decodeImage(data); !errors.Is(err, core.ErrInvalidInput) {
+				t.Errorf("decodeImage = %v, want an invalid input error", err)
+			}
+		})
+	}
+}
+
+func TestUploadImageRejectsLargeFiles(t *testing.T) {
+	storage := &memoryStorage{files: map[string][]byte{}}
+	file := fileHeader(t, encodeTestPNG(t, 1, 1), "image/png")
+	file.Size = MaxImageBytes + 1
+	if _, err := UploadImage(storage, file, "events", 1); !errors.Is(err, core.ErrInvalidInput) {
+		t.Errorf("UploadImage = %v, want an invalid input error", err)
+	}
+
+	// The size the client sent can't be trusted, the content is cut off after the limit
+	padded := append(encodeTestPNG(t, 1, 1), make([]byte, MaxImageBytes)...)
+	file = fileHeader(t, padded, "image/png")
+	file.Size = 1
+	if _, err := UploadImage(storage, file, "events", 1); !errors.Is(err, core.ErrInvalidInput) {
+		t.Errorf("UploadImage = %v, want an invalid input error", err)
+	}
+	if len(storage.files) != 0 {
+		t.Errorf("rejected uploads left %d files", len(storage.files))
+	}
+}
+
+func TestUploadImage(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string // claimed by the client, ignored
+		width       int
+		height      int
+		extension   string
+	}{
+		{"JPEG turned upright", withExif(t, encodeTestJPEG(t, 2000, 1000), exifSegment(6, binary.BigEndian)), "image/png", 1000, 2000, ".jpg"},
+		{"PNG", encodeTestPNG(t, 400, 200), "application/octet-stream", 400, 200, ".png"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			storage := &memoryStorage{files: map[string][]byte{}}
+			variants, err := UploadImage(storage, fileHeader(t, test.data, test.contentType), "events", 7)
+			if err != nil {
+				t.Fatalf("UploadImage: %v", err)
+			}
+			if variants.Original.Width != test.width || variants.Original.Height != test.height {
+				t.Errorf("original is %dx%d, want %dx%d", variants.Original.Width, variants.Original.Height, test.width, test.height)
+			}
+			if !strings.HasPrefix(variants.Original.URL, "mem://events/7-") || !strings.HasSuffix(variants.Original.URL, test.extension) {
+				t.Errorf("original saved as %s", variants.Original.URL)
+			}
+			if max(variants.Thumbnail.Width, variants.Thumbnail.Height) > ThumbnailSide {
+				t.Errorf("thumbnail is %dx%d", variants.Thumbnail.Width, variants.Thumbnail.Height)
+			}
+			if len(storage.files) != len(variants.URLs()) {
+				t.Errorf("saved %d files for %d variants", len(storage.files), len(variants.URLs()))
+			}
+			for key, data := range storage.files {
+				if bytes.Contains(data, []byte("Exif\x00\x00")) {
+					t.Errorf("%s kept its EXIF data", key)
+				}
+			}
+
+			DeleteImage(storage, variants)
+			if len(storage.files) != 0 {
+				t.Errorf("DeleteImage left %d files", len(storage.files))
+			}
+		})
+	}
+}
@@ -8,20 +8,53 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GernerateToken(phone string, userId int64) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"phone":  phone,
-		"userId": userId,
-		"exp":    time.Now().Add(time.Hour * 2).Unix(),
-	})
+// AccessTokenTTL is kept short, long lived sessions are carried by refresh tokens.
+const AccessTokenTTL = 15 * time.Minute
+
+// AccessClaims are the claims carried by every access token.
+// SessionID is the refresh token family the access token was issued for,
+// TwoFactor tells if the session's login passed two-factor authentication.
+type AccessClaims struct {
+	Phone     string `json:"phone"`
+	UserID    int64  `json:"userId"`
+	SessionID string `json:"sid"`
+	TwoFactor bool   `json:"2fa,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func GernerateToken(phone string, userId int64, sessionID string, twoFactor bool) (string, *AccessClaims, error) {
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate token id: %w", err)
+	}
+
+	now := time.Now()
+	claims := &AccessClaims{
+		Phone:     phone,
+		UserID:    userId,
+		SessionID: sessionID,
+		TwoFactor: twoFactor,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	secretKey := GetFromEnv("TOKEN_SECRET")
-	return token.SignedString([]byte(secretKey))
+	signed, err := token.SignedString([]byte(secretKey))
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
-func VerifyToken(token string) (int64, error) {
-	parseedToken, err := jwt.Parse(
+func VerifyToken(token string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	parseedToken, err := jwt.ParseWithClaims(
 		token,
+		claims,
 		func(t *jwt.Token) (interface{}, error) {
 			_, ok := t.Method.(*jwt.SigningMethodHMAC)
 			if !ok {
@@ -32,21 +65,16 @@ func VerifyToken(token string) (int64, error) {
 	)
 
 	if err != nil {
-		return 0, fmt.Errorf("jwt token parse failed %w", err)
+		return nil, fmt.Errorf("jwt token parse failed %w", err)
 	}
 
 	if !parseedToken.Valid {
-		return 0, errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
-	claims, ok := parseedToken.Claims.(jwt.MapClaims)
-
-	if !ok {
-		return 0, errors.New("invalid token")
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil, errors.New("invalid token")
 	}
 
-	userId := int64(claims["userId"].(float64))
-
-	return userId, nil
-
+	return claims, nil
 }
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// legacyDirs are the folders of Dir files were saved to before they moved under BaseURL, URLs stored back then
+// look like http://localhost:8080/events/12-20250102T150405. Migrations point them at BaseURL.
+var legacyDirs = []string{"user_photos", "events"}
+
+// legacyRoutes are the legacyDirs the server root still serves for links to the old URLs,
+// the API owns /events so event photos are only reachable under BaseURL.
+var legacyRoutes = []string{"user_photos"}
+
+// LocalStorage keeps files on the local disk, the server serves Dir under the path of BaseURL.
+// It only suits single instance deployments, replicas don't share their disks.
+type LocalStorage struct {
+	Dir     string // e.g. "public"
+	BaseURL string // e.g. "http://localhost:8080/media"
+}
+
+// NewLocalStorage needs a base URL with a path, the server can't serve files at its root next to the API.
+func NewLocalStorage(dir, baseURL string) (*LocalStorage, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage URL %q: %w", baseURL, err)
+	}
+	if parsed.Path == "" {
+		return nil, fmt.Errorf("storage URL %q needs a path to serve files under, e.g. /media", baseURL)
+	}
+	for _, legacy := range legacyDirs {
+		if parsed.Path == "/"+legacy || strings.HasPrefix(parsed.Path, "/"+legacy+"/") {
+			return nil, fmt.Errorf("storage URL %q clashes with the files still served under /%s", baseURL, legacy)
+		}
+	}
+	return &LocalStorage{Dir: dir, BaseURL: baseURL}, nil
+}
+
+func (s *LocalStorage) Save(key string, content io.Reader, size int64, contentType string) (string, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	// Ensure directory exists
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory for %s: %w", key, err)
+	}
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file %s: %w", path, err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, content); err != nil {
+		return "", fmt.Errorf("failed to write file %s: %w", path, err)
+	}
+	return s.URL(key), nil
+}
+
+// URL is the public URL of the file with the key.
+func (s *LocalStorage) URL(key string) string {
+	return s.BaseURL + "/" + key
+}
+
+func (s *LocalStorage) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	return file, nil
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) KeyFromURL(fileURL string) (string, error) {
+	key := strings.TrimPrefix(fileURL, s.BaseURL+"/")
+	if key != fileURL {
+		return key, nil
+	}
+	// Files saved before BaseURL are still in Dir, under the folder their URL starts with
+	if parsed, err := url.Parse(fileURL); err == nil {
+		key = strings.TrimPrefix(parsed.Path, "/")
+		if dir, _, found := strings.Cut(key, "/"); found && slices.Contains(legacyDirs, dir) {
+			return key, nil
+		}
+	}
+	return "", fmt.Errorf("invalid URL format: %s", fileURL)
+}
+
+// RoutePath is the path the server has to serve Dir under, e.g. "/media".
+func (s *LocalStorage) RoutePath() string {
+	parsed, _ := url.Parse(s.BaseURL) // checked by NewLocalStorage
+	return parsed.Path
+}
+
+// LegacyRoutes maps the paths the server served files under before BaseURL to their folders,
+// so URLs stored back then keep working.
+func (s *LocalStorage) LegacyRoutes() map[string]string {
+	routes := make(map[string]string, len(legacyRoutes))
+	for _, dir := range legacyRoutes {
+		routes["/"+dir] = filepath.Join(s.Dir, dir)
+	}
+	return routes
+}
+
+// path resolves the key inside Dir, refusing keys that would escape it.
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if cleaned == "/" {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(s.Dir, cleaned), nil
+}
@@ -0,0 +1,45 @@
+package utils
+
+import "testing"
+
+func TestLocalStorageKeyFromURL(t *testing.T) {
+	storage, err := NewLocalStorage(t.TempDir(), "http://localhost:8080/media/")
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"current", "http://localhost:8080/media/events/12-20250102T150405-x1y2z3.jpg", "events/12-20250102T150405-x1y2z3.jpg"},
+		{"legacy event photo", "http://localhost:8080/events/12-20250102T150405", "events/12-20250102T150405"},
+		{"legacy user photo", "http://localhost:8080/user_photos/3-20250102T150405", "user_photos/3-20250102T150405"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := storage.KeyFromURL(test.url)
+			if err != nil || key != test.want {
+				t.Fatalf("KeyFromURL = %q, %v, want %q", key, err, test.want)
+			}
+			if migrated, err := storage.KeyFromURL(storage.URL(key)); err != nil || migrated != key {
+				t.Errorf("KeyFromURL(URL(key)) = %q, %v, want %q", migrated, err, key)
+			}
+		})
+	}
+
+	for _, url := range []string{"http://localhost:8080/event_photos/12-20250102T150405", "http://localhost:8080/media"} {
+		if key, err := storage.KeyFromURL(url); err == nil {
+			t.Errorf("KeyFromURL(%q) = %q, want an error", url, key)
+		}
+	}
+}
+
+func TestNewLocalStorageRejects(t *testing.T) {
+	for _, baseURL := range []string{"http://localhost:8080", "http://localhost:8080/", "http://localhost:8080/events", "http://localhost:8080/user_photos/new"} {
+		if _, err := NewLocalStorage("public", baseURL); err == nil {
+			t.Errorf("NewLocalStorage accepted %q", baseURL)
+		}
+	}
+}
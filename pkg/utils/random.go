@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// RandomToken returns a URL safe random string built from n random bytes.
+func RandomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// RandomDigits returns n random decimal digits, for codes users have to type.
+func RandomDigits(n int) (string, error) {
+	return RandomString(n, "0123456789")
+}
+
+// RandomString returns n characters picked at random from the alphabet.
+func RandomString(n int, alphabet string) (string, error) {
+	chars := make([]byte, n)
+	for i := range chars {
+		index, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", fmt.Errorf("failed to read random characters: %w", err)
+		}
+		chars[i] = alphabet[index.Int64()]
+	}
+	return string(chars), nil
+}
@@ -21,6 +21,15 @@ func GetUserFromContext(context *gin.Context) (*models.User, error) {
 	return &user, nil
 }
 
+// GetViewerFromContext returns the authenticated user, or nil on routes where authentication is optional.
+func GetViewerFromContext(context *gin.Context) *models.User {
+	user, err := GetUserFromContext(context)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
 func GetEventFromContext(context *gin.Context) (*models.Event, error) {
 	value, exists := context.Get("event")
 	if !exists {
@@ -35,3 +44,25 @@ func GetEventFromContext(context *gin.Context) (*models.Event, error) {
 
 	return &event, nil
 }
+
+func GetClaimsFromContext(context *gin.Context) (*AccessClaims, error) {
+	value, exists := context.Get("claims")
+	if !exists {
+		return nil, fmt.Errorf("token claims not found in context")
+	}
+
+	claims, ok := value.(AccessClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims in context %v", value)
+	}
+	return &claims, nil
+}
+
+// GetSessionDevice describes the device the request comes from, the label is what the client named it.
+func GetSessionDevice(context *gin.Context, label string) models.SessionDevice {
+	return models.SessionDevice{
+		Label:     label,
+		IP:        context.ClientIP(),
+		UserAgent: context.Request.UserAgent(),
+	}
+}
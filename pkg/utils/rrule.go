@@ -0,0 +1,323 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const icalTimeFormat = "20060102T150405Z"
+
+// maxRRulePeriods bounds rule expansion, so rules that rarely match (e.g. the 5th Monday) can't loop forever.
+const maxRRulePeriods = 10000
+
+type Frequency string
+
+const (
+	FreqDaily   Frequency = "DAILY"
+	FreqWeekly  Frequency = "WEEKLY"
+	FreqMonthly Frequency = "MONTHLY"
+)
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// WeekdayNum is a BYDAY entry. N is the weekday's ordinal within the month, 1 for the first,
+// -1 for the last and 0 for every such weekday. Ordinals are only allowed on MONTHLY rules.
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+func (w WeekdayNum) String() string {
+	code := strings.ToUpper(w.Day.String()[:2])
+	if w.N == 0 {
+		return code
+	}
+	return strconv.Itoa(w.N) + code
+}
+
+// RRule is the subset of RFC 5545 recurrence rules events support:
+// FREQ (DAILY, WEEKLY or MONTHLY), INTERVAL, BYDAY, COUNT and UNTIL.
+type RRule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []WeekdayNum
+	Count    int
+	Until    *time.Time
+}
+
+// ParseRRule parses a rule such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10", the "RRULE:" prefix is optional.
+func ParseRRule(value string) (*RRule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	rule := &RRule{Interval: 1}
+
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("malformed rrule part %q", part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(val))
+			if rule.Freq != FreqDaily && rule.Freq != FreqWeekly && rule.Freq != FreqMonthly {
+				return nil, fmt.Errorf("unsupported rrule frequency %q", val)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("invalid rrule interval %q", val)
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid rrule count %q", val)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseICalTime(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rrule until %q", val)
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, code := range strings.Split(val, ",") {
+				day, err := parseWeekdayNum(code)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "WKST":
+			// weeks always start on Monday
+		default:
+			return nil, fmt.Errorf("unsupported rrule part %q", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("rrule is missing FREQ")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("rrule can't have both COUNT and UNTIL")
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != FreqMonthly {
+			return nil, fmt.Errorf("BYDAY ordinals are only allowed on MONTHLY rules")
+		}
+	}
+	return rule, nil
+}
+
+func (r RRule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			days = append(days, day.String())
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(icalTimeFormat))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrences expands the rule starting at start and returns the occurrences within [from, to]
+// that aren't excluded, at most limit of them. COUNT is applied before exclusions as RFC 5545 requires.
+func (r RRule) Occurrences(start, from, to time.Time, exdates []time.Time, limit int) []time.Time {
+	occurrences := []time.Time{}
+	generated := 0
+	for period := 0; period < maxRRulePeriods; period++ {
+		for _, candidate := range r.periodCandidates(start, period) {
+			if candidate.Before(start) {
+				continue
+			}
+			if (r.Until != nil && candidate.After(*r.Until)) || candidate.After(to) {
+				return occurrences
+			}
+			generated++
+			if r.Count > 0 && generated > r.Count {
+				return occurrences
+			}
+			if candidate.Before(from) || isExcluded(candidate, exdates) {
+				continue
+			}
+			occurrences = append(occurrences, candidate)
+			if limit > 0 && len(occurrences) >= limit {
+				return occurrences
+			}
+		}
+	}
+	return occurrences
+}
+
+// periodCandidates lists the sorted candidate starts of the nth day, week or month of the rule.
+func (r RRule) periodCandidates(start time.Time, period int) []time.Time {
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+
+	candidates := []time.Time{}
+	switch r.Freq {
+	case FreqDaily:
+		day := start.AddDate(0, 0, period*r.Interval)
+		if r.matchesWeekday(day.Weekday()) {
+			candidates = append(candidates, day)
+		}
+	case FreqWeekly:
+		sinceMonday := (int(start.Weekday()) + 6) % 7
+		monday := at(start.Year(), start.Month(), start.Day()-sinceMonday+period*7*r.Interval)
+		for offset := 0; offset < 7; offset++ {
+			day := monday.AddDate(0, 0, offset)
+			matches := r.matchesWeekday(day.Weekday())
+			if len(r.ByDay) == 0 {
+				matches = day.Weekday() == start.Weekday()
+			}
+			if matches {
+				candidates = append(candidates, day)
+			}
+		}
+	case FreqMonthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(period*r.Interval), 1, 0, 0, 0, 0, start.Location())
+		if len(r.ByDay) == 0 {
+			// months without the start's day of month are skipped
+			day := at(first.Year(), first.Month(), start.Day())
+			if day.Month() == first.Month() {
+				candidates = append(candidates, day)
+			}
+			break
+		}
+		daysInMonth := time.Date(first.Year(), first.Month()+1, 0, 0, 0, 0, 0, start.Location()).Day()
+		seen := map[int]bool{}
+		for _, byDay := range r.ByDay {
+			matches := []int{}
+			for d := 1; d <= daysInMonth; d++ {
+				if time.Date(first.Year(), first.Month(), d, 0, 0, 0, 0, start.Location()).Weekday() == byDay.Day {
+					matches = append(matches, d)
+				}
+			}
+			switch {
+			case byDay.N == 0:
+			case byDay.N > 0 && byDay.N <= len(matches):
+				matches = matches[byDay.N-1 : byDay.N]
+			case byDay.N < 0 && -byDay.N <= len(matches):
+				matches = matches[len(matches)+byDay.N : len(matches)+byDay.N+1]
+			default:
+				matches = nil
+			}
+			for _, d := range matches {
+				if !seen[d] {
+					seen[d] = true
+					candidates = append(candidates, at(first.Year(), first.Month(), d))
+				}
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	}
+	return candidates
+}
+
+func (r RRule) matchesWeekday(day time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, byDay := range r.ByDay {
+		if byDay.Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+// ShiftWallClock moves t the way from was moved to to, by whole days and local clock time in to's
+// location rather than by their duration, so times on both sides of a DST change keep their local time.
+func ShiftWallClock(t, from, to time.Time) time.Time {
+	location := to.Location()
+	t, from = t.In(location), from.In(location)
+	days := civilDays(to) - civilDays(from)
+	return time.Date(t.Year(), t.Month(), t.Day()+days,
+		t.Hour()+to.Hour()-from.Hour(), t.Minute()+to.Minute()-from.Minute(), t.Second()+to.Second()-from.Second(),
+		t.Nanosecond(), location)
+}
+
+// civilDays counts the calendar days of t's local date since the Unix epoch.
+func civilDays(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// ParseExDates parses a comma separated list of UTC times in iCalendar form, e.g. 20250102T150405Z.
+func ParseExDates(value string) ([]time.Time, error) {
+	exdates := []time.Time{}
+	if strings.TrimSpace(value) == "" {
+		return exdates, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		exdate, err := parseICalTime(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid exdate %q", part)
+		}
+		exdates = append(exdates, exdate)
+	}
+	return exdates, nil
+}
+
+func FormatExDates(exdates []time.Time) string {
+	sorted := append([]time.Time{}, exdates...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	parts := make([]string, 0, len(sorted))
+	for _, exdate := range sorted {
+		parts = append(parts, exdate.UTC().Format(icalTimeFormat))
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseICalTime(value string) (time.Time, error) {
+	if len(value) == len("20060102") {
+		// a date only UNTIL covers that whole day
+		day, err := time.Parse("20060102", value)
+		return day.Add(24*time.Hour - time.Second), err
+	}
+	return time.Parse(icalTimeFormat, value)
+}
+
+func parseWeekdayNum(code string) (WeekdayNum, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid rrule weekday %q", code)
+	}
+	day, ok := weekdayCodes[code[len(code)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid rrule weekday %q", code)
+	}
+	n := 0
+	if ordinal := code[:len(code)-2]; ordinal != "" {
+		var err error
+		n, err = strconv.Atoi(ordinal)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayNum{}, fmt.Errorf("invalid rrule weekday %q", code)
+		}
+	}
+	return WeekdayNum{N: n, Day: day}, nil
+}
+
+func isExcluded(t time.Time, exdates []time.Time) bool {
+	for _, exdate := range exdates {
+		if exdate.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
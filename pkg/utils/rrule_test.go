@@ -0,0 +1,283 @@
+package utils
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("failed to load %s: %v", name, err)
+	}
+	return location
+}
+
+func utc(year int, month time.Month, day, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"prefix and byday", "RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10", "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10"},
+		{"lower case and ordinals", "freq=monthly;byday=-1fr,2tu;interval=2", "FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR,2TU"},
+		{"until and wkst", "FREQ=DAILY;UNTIL=20250105T100000Z;WKST=MO", "FREQ=DAILY;UNTIL=20250105T100000Z"},
+		{"date only until covers the day", "FREQ=DAILY;UNTIL=20250103", "FREQ=DAILY;UNTIL=20250103T235959Z"},
+		{"interval of one is implied", "FREQ=DAILY;INTERVAL=1", "FREQ=DAILY"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRRule(test.value)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q) failed: %v", test.value, err)
+			}
+			if got := rule.String(); got != test.want {
+				t.Errorf("ParseRRule(%q) = %q, want %q", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseRRuleRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"missing freq", "COUNT=3"},
+		{"yearly", "FREQ=YEARLY"},
+		{"part without value", "FREQ=DAILY;COUNT"},
+		{"zero interval", "FREQ=DAILY;INTERVAL=0"},
+		{"zero count", "FREQ=DAILY;COUNT=0"},
+		{"count and until", "FREQ=DAILY;COUNT=2;UNTIL=20250101T000000Z"},
+		{"local until", "FREQ=DAILY;UNTIL=20250101T000000"},
+		{"unknown weekday", "FREQ=WEEKLY;BYDAY=XX"},
+		{"ordinal on weekly", "FREQ=WEEKLY;BYDAY=2MO"},
+		{"ordinal out of range", "FREQ=MONTHLY;BYDAY=6MO"},
+		{"unsupported part", "FREQ=DAILY;BYSETPOS=1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if rule, err := ParseRRule(test.value); err == nil {
+				t.Errorf("ParseRRule(%q) = %q, want an error", test.value, rule.String())
+			}
+		})
+	}
+}
+
+func TestParseExDates(t *testing.T) {
+	exdates, err := ParseExDates(" 20250103T100000Z, 20250101T100000Z ")
+	if err != nil {
+		t.Fatalf("ParseExDates failed: %v", err)
+	}
+	want := []time.Time{utc(2025, time.January, 3, 10), utc(2025, time.January, 1, 10)}
+	if len(exdates) != len(want) {
+		t.Fatalf("ParseExDates returned %v, want %v", exdates, want)
+	}
+	for i := range want {
+		if !exdates[i].Equal(want[i]) {
+			t.Errorf("exdate %d = %s, want %s", i, exdates[i], want[i])
+		}
+	}
+	if got := FormatExDates(exdates); got != "20250101T100000Z,20250103T100000Z" {
+		t.Errorf("FormatExDates = %q, want them sorted", got)
+	}
+
+	if exdates, err := ParseExDates(""); err != nil || len(exdates) != 0 {
+		t.Errorf("ParseExDates(\"\") = %v, %v, want no exdates", exdates, err)
+	}
+	for _, value := range []string{"2025-01-03", "20250103T100000Z,", "20250103T100000"} {
+		if _, err := ParseExDates(value); err == nil {
+			t.Errorf("ParseExDates(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	newYork := mustLocation(t, "America/New_York")
+
+	tests := []struct {
+		name     string
+		rrule    string
+		start    time.Time
+		from, to time.Time // zero means start and a year later
+		exdates  []time.Time
+		limit    int
+		want     []time.Time
+	}{
+		{
+			name:  "weekly byday",
+			rrule: "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4",
+			start: utc(2025, time.January, 6, 10),
+			want:  []time.Time{utc(2025, time.January, 6, 10), utc(2025, time.January, 8, 10), utc(2025, time.January, 13, 10), utc(2025, time.January, 15, 10)},
+		},
+		{
+			name:  "weekly byday skips days before the start",
+			rrule: "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=3",
+			start: utc(2025, time.January, 6, 10),
+			want:  []time.Time{utc(2025, time.January, 7, 10), utc(2025, time.January, 9, 10), utc(2025, time.January, 14, 10)},
+		},
+		{
+			name:  "biweekly without byday repeats the start's weekday",
+			rrule: "FREQ=WEEKLY;INTERVAL=2;COUNT=3",
+			start: utc(2025, time.January, 8, 10),
+			want:  []time.Time{utc(2025, time.January, 8, 10), utc(2025, time.January, 22, 10), utc(2025, time.February, 5, 10)},
+		},
+		{
+			name:  "monthly second tuesday",
+			rrule: "FREQ=MONTHLY;BYDAY=2TU;COUNT=3",
+			start: utc(2025, time.January, 14, 10),
+			want:  []time.Time{utc(2025, time.January, 14, 10), utc(2025, time.February, 11, 10), utc(2025, time.March, 11, 10)},
+		},
+		{
+			name:  "monthly last friday",
+			rrule: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			start: utc(2025, time.January, 31, 10),
+			want:  []time.Time{utc(2025, time.January, 31, 10), utc(2025, time.February, 28, 10), utc(2025, time.March, 28, 10)},
+		},
+		{
+			name:  "monthly skips months without the day",
+			rrule: "FREQ=MONTHLY;COUNT=3",
+			start: utc(2025, time.January, 31, 10),
+			want:  []time.Time{utc(2025, time.January, 31, 10), utc(2025, time.March, 31, 10), utc(2025, time.May, 31, 10)},
+		},
+		{
+			name:  "until is inclusive",
+			rrule: "FREQ=DAILY;UNTIL=20250103T100000Z",
+			start: utc(2025, time.January, 1, 10),
+			want:  []time.Time{utc(2025, time.January, 1, 10), utc(2025, time.January, 2, 10), utc(2025, time.January, 3, 10)},
+		},
+		{
+			name:  "date only until",
+			rrule: "FREQ=DAILY;UNTIL=20250102",
+			start: utc(2025, time.January, 1, 22),
+			want:  []time.Time{utc(2025, time.January, 1, 22), utc(2025, time.January, 2, 22)},
+		},
+		{
+			name:    "exdates count towards count",
+			rrule:   "FREQ=DAILY;INTERVAL=2;COUNT=3",
+			start:   utc(2025, time.January, 1, 10),
+			exdates: []time.Time{utc(2025, time.January, 3, 10)},
+			want:    []time.Time{utc(2025, time.January, 1, 10), utc(2025, time.January, 5, 10)},
+		},
+		{
+			name:    "exdates only match exact starts",
+			rrule:   "FREQ=DAILY;COUNT=2",
+			start:   utc(2025, time.January, 1, 10),
+			exdates: []time.Time{utc(2025, time.January, 2, 11)},
+			want:    []time.Time{utc(2025, time.January, 1, 10), utc(2025, time.January, 2, 10)},
+		},
+		{
+			name:  "window",
+			rrule: "FREQ=WEEKLY",
+			start: utc(2025, time.January, 6, 10),
+			from:  utc(2025, time.January, 20, 0),
+			to:    utc(2025, time.February, 3, 10),
+			want:  []time.Time{utc(2025, time.January, 20, 10), utc(2025, time.January, 27, 10), utc(2025, time.February, 3, 10)},
+		},
+		{
+			name:  "limit",
+			rrule: "FREQ=DAILY",
+			start: utc(2025, time.January, 1, 10),
+			limit: 2,
+			want:  []time.Time{utc(2025, time.January, 1, 10), utc(2025, time.January, 2, 10)},
+		},
+		{
+			name:  "weekly keeps the local time when DST starts",
+			rrule: "FREQ=WEEKLY;COUNT=3",
+			start: time.Date(2025, time.March, 20, 18, 0, 0, 0, berlin),
+			want: []time.Time{
+				time.Date(2025, time.March, 20, 18, 0, 0, 0, berlin),
+				time.Date(2025, time.March, 27, 18, 0, 0, 0, berlin),
+				time.Date(2025, time.April, 3, 18, 0, 0, 0, berlin), // 16:00 UTC instead of 17:00
+			},
+		},
+		{
+			name:  "daily keeps the local time when DST ends",
+			rrule: "FREQ=DAILY;COUNT=3",
+			start: time.Date(2025, time.November, 1, 9, 0, 0, 0, newYork),
+			want: []time.Time{
+				time.Date(2025, time.November, 1, 9, 0, 0, 0, newYork),
+				time.Date(2025, time.November, 2, 9, 0, 0, 0, newYork),
+				time.Date(2025, time.November, 3, 9, 0, 0, 0, newYork),
+			},
+		},
+		{
+			name:  "monthly byday keeps the local time across DST",
+			rrule: "FREQ=MONTHLY;BYDAY=1SU;COUNT=2",
+			start: time.Date(2025, time.March, 2, 10, 0, 0, 0, berlin),
+			want: []time.Time{
+				time.Date(2025, time.March, 2, 10, 0, 0, 0, berlin),
+				time.Date(2025, time.April, 6, 10, 0, 0, 0, berlin),
+			},
+		},
+		{
+			name:    "exdates match local starts after DST",
+			rrule:   "FREQ=WEEKLY;COUNT=3",
+			start:   time.Date(2025, time.March, 20, 18, 0, 0, 0, berlin),
+			exdates: []time.Time{time.Date(2025, time.April, 3, 16, 0, 0, 0, time.UTC)},
+			want: []time.Time{
+				time.Date(2025, time.March, 20, 18, 0, 0, 0, berlin),
+				time.Date(2025, time.March, 27, 18, 0, 0, 0, berlin),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRRule(test.rrule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q) failed: %v", test.rrule, err)
+			}
+			from, to := test.from, test.to
+			if from.IsZero() {
+				from = test.start
+			}
+			if to.IsZero() {
+				to = test.start.AddDate(1, 0, 0)
+			}
+
+			got := rule.Occurrences(test.start, from, to, test.exdates, test.limit)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for i := range test.want {
+				if !got[i].Equal(test.want[i]) {
+					t.Errorf("occurrence %d = %s, want %s", i, got[i], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestShiftWallClock(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2025, month, day, hour, 0, 0, 0, berlin)
+	}
+
+	tests := []struct {
+		name        string
+		t, from, to time.Time
+		want        time.Time
+	}{
+		{"an hour later after DST starts", at(time.April, 3, 18), at(time.March, 27, 18), at(time.March, 27, 19), at(time.April, 3, 19)},
+		{"a day later after DST starts", at(time.April, 3, 18), at(time.March, 27, 18), at(time.March, 28, 18), at(time.April, 4, 18)},
+		{"earlier across midnight", at(time.April, 3, 18), at(time.March, 27, 18), at(time.March, 26, 23), at(time.April, 2, 23)},
+		{"unchanged", at(time.April, 3, 18), at(time.March, 27, 18), at(time.March, 27, 18), at(time.April, 3, 18)},
+		{"from in UTC", at(time.April, 3, 18), at(time.March, 27, 18).UTC(), at(time.March, 27, 19), at(time.April, 3, 19)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ShiftWallClock(test.t, test.from, test.to); !got.Equal(test.want) {
+				t.Errorf("ShiftWallClock = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
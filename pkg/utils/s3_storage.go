@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config configures an S3 compatible bucket, e.g. AWS S3 or a local MinIO.
+// PublicURL is where the bucket's objects are served from, it defaults to the bucket on the endpoint.
+type S3Config struct {
+	Endpoint  string // e.g. "s3.eu-west-1.amazonaws.com" or "localhost:9000"
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+	PublicURL string
+}
+
+// S3Storage keeps files in an S3 compatible bucket so every replica sees the same files.
+type S3Storage struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+}
+
+func NewS3Storage(config S3Config) (*S3Storage, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("s3 storage needs an endpoint and a bucket")
+	}
+
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.UseSSL,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 client: %w", err)
+	}
+
+	publicURL := config.PublicURL
+	if publicURL == "" {
+		scheme := "https"
+		if !config.UseSSL {
+			scheme = "http"
+		}
+		publicURL = fmt.Sprintf("%s://%s/%s", scheme, config.Endpoint, config.Bucket)
+	}
+	return &S3Storage{client: client, bucket: config.Bucket, publicURL: strings.TrimSuffix(publicURL, "/")}, nil
+}
+
+func (s *S3Storage) Save(key string, content io.Reader, size int64, contentType string) (string, error) {
+	_, err := s.client.PutObject(context.Background(), s.bucket, key, content, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s to bucket %s: %w", key, s.bucket, err)
+	}
+	return s.publicURL + "/" + key, nil
+}
+
+func (s *S3Storage) Open(key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(context.Background(), s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s from bucket %s: %w", key, s.bucket, err)
+	}
+	// GetObject is lazy, Stat surfaces missing objects before the caller starts reading
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, fmt.Errorf("failed to get %s from bucket %s: %w", key, s.bucket, err)
+	}
+	return object, nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	err := s.client.RemoveObject(context.Background(), s.bucket, key, minio.RemoveObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s from bucket %s: %w", key, s.bucket, err)
+	}
+	return nil
+}
+
+func (s *S3Storage) KeyFromURL(fileURL string) (string, error) {
+	key := strings.TrimPrefix(fileURL, s.publicURL+"/")
+	if key == fileURL { // No prefix removed
+		return "", fmt.Errorf("invalid URL format: %s", fileURL)
+	}
+	return key, nil
+}
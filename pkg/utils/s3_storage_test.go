@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestS3Storage connects to the bucket given by S3_TEST_ENDPOINT, e.g. the MinIO of
+// docker-compose.minio.yml at localhost:9000. Tests that need it are skipped without one.
+func newTestS3Storage(t *testing.T) *S3Storage {
+	t.Helper()
+	endpoint := GetFromEnv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT is not set, run `make minio` and set it to localhost:9000")
+	}
+	storage, err := NewS3Storage(S3Config{
+		Endpoint:  endpoint,
+		Region:    GetFromEnvOr("S3_TEST_REGION", "us-east-1"),
+		Bucket:    GetFromEnvOr("S3_TEST_BUCKET", "wander-base"),
+		AccessKey: GetFromEnvOr("S3_TEST_ACCESS_KEY", "minioadmin"),
+		SecretKey: GetFromEnvOr("S3_TEST_SECRET_KEY", "minioadmin"),
+		UseSSL:    GetFromEnv("S3_TEST_USE_SSL") == "true",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return storage
+}
+
+func TestS3Storage(t *testing.T) {
+	storage := newTestS3Storage(t)
+	key := fmt.Sprintf("tests/%d.txt", time.Now().UnixNano())
+	content := "wander-base storage test"
+
+	url, err := storage.Save(key, strings.NewReader(content), int64(len(content)), "text/plain")
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	t.Cleanup(func() { storage.Delete(key) })
+
+	if got, err := storage.KeyFromURL(url); err != nil || got != key {
+		t.Errorf("KeyFromURL(%q) = %q, %v, want %q", url, got, err, key)
+	}
+
+	file, err := storage.Open(key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	read, err := io.ReadAll(file)
+	file.Close()
+	if err != nil || string(read) != content {
+		t.Errorf("Open read %q, %v, want %q", read, err, content)
+	}
+
+	if err := storage.Delete(key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if file, err := storage.Open(key); err == nil {
+		file.Close()
+		t.Error("Open found the deleted file")
+	}
+}
+
+func TestS3StorageKeyFromURL(t *testing.T) {
+	// The client doesn't connect until it's used, so no endpoint has to be running
+	storage, err := NewS3Storage(S3Config{Endpoint: "localhost:9000", Bucket: "wander-base"})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	if key, err := storage.KeyFromURL("http://localhost:9000/wander-base/events/1-x.jpg"); err != nil || key != "events/1-x.jpg" {
+		t.Errorf("KeyFromURL = %q, %v, want %q", key, err, "events/1-x.jpg")
+	}
+	for _, url := range []string{"http://localhost:9000/other/events/1-x.jpg", "http://localhost:8080/media/events/1-x.jpg"} {
+		if key, err := storage.KeyFromURL(url); err == nil {
+			t.Errorf("KeyFromURL(%q) = %q, want an error", url, key)
+		}
+	}
+
+	custom, err := NewS3Storage(S3Config{Endpoint: "localhost:9000", Bucket: "wander-base", PublicURL: "https://cdn.example.com/"})
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	if key, err := custom.KeyFromURL("https://cdn.example.com/events/1-x.jpg"); err != nil || key != "events/1-x.jpg" {
+		t.Errorf("KeyFromURL with a public URL = %q, %v, want %q", key, err, "events/1-x.jpg")
+	}
+}
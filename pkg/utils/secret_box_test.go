@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestSealSecret(t *testing.T) {
+	t.Setenv("SECRET_KEY", "test secret key")
+
+	sealed, err := SealSecret("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("SealSecret: %v", err)
+	}
+	if sealed == "JBSWY3DPEHPK3PXP" {
+		t.Fatal("SealSecret returned the plaintext")
+	}
+	again, err := SealSecret("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("SealSecret: %v", err)
+	}
+	if again == sealed {
+		t.Error("SealSecret reused a nonce")
+	}
+
+	opened, err := OpenSecret(sealed)
+	if err != nil {
+		t.Fatalf("OpenSecret: %v", err)
+	}
+	if opened != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("OpenSecret = %q, want %q", opened, "JBSWY3DPEHPK3PXP")
+	}
+}
+
+func TestOpenSecretRejects(t *testing.T) {
+	t.Setenv("SECRET_KEY", "test secret key")
+	sealed, err := SealSecret("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("SealSecret: %v", err)
+	}
+
+	data, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil {
+		t.Fatalf("sealed secret isn't base64: %v", err)
+	}
+	data[len(data)-1] ^= 1
+	tampered := base64.RawStdEncoding.EncodeToString(data)
+	tests := map[string]string{
+		"tampered":   tampered,
+		"too short":  "AAAA",
+		"not base64": "%%%",
+	}
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := OpenSecret(value); err == nil {
+				t.Error("OpenSecret succeeded")
+			}
+		})
+	}
+
+	t.Run("other key", func(t *testing.T) {
+		t.Setenv("SECRET_KEY", "another key")
+		if _, err := OpenSecret(sealed); err == nil {
+			t.Error("OpenSecret succeeded")
+		}
+	})
+}
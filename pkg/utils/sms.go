@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// SMSSender delivers text messages to E.164 phone numbers, e.g. "+201001234567".
+type SMSSender interface {
+	Send(ctx context.Context, phone, message string) error
+}
+
+// NewSMSSenderFromEnv picks the SMS driver from SMS_DRIVER, "console" (default) or "file".
+// Both only suit local development, a real provider has to implement SMSSender.
+func NewSMSSenderFromEnv() (SMSSender, error) {
+	switch driver := GetFromEnv("SMS_DRIVER"); driver {
+	case "", "console":
+		return ConsoleSMSSender{}, nil
+	case "file":
+		return NewFileSMSSender(GetFromEnvOr("SMS_FILE", "sms.log")), nil
+	default:
+		return nil, fmt.Errorf("unknown SMS driver %q", driver)
+	}
+}
+
+// ConsoleSMSSender writes messages to the server log instead of sending them.
+type ConsoleSMSSender struct{}
+
+func (ConsoleSMSSender) Send(ctx context.Context, phone, message string) error {
+	log.Printf("SMS to %s: %s", phone, message)
+	return nil
+}
+
+// FileSMSSender appends messages to a file, one per line, so scripts and tests can read the codes.
+type FileSMSSender struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func NewFileSMSSender(path string) *FileSMSSender {
+	return &FileSMSSender{Path: path}
+}
+
+func (s *FileSMSSender) Send(ctx context.Context, phone, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open SMS file %s: %w", s.Path, err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), phone, message); err != nil {
+		return fmt.Errorf("failed to write SMS file %s: %w", s.Path, err)
+	}
+	return nil
+}
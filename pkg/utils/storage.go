@@ -3,69 +3,46 @@ package utils
 import (
 	"fmt"
 	"io"
-	"mime/multipart"
-	"os"
-	"path/filepath"
-	"strings"
-	"time"
 )
 
-type Storage struct {
-	baseURL string // e.g., "http://localhost:8080"
+// Storage keeps uploaded files under keys such as "events/12-20250102T150405-x1y2z3".
+// Drivers return the public URL of every file they save, the URL is what gets stored in the DB.
+type Storage interface {
+	Save(key string, content io.Reader, size int64, contentType string) (string, error)
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	// KeyFromURL maps a URL returned by Save back to its key.
+	KeyFromURL(url string) (string, error)
 }
 
-func NewStorage(baseURL string) *Storage {
-	return &Storage{baseURL: baseURL}
-}
-
-func (s *Storage) UploadFile(file *multipart.FileHeader, prefix string, id int64) (string, error) {
-	// Validate MIME type
-	if !strings.HasPrefix(file.Header.Get("Content-Type"), "image/") {
-		return "", fmt.Errorf("only image files are allowed")
-	}
-
-	// Ensure directory exists
-	dir := filepath.Join("public", prefix)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
-	}
-
-	// Generate unique filename
-	timestamp := time.Now().Format("20060102T150405")
-	filename := fmt.Sprintf("%d-%s", id, timestamp)
-	path := filepath.Join(dir, filename)
-
-	// Save file
-	src, err := file.Open()
-	if err != nil {
-		return "", fmt.Errorf("failed to open file: %w", err)
+// NewStorageFromEnv picks the storage driver from STORAGE_DRIVER, "local" (default) or "s3".
+func NewStorageFromEnv() (Storage, error) {
+	switch driver := GetFromEnv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		return NewLocalStorage(
+			GetFromEnvOr("STORAGE_LOCAL_DIR", "public"),
+			GetFromEnvOr("STORAGE_PUBLIC_URL", "http://localhost:8080/media"),
+		)
+	case "s3":
+		return NewS3Storage(S3Config{
+			Endpoint:  GetFromEnv("S3_ENDPOINT"),
+			Region:    GetFromEnv("S3_REGION"),
+			Bucket:    GetFromEnv("S3_BUCKET"),
+			AccessKey: GetFromEnv("S3_ACCESS_KEY"),
+			SecretKey: GetFromEnv("S3_SECRET_KEY"),
+			UseSSL:    GetFromEnv("S3_USE_SSL") != "false",
+			PublicURL: GetFromEnv("S3_PUBLIC_URL"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
 	}
-	defer src.Close()
-
-	dst, err := os.Create(path)
-	if err != nil {
-		return "", fmt.Errorf("failed to create file %s: %w", path, err)
-	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, src); err != nil {
-		return "", fmt.Errorf("failed to write file %s: %w", path, err)
-	}
-
-	// Construct URL
-	url := fmt.Sprintf("%s/%s/%s", s.baseURL, prefix, filename)
-	return url, nil
 }
 
-func (s *Storage) DeleteFile(url string) error {
-	// Extract path from URL
-	path := strings.TrimPrefix(url, s.baseURL)
-	if path == url { // No prefix removed
-		return fmt.Errorf("invalid URL format: %s", url)
-	}
-	fullPath := filepath.Join("public", strings.TrimPrefix(path, "/"))
-	if err := os.Remove(fullPath); err != nil {
-		return fmt.Errorf("failed to delete file %s: %w", fullPath, err)
+// DeleteFile deletes the file behind a URL returned by Save.
+func DeleteFile(s Storage, url string) error {
+	key, err := s.KeyFromURL(url)
+	if err != nil {
+		return err
 	}
-	return nil
+	return s.Delete(key)
 }
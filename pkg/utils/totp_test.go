@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Key is the SHA1 seed of the RFC 6238 test vectors.
+var rfc6238Key = []byte("12345678901234567890")
+
+// The RFC lists 8 digit codes, the 6 digit ones are their last 6 digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCode(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		step := TOTPStep(time.Unix(vector.unix, 0))
+		if got := totpCode(rfc6238Key, step); got != vector.code {
+			t.Errorf("totpCode at %d = %s, want %s", vector.unix, got, vector.code)
+		}
+	}
+}
+
+func TestCheckTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfc6238Key)
+	for _, vector := range rfc6238Vectors {
+		now := time.Unix(vector.unix, 0)
+		step, ok := CheckTOTP(secret, vector.code, now)
+		if !ok || step != TOTPStep(now) {
+			t.Errorf("CheckTOTP at %d = (%d, %v), want (%d, true)", vector.unix, step, ok, TOTPStep(now))
+		}
+	}
+
+	now := time.Unix(1111111109, 0)
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		now    time.Time
+		ok     bool
+	}{
+		{"lower case secret", strings.ToLower(secret), "081804", now, true},
+		{"previous step", secret, "081804", now.Add(TOTPPeriod), true},
+		{"next step", secret, "081804", now.Add(-TOTPPeriod), true},
+		{"two steps later", secret, "081804", now.Add(2 * TOTPPeriod), false},
+		{"wrong code", secret, "081805", now, false},
+		{"short code", secret, "81804", now, false},
+		{"malformed secret", "not base32!", "081804", now, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, ok := CheckTOTP(test.secret, test.code, test.now); ok != test.ok {
+				t.Errorf("CheckTOTP = %v, want %v", ok, test.ok)
+			}
+		})
+	}
+}